@@ -40,13 +40,14 @@ func main() {
 		log.Fatalf("Failed to create Elasticsearch sink: %v", err)
 	}
 
-	// Create and configure the pipeline
+	// Create and configure the pipeline. Pulling in batches lets every run
+	// commit its offsets once Elasticsearch has the records.
 	p := pipeline.NewPipeline(
 		"kafka-to-elasticsearch",
 		source,
 		sink,
 		filter,
-	)
+	).WithPullConfig(&pipeline.PullConfig{BatchSize: 1000})
 
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -62,10 +63,20 @@ func main() {
 		cancel()
 	}()
 
-	// Run the pipeline
+	// Run the pipeline batch by batch until shutdown. A failed batch stops
+	// the pipeline, as the reader has moved past it; its offsets were not
+	// committed, so it is read again on restart.
 	log.Println("Starting pipeline...")
-	if err := p.Run(ctx); err != nil {
-		log.Printf("Pipeline error: %v", err)
+	for ctx.Err() == nil {
+		if err := p.Run(ctx); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Pipeline error: %v", err)
+			}
+			break
+		}
+	}
+	if err := source.Err(); err != nil {
+		log.Printf("Last Kafka error: %v", err)
 	}
 
 	// Stop the pipeline
@@ -0,0 +1,301 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// AvroConfig configures an Avro codec
+type AvroConfig struct {
+	// Schema is the writer schema used for encoding, and for decoding when
+	// payloads carry no schema ID
+	Schema string
+	// ConfluentWireFormat prefixes payloads with the magic byte and schema ID
+	ConfluentWireFormat bool
+	// SchemaID is written into encoded payloads in the Confluent wire format
+	SchemaID int
+	// Resolver looks up the writer schema of decoded Confluent payloads by
+	// ID. Without one, every payload is decoded with Schema.
+	Resolver SchemaResolver
+}
+
+// AvroCodec encodes records as Avro binary. Streams use object container
+// files.
+type AvroCodec struct {
+	config AvroConfig
+	schema avro.Schema
+
+	mu      sync.RWMutex
+	schemas map[int]avro.Schema
+}
+
+// NewAvro creates an Avro codec
+func NewAvro(config AvroConfig) (*AvroCodec, error) {
+	c := &AvroCodec{
+		config:  config,
+		schemas: make(map[int]avro.Schema),
+	}
+	if config.Schema != "" {
+		schema, err := avro.Parse(config.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid avro schema: %w", err)
+		}
+		c.schema = schema
+	} else if config.Resolver == nil {
+		return nil, fmt.Errorf("avro codec requires a schema or a resolver")
+	}
+	return c, nil
+}
+
+// Name implements Codec
+func (c *AvroCodec) Name() string { return "avro" }
+
+// ContentType implements Codec
+func (c *AvroCodec) ContentType() string { return "application/avro" }
+
+// Decode implements Codec
+func (c *AvroCodec) Decode(data []byte) (map[string]interface{}, error) {
+	schema := c.schema
+	if c.config.ConfluentWireFormat {
+		id, body, err := SplitConfluent(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode avro: %w", err)
+		}
+		if schema, err = c.schemaByID(id); err != nil {
+			return nil, err
+		}
+		data = body
+	}
+
+	var record map[string]interface{}
+	if err := avro.Unmarshal(schema, data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode avro: %w", err)
+	}
+	return record, nil
+}
+
+// Encode implements Codec. JSON numbers are converted to the integer and
+// float types the schema declares.
+func (c *AvroCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	if c.schema == nil {
+		return nil, fmt.Errorf("avro codec has no schema to encode with")
+	}
+	body, err := avro.Marshal(c.schema, avroNative(c.schema, data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode avro: %w", err)
+	}
+	if !c.config.ConfluentWireFormat {
+		return body, nil
+	}
+	return append(ConfluentHeader(c.config.SchemaID), body...), nil
+}
+
+// SchemaType implements RegistryCodec
+func (c *AvroCodec) SchemaType() string { return "AVRO" }
+
+// Schema implements RegistryCodec. The configured text is returned as is,
+// since the canonical form drops defaults needed for compatibility checks.
+func (c *AvroCodec) Schema() string {
+	return c.config.Schema
+}
+
+// UseRegistry implements RegistryCodec. It must be called before the codec
+// is shared between goroutines.
+func (c *AvroCodec) UseRegistry(id int, resolver SchemaResolver) {
+	c.config.ConfluentWireFormat = true
+	c.config.SchemaID = id
+	c.config.Resolver = resolver
+}
+
+// schemaByID resolves and caches the writer schema for a schema ID
+func (c *AvroCodec) schemaByID(id int) (avro.Schema, error) {
+	if c.config.Resolver == nil {
+		return c.schema, nil
+	}
+
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	text, err := c.config.Resolver(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve schema %d: %w", id, err)
+	}
+	schema, err = avro.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema %d: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// NewDecoder implements Codec. The writer schema is read from the container
+// header.
+func (c *AvroCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	dec, err := ocf.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avro container header: %w", err)
+	}
+	return &avroDecoder{dec: dec}, nil
+}
+
+// NewEncoder implements Codec
+func (c *AvroCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	if c.schema == nil {
+		return nil, fmt.Errorf("avro codec has no schema to encode with")
+	}
+	enc, err := ocf.NewEncoder(c.config.Schema, w)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create avro container: %w", err)
+	}
+	return &avroEncoder{enc: enc, schema: c.schema}, nil
+}
+
+type avroDecoder struct {
+	dec *ocf.Decoder
+}
+
+func (d *avroDecoder) Decode() (map[string]interface{}, error) {
+	if !d.dec.HasNext() {
+		if err := d.dec.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read avro container: %w", err)
+		}
+		return nil, io.EOF
+	}
+	var record map[string]interface{}
+	if err := d.dec.Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to decode avro: %w", err)
+	}
+	return record, nil
+}
+
+type avroEncoder struct {
+	enc    *ocf.Encoder
+	schema avro.Schema
+}
+
+func (e *avroEncoder) Encode(data map[string]interface{}) error {
+	if err := e.enc.Encode(avroNative(e.schema, data)); err != nil {
+		return fmt.Errorf("failed to encode avro: %w", err)
+	}
+	return nil
+}
+
+func (e *avroEncoder) Close() error {
+	return e.enc.Close()
+}
+
+// avroNative converts values decoded from JSON (float64 and json.Number) to
+// the numeric types the schema expects, so JSON records can be encoded
+func avroNative(schema avro.Schema, v interface{}) interface{} {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, fv := range m {
+			out[k] = fv
+		}
+		for _, f := range s.Fields() {
+			if fv, ok := m[f.Name()]; ok {
+				out[f.Name()] = avroNative(f.Type(), fv)
+			}
+		}
+		return out
+	case *avro.ArraySchema:
+		items, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			out[i] = avroNative(s.Items(), item)
+		}
+		return out
+	case *avro.MapSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, mv := range m {
+			out[k] = avroNative(s.Values(), mv)
+		}
+		return out
+	case *avro.UnionSchema:
+		if v == nil || !s.Nullable() {
+			return v
+		}
+		for _, t := range s.Types() {
+			if t.Type() != avro.Null {
+				return avroNative(t, v)
+			}
+		}
+		return v
+	case *avro.PrimitiveSchema:
+		if s.Logical() != nil {
+			return v
+		}
+		return avroNumber(s.Type(), v)
+	}
+	return v
+}
+
+func avroNumber(t avro.Type, v interface{}) interface{} {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case json.Number:
+		if t == avro.Int || t == avro.Long {
+			if i, err := n.Int64(); err == nil {
+				return avroInteger(t, i)
+			}
+		}
+		parsed, err := n.Float64()
+		if err != nil {
+			return v
+		}
+		f = parsed
+	default:
+		return v
+	}
+
+	switch t {
+	case avro.Int, avro.Long:
+		if f != math.Trunc(f) {
+			return v
+		}
+		return avroInteger(t, int64(f))
+	case avro.Float:
+		return float32(f)
+	case avro.Double:
+		return f
+	}
+	return v
+}
+
+func avroInteger(t avro.Type, i int64) interface{} {
+	if t == avro.Int {
+		return int32(i)
+	}
+	return i
+}
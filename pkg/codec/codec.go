@@ -0,0 +1,90 @@
+// Package codec converts between serialized payloads and pipeline record data.
+package codec
+
+import (
+	"fmt"
+	"io"
+)
+
+// Codec decodes payloads into Record.Data and encodes Record.Data back.
+// Decode and Encode work on a single message, e.g. a Kafka value, while
+// NewDecoder and NewEncoder work on streams holding many records, e.g. a file.
+type Codec interface {
+	// Name identifies the codec, e.g. "json" or "avro"
+	Name() string
+	// ContentType is the MIME type of encoded payloads
+	ContentType() string
+	Decode(data []byte) (map[string]interface{}, error)
+	Encode(data map[string]interface{}) ([]byte, error)
+	NewDecoder(r io.Reader) (Decoder, error)
+	NewEncoder(w io.Writer) (Encoder, error)
+}
+
+// Decoder reads records from a stream. Decode returns io.EOF once the stream
+// is exhausted.
+type Decoder interface {
+	Decode() (map[string]interface{}, error)
+}
+
+// LineDecoder is implemented by decoders of line-oriented formats. Line
+// returns the 1-based line number of the last decoded record.
+type LineDecoder interface {
+	Decoder
+	Line() int
+}
+
+// ClosingDecoder is implemented by decoders that hold resources of their
+// own, such as temporary files. Close releases them and must be called by
+// callers that stop before io.EOF; it may be called more than once.
+type ClosingDecoder interface {
+	Decoder
+	Close() error
+}
+
+// CloseDecoder closes dec if it is a ClosingDecoder
+func CloseDecoder(dec Decoder) error {
+	if closer, ok := dec.(ClosingDecoder); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Encoder writes records to a stream. Close flushes buffered output and
+// writes any trailer but does not close the underlying writer.
+type Encoder interface {
+	Encode(data map[string]interface{}) error
+	Close() error
+}
+
+// Appendable reports whether streams encoded by c can be concatenated into
+// one valid stream, as sinks that append batches to a file need: JSON,
+// JSON lines, raw bytes, Protobuf and CSV without a header row can; Avro
+// containers and Parquet files cannot.
+func Appendable(c Codec) bool {
+	switch c := c.(type) {
+	case *JSONCodec, *JSONLinesCodec, *RawCodec, *ProtobufCodec:
+		return true
+	case *CSVCodec:
+		return !c.config.HasHeader
+	}
+	return false
+}
+
+// ByName returns a codec that needs no configuration: "json", "jsonl",
+// "csv" (with a header row), "parquet" (with an inferred schema) or "raw"
+func ByName(name string) (Codec, error) {
+	switch name {
+	case "json":
+		return NewJSON(), nil
+	case "jsonl", "ndjson":
+		return NewJSONLines(), nil
+	case "csv":
+		return NewCSV(CSVConfig{HasHeader: true})
+	case "parquet":
+		return NewParquet(ParquetConfig{})
+	case "raw":
+		return NewRaw(""), nil
+	default:
+		return nil, fmt.Errorf("unknown codec: %s", name)
+	}
+}
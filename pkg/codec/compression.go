@@ -0,0 +1,139 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms for files and objects
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	// CompressionAuto detects gzip and zstd from their magic bytes when
+	// reading
+	CompressionAuto = "auto"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress wraps r to decompress it. An empty compression means
+// CompressionAuto. Closing the result does not close r.
+func Decompress(r io.Reader, compression string) (io.ReadCloser, error) {
+	if compression == "" || compression == CompressionAuto {
+		br := bufio.NewReader(r)
+		magic, _ := br.Peek(len(zstdMagic))
+		switch {
+		case bytes.HasPrefix(magic, gzipMagic):
+			compression = CompressionGzip
+		case bytes.HasPrefix(magic, zstdMagic):
+			compression = CompressionZstd
+		default:
+			compression = CompressionNone
+		}
+		r = br
+	}
+
+	switch compression {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return zr, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+// Compress wraps w to compress what is written to it. Closing the result
+// flushes the compressed stream but does not close w.
+func Compress(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "", CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// CompressionExtension returns the file extension for a compression
+// algorithm, e.g. ".gz", or "" for none
+func CompressionExtension(compression string) string {
+	switch compression {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// ByExtension returns the codec for a file name from its extension, ignoring
+// a trailing compression extension: ".json", ".jsonl" or ".ndjson", ".csv"
+// (with a header row) and ".parquet"
+func ByExtension(name string) (Codec, error) {
+	ext := strings.ToLower(path.Ext(name))
+	switch ext {
+	case ".gz", ".gzip", ".zst", ".zstd":
+		ext = strings.ToLower(path.Ext(strings.TrimSuffix(name, path.Ext(name))))
+	}
+
+	switch ext {
+	case ".json":
+		return NewJSON(), nil
+	case ".jsonl", ".ndjson":
+		return NewJSONLines(), nil
+	case ".csv":
+		return NewCSV(CSVConfig{HasHeader: true})
+	case ".parquet":
+		return NewParquet(ParquetConfig{})
+	default:
+		return nil, fmt.Errorf("no codec for file extension %q", ext)
+	}
+}
+
+// Extension returns the file extension for files written with c, e.g.
+// ".jsonl", the inverse of ByExtension
+func Extension(c Codec) string {
+	switch c.Name() {
+	case "raw":
+		return ".bin"
+	case "protobuf":
+		return ".pb"
+	default:
+		return "." + c.Name()
+	}
+}
@@ -0,0 +1,198 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// CSVConfig configures a CSV codec
+type CSVConfig struct {
+	// Header names the columns. When empty, the first row of every stream is
+	// used and encoded columns are the sorted keys of the first record.
+	Header []string
+	// HasHeader reads the first row of a stream as the header and writes
+	// the header before the first encoded record
+	HasHeader bool
+	// Delimiter defaults to ','
+	Delimiter rune
+}
+
+// CSVCodec encodes records as CSV rows. Values decode as strings.
+type CSVCodec struct {
+	config CSVConfig
+}
+
+// NewCSV creates a CSV codec
+func NewCSV(config CSVConfig) (*CSVCodec, error) {
+	if config.Delimiter == 0 {
+		config.Delimiter = ','
+	}
+	if len(config.Header) == 0 && !config.HasHeader {
+		return nil, fmt.Errorf("csv codec requires a header or HasHeader")
+	}
+	return &CSVCodec{config: config}, nil
+}
+
+// Name implements Codec
+func (c *CSVCodec) Name() string { return "csv" }
+
+// ContentType implements Codec
+func (c *CSVCodec) ContentType() string { return "text/csv" }
+
+// Decode implements Codec. The payload holds one row, preceded by a header
+// row when HasHeader is set.
+func (c *CSVCodec) Decode(data []byte) (map[string]interface{}, error) {
+	dec, err := c.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	record, err := dec.Decode()
+	if err == io.EOF {
+		return nil, fmt.Errorf("failed to decode csv: no rows")
+	}
+	return record, err
+}
+
+// Encode implements Codec. The payload holds one row, preceded by a header
+// row when HasHeader is set, so Decode reads it back.
+func (c *CSVCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := c.newEncoder(&buf)
+	if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewDecoder implements Codec
+func (c *CSVCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = c.config.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &csvDecoder{reader: reader, header: c.config.Header, readHeader: c.config.HasHeader}, nil
+}
+
+// NewEncoder implements Codec
+func (c *CSVCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return c.newEncoder(w), nil
+}
+
+func (c *CSVCodec) newEncoder(w io.Writer) *csvEncoder {
+	writer := csv.NewWriter(w)
+	writer.Comma = c.config.Delimiter
+	return &csvEncoder{writer: writer, header: c.config.Header, writeHeader: c.config.HasHeader}
+}
+
+type csvDecoder struct {
+	reader     *csv.Reader
+	header     []string
+	readHeader bool
+	line       int
+}
+
+func (d *csvDecoder) Decode() (map[string]interface{}, error) {
+	if d.readHeader {
+		d.readHeader = false
+		row, err := d.reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read csv header: %w", err)
+		}
+		if len(d.header) == 0 {
+			d.header = append([]string(nil), row...)
+		}
+	}
+
+	row, err := d.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to decode csv: %w", err)
+	}
+	d.line, _ = d.reader.FieldPos(0)
+
+	if len(row) > len(d.header) {
+		return nil, fmt.Errorf("failed to decode csv on line %d: %d fields for %d columns", d.line, len(row), len(d.header))
+	}
+	record := make(map[string]interface{}, len(d.header))
+	for i, value := range row {
+		record[d.header[i]] = value
+	}
+	return record, nil
+}
+
+func (d *csvDecoder) Line() int {
+	return d.line
+}
+
+type csvEncoder struct {
+	writer      *csv.Writer
+	header      []string
+	writeHeader bool
+	row         []string
+}
+
+func (e *csvEncoder) Encode(data map[string]interface{}) error {
+	if len(e.header) == 0 {
+		for k := range data {
+			e.header = append(e.header, k)
+		}
+		sort.Strings(e.header)
+	}
+	if e.writeHeader {
+		e.writeHeader = false
+		if err := e.writer.Write(e.header); err != nil {
+			return fmt.Errorf("failed to write csv header: %w", err)
+		}
+	}
+
+	e.row = e.row[:0]
+	for _, column := range e.header {
+		e.row = append(e.row, csvValue(data[column]))
+	}
+	if err := e.writer.Write(e.row); err != nil {
+		return fmt.Errorf("failed to encode csv: %w", err)
+	}
+	return nil
+}
+
+func (e *csvEncoder) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// csvValue formats a field; nested values are written as JSON
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,155 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSONCodec encodes records as JSON objects. Streams may hold any sequence of
+// whitespace-separated objects.
+type JSONCodec struct{}
+
+// NewJSON creates a JSON codec
+func NewJSON() *JSONCodec {
+	return &JSONCodec{}
+}
+
+// Name implements Codec
+func (c *JSONCodec) Name() string { return "json" }
+
+// ContentType implements Codec
+func (c *JSONCodec) ContentType() string { return "application/json" }
+
+// Decode implements Codec
+func (c *JSONCodec) Decode(data []byte) (map[string]interface{}, error) {
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode json: %w", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("failed to decode json: not an object")
+	}
+	return record, nil
+}
+
+// Encode implements Codec
+func (c *JSONCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode json: %w", err)
+	}
+	return b, nil
+}
+
+// NewDecoder implements Codec
+func (c *JSONCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	return &jsonDecoder{dec: json.NewDecoder(r)}, nil
+}
+
+// NewEncoder implements Codec
+func (c *JSONCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return newLineEncoder(w), nil
+}
+
+type jsonDecoder struct {
+	dec *json.Decoder
+}
+
+func (d *jsonDecoder) Decode() (map[string]interface{}, error) {
+	var record map[string]interface{}
+	if err := d.dec.Decode(&record); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to decode json: %w", err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("failed to decode json: not an object")
+	}
+	return record, nil
+}
+
+// JSONLinesCodec encodes records as newline-delimited JSON objects
+type JSONLinesCodec struct {
+	JSONCodec
+}
+
+// NewJSONLines creates a JSON-lines codec
+func NewJSONLines() *JSONLinesCodec {
+	return &JSONLinesCodec{}
+}
+
+// Name implements Codec
+func (c *JSONLinesCodec) Name() string { return "jsonl" }
+
+// ContentType implements Codec
+func (c *JSONLinesCodec) ContentType() string { return "application/x-ndjson" }
+
+// NewDecoder implements Codec. Blank lines are skipped and errors report the
+// offending line number.
+func (c *JSONLinesCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &lineDecoder{scanner: scanner}, nil
+}
+
+// maxLineSize bounds a single JSON-lines record
+const maxLineSize = 16 * 1024 * 1024
+
+type lineDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (d *lineDecoder) Decode() (map[string]interface{}, error) {
+	for d.scanner.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode json on line %d: %w", d.line, err)
+		}
+		if record == nil {
+			return nil, fmt.Errorf("failed to decode json on line %d: not an object", d.line)
+		}
+		return record, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read line %d: %w", d.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+// Line returns the line number of the last decoded record
+func (d *lineDecoder) Line() int {
+	return d.line
+}
+
+// lineEncoder writes one JSON object per line
+type lineEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newLineEncoder(w io.Writer) *lineEncoder {
+	buf := bufio.NewWriter(w)
+	return &lineEncoder{w: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *lineEncoder) Encode(data map[string]interface{}) error {
+	if err := e.enc.Encode(data); err != nil {
+		return fmt.Errorf("failed to encode json: %w", err)
+	}
+	return nil
+}
+
+func (e *lineEncoder) Close() error {
+	return e.w.Flush()
+}
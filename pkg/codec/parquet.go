@@ -0,0 +1,562 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/schema"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/compress/brotli"
+	"github.com/parquet-go/parquet-go/compress/gzip"
+	"github.com/parquet-go/parquet-go/compress/lz4"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+	"github.com/parquet-go/parquet-go/compress/uncompressed"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// ParquetConfig configures a Parquet codec
+type ParquetConfig struct {
+	// Schema declares the columns to write. Records are coerced to it,
+	// and fields it does not declare are dropped. Without a schema, one is
+	// inferred with schema.Infer from the records of the first row group,
+	// and later records with fields it lacks, or with values that cannot
+	// be coerced to its types, fail to encode; declare a schema when the
+	// fields vary across a file.
+	//
+	// Objects with Fields become groups and arrays repeated columns. Any
+	// fields, objects without Fields and arrays of arrays or nullable
+	// items are written as JSON columns. Required fields that are not
+	// Nullable are required columns; all others are optional.
+	Schema *schema.Schema
+	// Compression is the column codec: "snappy" (default), "gzip",
+	// "zstd", "lz4", "brotli" or "none"
+	Compression string
+	// RowGroupSize is the number of rows per row group; defaults to
+	// 100000. A row group is buffered in memory until it is complete.
+	RowGroupSize int64
+	// Columns projects reads onto these columns, e.g. "id" or
+	// "address.city" for a nested one; every column is read by default.
+	// Other columns are not decoded.
+	Columns []string
+}
+
+// ParquetCodec reads and writes Apache Parquet files. Parquet is a file
+// format, so Encode and Decode work on whole files of one record; streams
+// are what the codec is for. Decoders need random access to the file's
+// footer: a stream that is not an io.ReaderAt and io.Seeker, such as an
+// object body, is first copied to a temporary file, removed when the
+// decoder reaches io.EOF or is closed; see ClosingDecoder.
+type ParquetCodec struct {
+	config ParquetConfig
+	codec  compress.Codec
+}
+
+// NewParquet creates a Parquet codec
+func NewParquet(config ParquetConfig) (*ParquetCodec, error) {
+	codec, err := parquetCompression(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if config.Schema != nil {
+		if err := config.Schema.Check(); err != nil {
+			return nil, fmt.Errorf("invalid parquet schema: %w", err)
+		}
+	}
+	if config.RowGroupSize <= 0 {
+		config.RowGroupSize = 100000
+	}
+	return &ParquetCodec{config: config, codec: codec}, nil
+}
+
+func parquetCompression(name string) (compress.Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "snappy":
+		return &snappy.Codec{}, nil
+	case "gzip":
+		return &gzip.Codec{}, nil
+	case "zstd":
+		return &zstd.Codec{}, nil
+	case "lz4":
+		return &lz4.Codec{}, nil
+	case "brotli":
+		return &brotli.Codec{}, nil
+	case "none", "uncompressed":
+		return &uncompressed.Codec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression: %s", name)
+	}
+}
+
+// Name implements Codec
+func (c *ParquetCodec) Name() string { return "parquet" }
+
+// ContentType implements Codec
+func (c *ParquetCodec) ContentType() string { return "application/vnd.apache.parquet" }
+
+// Decode implements Codec for a file holding one record
+func (c *ParquetCodec) Decode(data []byte) (map[string]interface{}, error) {
+	dec, err := c.newDecoder(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	if n := dec.file.NumRows(); n != 1 {
+		return nil, fmt.Errorf("failed to decode parquet: expected one record, found %d", n)
+	}
+	return dec.Decode()
+}
+
+// Encode implements Codec by writing a file holding one record
+func (c *ParquetCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc, err := c.NewEncoder(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewDecoder implements Codec
+func (c *ParquetCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	if seeker, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		size, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to size parquet file: %w", err)
+		}
+		return c.newDecoder(seeker, size, nil)
+	}
+
+	spool, err := os.CreateTemp("", "datapipe-parquet-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer parquet file: %w", err)
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to buffer parquet file: %w", err)
+	}
+	// Where open files can be removed, nothing is left behind if the
+	// decoder is abandoned
+	if os.Remove(spool.Name()) == nil {
+		cleanup = func() { spool.Close() }
+	}
+	dec, err := c.newDecoder(spool, size, cleanup)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return dec, nil
+}
+
+func (c *ParquetCodec) newDecoder(r io.ReaderAt, size int64, cleanup func()) (*parquetDecoder, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open parquet file: %w", err)
+	}
+
+	readSchema := file.Schema()
+	if len(c.config.Columns) > 0 {
+		if readSchema, err = projectSchema(readSchema, c.config.Columns); err != nil {
+			return nil, err
+		}
+	}
+	return &parquetDecoder{
+		file:       file,
+		reader:     parquet.NewReader(file, readSchema),
+		schema:     readSchema,
+		timestamps: hasTimestamps(readSchema),
+		cleanup:    cleanup,
+	}, nil
+}
+
+// NewEncoder implements Codec
+func (c *ParquetCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	enc := &parquetEncoder{codec: c, w: w}
+	if c.config.Schema != nil {
+		enc.open(c.config.Schema)
+	}
+	return enc, nil
+}
+
+type parquetDecoder struct {
+	file   *parquet.File
+	reader *parquet.Reader
+	schema *parquet.Schema
+	// timestamps is set if any column holds timestamps, which are read as
+	// integers and converted to time.Time
+	timestamps bool
+	cleanup    func()
+}
+
+func (d *parquetDecoder) Decode() (map[string]interface{}, error) {
+	record := make(map[string]interface{})
+	if err := d.reader.Read(&record); err != nil {
+		if err == io.EOF {
+			d.Close()
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to decode parquet: %w", err)
+	}
+	if d.timestamps {
+		convertTimestamps(d.schema, record)
+	}
+	return record, nil
+}
+
+// Close implements ClosingDecoder by removing the temporary copy of a
+// streamed file
+func (d *parquetDecoder) Close() error {
+	if d.cleanup != nil {
+		d.cleanup()
+		d.cleanup = nil
+	}
+	return nil
+}
+
+type parquetEncoder struct {
+	codec  *ParquetCodec
+	w      io.Writer
+	schema *schema.Schema
+	writer *parquet.Writer
+	// pending buffers the first row group while the schema is inferred
+	pending []map[string]interface{}
+}
+
+func (e *parquetEncoder) open(s *schema.Schema) {
+	e.schema = s
+	name := s.Name
+	if name == "" {
+		name = "record"
+	}
+	e.writer = parquet.NewWriter(e.w,
+		parquet.NewSchema(name, parquetGroup(s.Fields)),
+		parquet.Compression(e.codec.codec),
+		parquet.MaxRowsPerRowGroup(e.codec.config.RowGroupSize),
+	)
+}
+
+func (e *parquetEncoder) Encode(data map[string]interface{}) error {
+	if e.writer == nil {
+		e.pending = append(e.pending, data)
+		if int64(len(e.pending)) < e.codec.config.RowGroupSize {
+			return nil
+		}
+		return e.flushPending()
+	}
+	return e.write(data)
+}
+
+// flushPending infers the schema from the buffered records and writes them.
+// The inferred schema is strict, so a later field is reported rather than
+// dropped.
+func (e *parquetEncoder) flushPending() error {
+	inferred := schema.Infer("record", e.pending...)
+	inferred.Strict = true
+	strictFields(inferred.Fields)
+	e.open(inferred)
+	pending := e.pending
+	e.pending = nil
+	for _, data := range pending {
+		if err := e.write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *parquetEncoder) write(data map[string]interface{}) error {
+	coerced, err := e.schema.Validate(data, schema.Options{Coerce: true})
+	if err != nil {
+		return fmt.Errorf("failed to encode parquet: %w", err)
+	}
+	row, err := parquetObject(e.schema.Fields, coerced)
+	if err != nil {
+		return fmt.Errorf("failed to encode parquet: %w", err)
+	}
+	if err := e.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to encode parquet: %w", err)
+	}
+	return nil
+}
+
+// Close writes the last row group and the footer. Nothing is written when
+// no schema was declared and no records were encoded.
+func (e *parquetEncoder) Close() error {
+	if e.writer == nil {
+		if len(e.pending) == 0 {
+			return nil
+		}
+		if err := e.flushPending(); err != nil {
+			return err
+		}
+	}
+	if err := e.writer.Close(); err != nil {
+		return fmt.Errorf("failed to write parquet file: %w", err)
+	}
+	return nil
+}
+
+// strictFields makes the objects written as groups reject undeclared
+// members; objects written as JSON keep them all
+func strictFields(fields []schema.Field) {
+	for i := range fields {
+		f := &fields[i]
+		if f.Type == schema.Array && f.Items != nil {
+			f = f.Items
+		}
+		if f.Type == schema.Object && len(f.Fields) > 0 {
+			f.Strict = true
+			strictFields(f.Fields)
+		}
+	}
+}
+
+// jsonColumn reports whether a field is written as a JSON column
+func jsonColumn(f schema.Field) bool {
+	switch f.Type {
+	case schema.Any:
+		return true
+	case schema.Object:
+		return len(f.Fields) == 0
+	}
+	return false
+}
+
+// jsonElement reports whether the items of an array are written as JSON
+func jsonElement(items *schema.Field) bool {
+	return items == nil || items.Nullable || items.Type == schema.Array || jsonColumn(*items)
+}
+
+func parquetGroup(fields []schema.Field) parquet.Group {
+	group := make(parquet.Group, len(fields))
+	for _, f := range fields {
+		group[f.Name] = parquetNode(f)
+	}
+	return group
+}
+
+func parquetNode(f schema.Field) parquet.Node {
+	if f.Type == schema.Array {
+		if jsonElement(f.Items) {
+			return parquet.Repeated(parquet.JSON())
+		}
+		return parquet.Repeated(parquetType(*f.Items))
+	}
+	node := parquetType(f)
+	if !f.Required || f.Nullable {
+		node = parquet.Optional(node)
+	}
+	return node
+}
+
+// parquetType maps a field to a column type, without its repetition
+func parquetType(f schema.Field) parquet.Node {
+	if jsonColumn(f) {
+		return parquet.JSON()
+	}
+	switch f.Type {
+	case schema.String:
+		return parquet.String()
+	case schema.Integer:
+		return parquet.Int(64)
+	case schema.Number:
+		return parquet.Leaf(parquet.DoubleType)
+	case schema.Boolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case schema.Timestamp:
+		return parquet.Timestamp(parquet.Microsecond)
+	default:
+		return parquetGroup(f.Fields)
+	}
+}
+
+// parquetObject converts validated data to the row the schema describes:
+// JSON columns are marshalled and undeclared fields dropped
+func parquetObject(fields []schema.Field, data map[string]interface{}) (map[string]interface{}, error) {
+	row := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		val, ok := data[f.Name]
+		if !ok || val == nil {
+			continue
+		}
+		converted, err := parquetValue(f, val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		row[f.Name] = converted
+	}
+	return row, nil
+}
+
+func parquetValue(f schema.Field, val interface{}) (interface{}, error) {
+	if jsonColumn(f) {
+		return marshalJSON(val)
+	}
+	switch f.Type {
+	case schema.Object:
+		return parquetObject(f.Fields, val.(map[string]interface{}))
+	case schema.Array:
+		items := val.([]interface{})
+		out := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			var converted interface{}
+			var err error
+			if jsonElement(f.Items) {
+				converted, err = marshalJSON(item)
+			} else {
+				converted, err = parquetValue(*f.Items, item)
+			}
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, converted)
+		}
+		return out, nil
+	}
+	return val, nil
+}
+
+func marshalJSON(val interface{}) (string, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func timestampUnit(node parquet.Node) (time.Duration, bool) {
+	if !node.Leaf() {
+		return 0, false
+	}
+	logical := node.Type().LogicalType()
+	if logical == nil || logical.Timestamp == nil {
+		return 0, false
+	}
+	switch unit := logical.Timestamp.Unit; {
+	case unit.Millis != nil:
+		return time.Millisecond, true
+	case unit.Nanos != nil:
+		return time.Nanosecond, true
+	default:
+		return time.Microsecond, true
+	}
+}
+
+func hasTimestamps(node parquet.Node) bool {
+	if _, ok := timestampUnit(node); ok {
+		return true
+	}
+	for _, f := range node.Fields() {
+		if hasTimestamps(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertTimestamps replaces the integers read from timestamp columns of
+// a group with UTC time.Time values
+func convertTimestamps(node parquet.Node, record map[string]interface{}) {
+	for _, f := range node.Fields() {
+		val, ok := record[f.Name()]
+		if !ok || val == nil || !hasTimestamps(f) {
+			continue
+		}
+		if items, ok := val.([]interface{}); ok && f.Repeated() {
+			for i, item := range items {
+				items[i] = convertTimestamp(f, item)
+			}
+			continue
+		}
+		record[f.Name()] = convertTimestamp(f, val)
+	}
+}
+
+func convertTimestamp(node parquet.Node, val interface{}) interface{} {
+	if unit, ok := timestampUnit(node); ok {
+		if n, ok := val.(int64); ok {
+			return time.Unix(0, n*int64(unit)).UTC()
+		}
+		return val
+	}
+	if group, ok := val.(map[string]interface{}); ok {
+		convertTimestamps(node, group)
+	}
+	return val
+}
+
+// projectSchema keeps the columns named by dotted paths, with the groups
+// that hold them
+func projectSchema(s *parquet.Schema, columns []string) (*parquet.Schema, error) {
+	paths := make([][]string, len(columns))
+	for i, column := range columns {
+		paths[i] = strings.Split(column, ".")
+	}
+	group, err := projectGroup(s, paths, "")
+	if err != nil {
+		return nil, err
+	}
+	return parquet.NewSchema(s.Name(), group), nil
+}
+
+func projectGroup(node parquet.Node, paths [][]string, prefix string) (parquet.Group, error) {
+	fields := make(map[string]parquet.Field)
+	for _, f := range node.Fields() {
+		fields[f.Name()] = f
+	}
+
+	group := make(parquet.Group)
+	nested := make(map[string][][]string)
+	for _, p := range paths {
+		f, ok := fields[p[0]]
+		if !ok {
+			return nil, fmt.Errorf("parquet file has no column %q", prefix+strings.Join(p, "."))
+		}
+		if len(p) == 1 {
+			group[p[0]] = f
+			continue
+		}
+		if f.Leaf() {
+			return nil, fmt.Errorf("parquet column %q has no nested columns", prefix+p[0])
+		}
+		nested[p[0]] = append(nested[p[0]], p[1:])
+	}
+
+	for name, subpaths := range nested {
+		if _, whole := group[name]; whole {
+			continue
+		}
+		f := fields[name]
+		sub, err := projectGroup(f, subpaths, prefix+name+".")
+		if err != nil {
+			return nil, err
+		}
+		var projected parquet.Node = sub
+		switch {
+		case f.Optional():
+			projected = parquet.Optional(projected)
+		case f.Repeated():
+			projected = parquet.Repeated(projected)
+		}
+		group[name] = projected
+	}
+	return group, nil
+}
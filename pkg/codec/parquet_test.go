@@ -0,0 +1,223 @@
+package codec_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/schema"
+)
+
+var (
+	created = time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	login   = time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+)
+
+// userSchema covers groups, repeated leaves, repeated groups, timestamps
+// at every level and a JSON column
+var userSchema = schema.New("user",
+	schema.Field{Name: "id", Type: schema.Integer, Required: true},
+	schema.Field{Name: "name", Type: schema.String},
+	schema.Field{Name: "created", Type: schema.Timestamp, Required: true},
+	schema.Field{Name: "address", Type: schema.Object, Fields: []schema.Field{
+		{Name: "city", Type: schema.String},
+		{Name: "zip", Type: schema.String},
+	}},
+	schema.Field{Name: "tags", Type: schema.Array, Items: &schema.Field{Type: schema.String}},
+	schema.Field{Name: "events", Type: schema.Array, Items: &schema.Field{Type: schema.Object, Fields: []schema.Field{
+		{Name: "at", Type: schema.Timestamp},
+		{Name: "kind", Type: schema.String},
+	}}},
+	schema.Field{Name: "extra", Type: schema.Any},
+)
+
+func userRecords() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"id":      1,
+			"name":    "ada",
+			"created": created,
+			"address": map[string]interface{}{"city": "London", "zip": "N1"},
+			"tags":    []interface{}{"admin", "ops"},
+			"events": []interface{}{
+				map[string]interface{}{"at": login, "kind": "login"},
+			},
+			"extra": map[string]interface{}{"score": 7},
+		},
+		{
+			// Coerced to the declared types
+			"id":      "2",
+			"created": created.Format(time.RFC3339Nano),
+			"address": map[string]interface{}{"city": "Paris"},
+		},
+	}
+}
+
+func newParquet(t *testing.T, config codec.ParquetConfig) *codec.ParquetCodec {
+	t.Helper()
+	c, err := codec.NewParquet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func encodeAll(t *testing.T, c codec.Codec, records []map[string]interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := c.NewEncoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeAll(t *testing.T, c codec.Codec, r io.Reader) []map[string]interface{} {
+	t.Helper()
+	dec, err := c.NewDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]interface{}
+	for {
+		record, err := dec.Decode()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	c := newParquet(t, codec.ParquetConfig{Schema: userSchema})
+	data := encodeAll(t, c, userRecords())
+
+	got := decodeAll(t, c, bytes.NewReader(data))
+	want := []map[string]interface{}{
+		{
+			"id":      int64(1),
+			"name":    "ada",
+			"created": created,
+			"address": map[string]interface{}{"city": "London", "zip": "N1"},
+			"tags":    []interface{}{"admin", "ops"},
+			"events": []interface{}{
+				map[string]interface{}{"at": login, "kind": "login"},
+			},
+			"extra": map[string]interface{}{"score": 7.0},
+		},
+		{
+			"id":      int64(2),
+			"name":    nil,
+			"created": created,
+			"address": map[string]interface{}{"city": "Paris", "zip": nil},
+			"tags":    []interface{}{},
+			"events":  []interface{}{},
+			"extra":   nil,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParquetStreamedDecode(t *testing.T) {
+	c := newParquet(t, codec.ParquetConfig{Schema: userSchema})
+	data := encodeAll(t, c, userRecords())
+
+	// A reader without ReadAt is spooled to a temporary file
+	got := decodeAll(t, c, io.MultiReader(bytes.NewReader(data)))
+	if len(got) != 2 || got[0]["id"] != int64(1) || got[1]["id"] != int64(2) {
+		t.Errorf("streamed decode = %v", got)
+	}
+}
+
+func TestParquetProjection(t *testing.T) {
+	data := encodeAll(t, newParquet(t, codec.ParquetConfig{Schema: userSchema}), userRecords())
+
+	c := newParquet(t, codec.ParquetConfig{Columns: []string{"id", "address.city", "events.at"}})
+	got := decodeAll(t, c, bytes.NewReader(data))
+	want := []map[string]interface{}{
+		{
+			"id":      int64(1),
+			"address": map[string]interface{}{"city": "London"},
+			"events": []interface{}{
+				map[string]interface{}{"at": login},
+			},
+		},
+		{
+			"id":      int64(2),
+			"address": map[string]interface{}{"city": "Paris"},
+			"events":  []interface{}{},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("projection:\n got %#v\nwant %#v", got, want)
+	}
+
+	c = newParquet(t, codec.ParquetConfig{Columns: []string{"address.country"}})
+	if _, err := c.NewDecoder(bytes.NewReader(data)); err == nil {
+		t.Error("projecting a missing column succeeded")
+	}
+}
+
+func TestParquetInferredSchema(t *testing.T) {
+	c := newParquet(t, codec.ParquetConfig{RowGroupSize: 2})
+	records := []map[string]interface{}{
+		{"id": 1, "at": created, "address": map[string]interface{}{"city": "London"}},
+		{"id": 2, "at": login, "address": map[string]interface{}{"city": "Paris"}},
+		{"id": 3, "at": login, "address": map[string]interface{}{"city": "Rome"}},
+	}
+	got := decodeAll(t, c, bytes.NewReader(encodeAll(t, c, records)))
+	if len(got) != 3 || got[0]["at"] != created || got[2]["address"].(map[string]interface{})["city"] != "Rome" {
+		t.Errorf("inferred round trip = %v", got)
+	}
+
+	// Fields outside the schema inferred from the first row group fail
+	// rather than being dropped
+	later := []map[string]interface{}{
+		{"id": 3, "at": login, "address": map[string]interface{}{"city": "Rome"}, "email": "x@example.com"},
+		{"id": 3, "at": login, "address": map[string]interface{}{"city": "Rome", "zip": "00100"}},
+		{"id": "three", "at": login, "address": map[string]interface{}{"city": "Rome"}},
+	}
+	for _, record := range later {
+		enc, err := c.NewEncoder(io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, first := range records[:2] {
+			if err := enc.Encode(first); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = enc.Encode(record)
+		var verr *schema.ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("Encode(%v) = %v, want a validation error", record, err)
+		}
+	}
+}
+
+func TestParquetByExtension(t *testing.T) {
+	c, err := codec.ByExtension("part-0001.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name() != "parquet" {
+		t.Errorf("ByExtension(.parquet) = %s, want parquet", c.Name())
+	}
+}
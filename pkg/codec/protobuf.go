@@ -0,0 +1,255 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufConfig configures a Protobuf codec
+type ProtobufConfig struct {
+	// DescriptorSet is a serialized FileDescriptorSet, as written by
+	// protoc --include_imports --descriptor_set_out
+	DescriptorSet []byte
+	// DescriptorSetFile is read when DescriptorSet is empty
+	DescriptorSetFile string
+	// MessageName is the fully qualified message type, e.g. "acme.v1.Order"
+	MessageName string
+	// ConfluentWireFormat prefixes payloads with the magic byte, schema ID
+	// and message index path
+	ConfluentWireFormat bool
+	// SchemaID is written into encoded payloads in the Confluent wire format
+	SchemaID int
+	// SchemaText is the .proto source registered with a schema registry
+	SchemaText string
+}
+
+// ProtobufCodec encodes records as Protobuf messages of a single type.
+// Fields map to Record.Data by their proto names, following the protobuf
+// JSON mapping. Streams hold varint length-delimited messages.
+type ProtobufCodec struct {
+	config  ProtobufConfig
+	message protoreflect.MessageDescriptor
+	indexes []int
+}
+
+// NewProtobuf creates a Protobuf codec
+func NewProtobuf(config ProtobufConfig) (*ProtobufCodec, error) {
+	if config.MessageName == "" {
+		return nil, fmt.Errorf("protobuf codec requires a message name")
+	}
+
+	raw := config.DescriptorSet
+	if len(raw) == 0 {
+		if config.DescriptorSetFile == "" {
+			return nil, fmt.Errorf("protobuf codec requires a descriptor set")
+		}
+		b, err := os.ReadFile(config.DescriptorSetFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+		}
+		raw = b
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(config.MessageName))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found: %w", config.MessageName, err)
+	}
+	message, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", config.MessageName)
+	}
+
+	return &ProtobufCodec{
+		config:  config,
+		message: message,
+		indexes: messageIndexes(message),
+	}, nil
+}
+
+// messageIndexes returns the position of a message within its file, the
+// path the Confluent wire format uses to identify nested types
+func messageIndexes(message protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	var d protoreflect.Descriptor = message
+	for {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		indexes = append([]int{d.Index()}, indexes...)
+		d = d.Parent()
+	}
+	return indexes
+}
+
+// Name implements Codec
+func (c *ProtobufCodec) Name() string { return "protobuf" }
+
+// ContentType implements Codec
+func (c *ProtobufCodec) ContentType() string { return "application/x-protobuf" }
+
+// Decode implements Codec. Confluent payloads are always decoded as the
+// configured message type.
+func (c *ProtobufCodec) Decode(data []byte) (map[string]interface{}, error) {
+	if c.config.ConfluentWireFormat {
+		_, body, err := SplitConfluent(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode protobuf: %w", err)
+		}
+		if data, err = skipMessageIndexes(body); err != nil {
+			return nil, fmt.Errorf("failed to decode protobuf: %w", err)
+		}
+	}
+
+	msg := dynamicpb.NewMessage(c.message)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("failed to decode protobuf: %w", err)
+	}
+	return c.toMap(msg)
+}
+
+// Encode implements Codec
+func (c *ProtobufCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	msg, err := c.fromMap(data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode protobuf: %w", err)
+	}
+	if !c.config.ConfluentWireFormat {
+		return body, nil
+	}
+
+	out := ConfluentHeader(c.config.SchemaID)
+	// The common case of the first message in the file is a single zero
+	if len(c.indexes) == 1 && c.indexes[0] == 0 {
+		out = append(out, 0)
+	} else {
+		out = protowire.AppendVarint(out, protowire.EncodeZigZag(int64(len(c.indexes))))
+		for _, i := range c.indexes {
+			out = protowire.AppendVarint(out, protowire.EncodeZigZag(int64(i)))
+		}
+	}
+	return append(out, body...), nil
+}
+
+// SchemaType implements RegistryCodec
+func (c *ProtobufCodec) SchemaType() string { return "PROTOBUF" }
+
+// Schema implements RegistryCodec
+func (c *ProtobufCodec) Schema() string { return c.config.SchemaText }
+
+// UseRegistry implements RegistryCodec. Payloads are always decoded as the
+// configured message type, so the resolver is not consulted.
+func (c *ProtobufCodec) UseRegistry(id int, resolver SchemaResolver) {
+	c.config.ConfluentWireFormat = true
+	c.config.SchemaID = id
+}
+
+// skipMessageIndexes drops the message index path after the schema ID
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	count, n := protowire.ConsumeVarint(data)
+	if n < 0 {
+		return nil, fmt.Errorf("invalid message index count")
+	}
+	data = data[n:]
+	for i := int64(0); i < protowire.DecodeZigZag(count); i++ {
+		_, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid message index")
+		}
+		data = data[n:]
+	}
+	return data, nil
+}
+
+func (c *ProtobufCodec) toMap(msg proto.Message) (map[string]interface{}, error) {
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode protobuf: %w", err)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode protobuf: %w", err)
+	}
+	return record, nil
+}
+
+func (c *ProtobufCodec) fromMap(data map[string]interface{}) (*dynamicpb.Message, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode protobuf: %w", err)
+	}
+	msg := dynamicpb.NewMessage(c.message)
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, msg); err != nil {
+		return nil, fmt.Errorf("failed to encode protobuf: %w", err)
+	}
+	return msg, nil
+}
+
+// NewDecoder implements Codec
+func (c *ProtobufCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	return &protobufDecoder{codec: c, r: bufio.NewReader(r)}, nil
+}
+
+// NewEncoder implements Codec
+func (c *ProtobufCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return &protobufEncoder{codec: c, w: bufio.NewWriter(w)}, nil
+}
+
+type protobufDecoder struct {
+	codec *ProtobufCodec
+	r     *bufio.Reader
+}
+
+func (d *protobufDecoder) Decode() (map[string]interface{}, error) {
+	msg := dynamicpb.NewMessage(d.codec.message)
+	if err := protodelim.UnmarshalFrom(d.r, msg); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to decode protobuf: %w", err)
+	}
+	return d.codec.toMap(msg)
+}
+
+type protobufEncoder struct {
+	codec *ProtobufCodec
+	w     *bufio.Writer
+}
+
+func (e *protobufEncoder) Encode(data map[string]interface{}) error {
+	msg, err := e.codec.fromMap(data)
+	if err != nil {
+		return err
+	}
+	if _, err := protodelim.MarshalTo(e.w, msg); err != nil {
+		return fmt.Errorf("failed to encode protobuf: %w", err)
+	}
+	return nil
+}
+
+func (e *protobufEncoder) Close() error {
+	return e.w.Flush()
+}
@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+)
+
+// RawCodec passes payloads through unchanged under a single Record.Data field
+type RawCodec struct {
+	field string
+}
+
+// NewRaw creates a raw codec storing payloads in field, "value" by default
+func NewRaw(field string) *RawCodec {
+	if field == "" {
+		field = "value"
+	}
+	return &RawCodec{field: field}
+}
+
+// Name implements Codec
+func (c *RawCodec) Name() string { return "raw" }
+
+// ContentType implements Codec
+func (c *RawCodec) ContentType() string { return "application/octet-stream" }
+
+// Decode implements Codec
+func (c *RawCodec) Decode(data []byte) (map[string]interface{}, error) {
+	return map[string]interface{}{c.field: append([]byte(nil), data...)}, nil
+}
+
+// Encode implements Codec. The field must hold a []byte or a string.
+func (c *RawCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	switch v := data[c.field].(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case nil:
+		return nil, fmt.Errorf("raw codec: field %q is missing", c.field)
+	default:
+		return nil, fmt.Errorf("raw codec: field %q has type %T, want []byte or string", c.field, v)
+	}
+}
+
+// NewDecoder implements Codec. The whole stream is decoded as one record.
+func (c *RawCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	return &rawDecoder{codec: c, r: r}, nil
+}
+
+// NewEncoder implements Codec. Payloads are concatenated without framing.
+func (c *RawCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return &rawEncoder{codec: c, w: w}, nil
+}
+
+type rawDecoder struct {
+	codec *RawCodec
+	r     io.Reader
+	done  bool
+}
+
+func (d *rawDecoder) Decode() (map[string]interface{}, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	data, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	return map[string]interface{}{d.codec.field: data}, nil
+}
+
+type rawEncoder struct {
+	codec *RawCodec
+	w     io.Writer
+}
+
+func (e *rawEncoder) Encode(data map[string]interface{}) error {
+	payload, err := e.codec.Encode(data)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(payload)
+	return err
+}
+
+func (e *rawEncoder) Close() error {
+	return nil
+}
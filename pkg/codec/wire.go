@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// confluentMagic starts every payload in the Confluent wire format, followed
+// by a 4-byte big-endian schema ID
+const confluentMagic byte = 0
+
+// SchemaResolver returns the schema registered under a schema registry ID
+type SchemaResolver func(id int) (string, error)
+
+// ConfluentHeader returns the wire format prefix for a schema ID
+func ConfluentHeader(id int) []byte {
+	header := make([]byte, 5)
+	header[0] = confluentMagic
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return header
+}
+
+// SplitConfluent separates a Confluent wire format payload into its schema
+// ID and body
+func SplitConfluent(data []byte) (int, []byte, error) {
+	if len(data) < 5 {
+		return 0, nil, fmt.Errorf("payload of %d bytes is too short for the confluent wire format", len(data))
+	}
+	if data[0] != confluentMagic {
+		return 0, nil, fmt.Errorf("unknown magic byte %d", data[0])
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
+
+// RegistryCodec is implemented by codecs whose schemas can be stored in a
+// Confluent-compatible schema registry
+type RegistryCodec interface {
+	Codec
+	// SchemaType is the registry schema type, "AVRO" or "PROTOBUF"
+	SchemaType() string
+	// Schema is the schema text to register
+	Schema() string
+	// UseRegistry switches the codec to the Confluent wire format. Encoded
+	// payloads carry id, and decoded payloads are resolved through resolver.
+	UseRegistry(id int, resolver SchemaResolver)
+}
@@ -1,10 +1,18 @@
 package cloud
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
 )
 
@@ -19,6 +27,13 @@ type S3Config struct {
 	Bucket          string
 	AccessKeyID     string
 	SecretAccessKey string
+	Endpoint        string // Optional, for S3-compatible stores such as MinIO
+	// ForcePathStyle addresses buckets as endpoint/bucket instead of
+	// bucket.endpoint, which most S3-compatible stores require
+	ForcePathStyle bool
+	// PartSize is the multipart upload part size used by Create; defaults
+	// to the SDK's 5 MiB, the smallest part S3 accepts
+	PartSize int64
 }
 
 func NewS3Connector(config S3Config) *S3Connector {
@@ -34,20 +49,31 @@ func NewS3Connector(config S3Config) *S3Connector {
 }
 
 func (s *S3Connector) Connect() error {
-	sess, err := session.NewSession(&aws.Config{
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	s.client = s3.New(sess)
+	return nil
+}
+
+func (s *S3Connector) session() (*session.Session, error) {
+	config := &aws.Config{
 		Region: aws.String(s.Config.Region),
 		Credentials: credentials.NewStaticCredentials(
 			s.Config.AccessKeyID,
 			s.Config.SecretAccessKey,
 			"",
 		),
-	})
-	if err != nil {
-		return err
 	}
-
-	s.client = s3.New(sess)
-	return nil
+	if s.Config.Endpoint != "" {
+		config.Endpoint = aws.String(s.Config.Endpoint)
+	}
+	if s.Config.ForcePathStyle {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	return session.NewSession(config)
 }
 
 func (s *S3Connector) Disconnect() error {
@@ -81,3 +107,142 @@ func (s *S3Connector) ListObjects(prefix string) (*s3.ListObjectsV2Output, error
 	}
 	return s.client.ListObjectsV2(input)
 }
+
+// Client returns the underlying S3 client
+func (s *S3Connector) Client() *s3.S3 {
+	return s.client
+}
+
+// Upload writes an object, switching to a multipart upload in parts of
+// partSize bytes when the body is larger than one part. A partSize of zero
+// uses the SDK default of 5 MiB, the smallest part S3 accepts.
+func (s *S3Connector) Upload(ctx context.Context, key string, body io.Reader, contentType string, partSize int64) error {
+	uploader := s3manager.NewUploaderWithClient(s.client, func(u *s3manager.Uploader) {
+		if partSize > 0 {
+			u.PartSize = partSize
+		}
+	})
+
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	_, err := uploader.UploadWithContext(ctx, input)
+	return err
+}
+
+// List implements connectors.ObjectStore
+func (s *S3Connector) List(ctx context.Context, options connectors.ListOptions, fn func(page []connectors.ObjectInfo) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Config.Bucket),
+		Prefix: aws.String(options.Prefix),
+	}
+	if options.StartAfter != "" {
+		input.StartAfter = aws.String(options.StartAfter)
+	}
+	if options.PageSize > 0 {
+		input.MaxKeys = aws.Int64(int64(options.PageSize))
+	}
+
+	var fnErr error
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(output *s3.ListObjectsV2Output, lastPage bool) bool {
+		page := make([]connectors.ObjectInfo, 0, len(output.Contents))
+		for _, obj := range output.Contents {
+			key := aws.StringValue(obj.Key)
+			// Skip the empty objects consoles create as folders
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			page = append(page, connectors.ObjectInfo{
+				Key:     key,
+				Size:    aws.Int64Value(obj.Size),
+				ModTime: aws.TimeValue(obj.LastModified),
+				ETag:    aws.StringValue(obj.ETag),
+			})
+		}
+		if len(page) == 0 {
+			return true
+		}
+		fnErr = fn(page)
+		return fnErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// Open implements connectors.ObjectStore
+func (s *S3Connector) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	result, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, s3Error(key, err)
+	}
+	return result.Body, nil
+}
+
+// Create implements connectors.ObjectStore. The object is streamed with a
+// multipart upload in parts of Config.PartSize.
+func (s *S3Connector) Create(ctx context.Context, key string, options connectors.WriteOptions) (io.WriteCloser, error) {
+	return newPipeWriter(func(body io.Reader) error {
+		return s.Upload(ctx, key, body, options.ContentType, s.Config.PartSize)
+	}), nil
+}
+
+// Stat implements connectors.ObjectStore
+func (s *S3Connector) Stat(ctx context.Context, key string) (connectors.ObjectInfo, error) {
+	result, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return connectors.ObjectInfo{}, s3Error(key, err)
+	}
+	return connectors.ObjectInfo{
+		Key:         key,
+		Size:        aws.Int64Value(result.ContentLength),
+		ModTime:     aws.TimeValue(result.LastModified),
+		ETag:        aws.StringValue(result.ETag),
+		ContentType: aws.StringValue(result.ContentType),
+	}, nil
+}
+
+// Delete implements connectors.ObjectStore
+func (s *S3Connector) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// Copy implements connectors.ObjectStore with a server-side copy, which S3
+// limits to objects of up to 5 GiB
+func (s *S3Connector) Copy(ctx context.Context, src, dst string) error {
+	source := (&url.URL{Path: s.Config.Bucket + "/" + src}).EscapedPath()
+	_, err := s.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.Config.Bucket),
+		Key:        aws.String(dst),
+		CopySource: aws.String(source),
+	})
+	return s3Error(src, err)
+}
+
+// s3Error wraps the errors S3 returns for missing keys in
+// connectors.ErrObjectNotFound
+func s3Error(key string, err error) error {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return fmt.Errorf("%w: %s: %v", connectors.ErrObjectNotFound, key, err)
+		}
+	}
+	return err
+}
@@ -1,9 +1,25 @@
 package cloud
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/appendblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
@@ -18,12 +34,28 @@ type AzureBlobConnector struct {
 }
 
 type AzureBlobConfig struct {
-	AccountName    string
-	AccountKey     string
-	ContainerName  string
+	AccountName   string
+	AccountKey    string
+	ContainerName string
+	// EndpointSuffix selects the cloud, e.g. "core.chinacloudapi.cn";
+	// defaults to "core.windows.net"
 	EndpointSuffix string
+	// Endpoint overrides the service URL built from AccountName and
+	// EndpointSuffix, e.g. "http://127.0.0.1:10000/devstoreaccount1" for
+	// Azurite
+	Endpoint string
+	// BlockSize is the size of the blocks Create stages before committing
+	// them; defaults to 8 MiB. Every open writer buffers one block.
+	BlockSize int
 }
 
+// AzureMaxAppendBlock is the largest block AppendBlock accepts
+const AzureMaxAppendBlock = 4 * 1024 * 1024
+
+// ErrAppendBlobFull is returned, wrapped, when an append blob already holds
+// the 50,000 blocks Azure allows
+var ErrAppendBlobFull = errors.New("append blob has reached its block limit")
+
 func NewAzureBlobConnector(config AzureBlobConfig) *AzureBlobConnector {
 	return &AzureBlobConnector{
 		BaseConnector: connectors.BaseConnector{
@@ -43,7 +75,7 @@ func (a *AzureBlobConnector) Connect() error {
 		return err
 	}
 
-	serviceClient, err := azblob.NewClientWithSharedKeyCredential(a.Config.AccountName, credential, &azblob.ClientOptions{})
+	serviceClient, err := azblob.NewClientWithSharedKeyCredential(a.ServiceURL(), credential, &azblob.ClientOptions{})
 	if err != nil {
 		return err
 	}
@@ -53,6 +85,18 @@ func (a *AzureBlobConnector) Connect() error {
 	return nil
 }
 
+// ServiceURL returns the blob service URL of the account
+func (a *AzureBlobConnector) ServiceURL() string {
+	if a.Config.Endpoint != "" {
+		return strings.TrimSuffix(a.Config.Endpoint, "/") + "/"
+	}
+	suffix := a.Config.EndpointSuffix
+	if suffix == "" {
+		suffix = "core.windows.net"
+	}
+	return fmt.Sprintf("https://%s.blob.%s/", a.Config.AccountName, suffix)
+}
+
 func (a *AzureBlobConnector) Disconnect() error {
 	// Azure SDK handles connection management
 	return nil
@@ -103,3 +147,276 @@ func (a *AzureBlobConnector) ListBlobs(prefix string) ([]*container.BlobItem, er
 
 	return blobs, nil
 }
+
+func (a *AzureBlobConnector) containerClient() *container.Client {
+	return a.serviceClient.ServiceClient().NewContainerClient(a.Config.ContainerName)
+}
+
+// List implements connectors.ObjectStore
+func (a *AzureBlobConnector) List(ctx context.Context, options connectors.ListOptions, fn func(page []connectors.ObjectInfo) error) error {
+	listOptions := &container.ListBlobsFlatOptions{Prefix: &options.Prefix}
+	if options.PageSize > 0 {
+		maxResults := int32(options.PageSize)
+		listOptions.MaxResults = &maxResults
+	}
+
+	pager := a.containerClient().NewListBlobsFlatPager(listOptions)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		page := make([]connectors.ObjectInfo, 0, len(resp.Segment.BlobItems))
+		for _, item := range resp.Segment.BlobItems {
+			key := deref(item.Name)
+			// Blob listings have no start key, so skip up to it here
+			if key <= options.StartAfter || strings.HasSuffix(key, "/") {
+				continue
+			}
+			info := connectors.ObjectInfo{Key: key}
+			if props := item.Properties; props != nil {
+				info.Size = deref(props.ContentLength)
+				info.ModTime = deref(props.LastModified)
+				info.ContentType = deref(props.ContentType)
+				if props.ETag != nil {
+					info.ETag = string(*props.ETag)
+				}
+			}
+			page = append(page, info)
+		}
+		if len(page) == 0 {
+			continue
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open implements connectors.ObjectStore
+func (a *AzureBlobConnector) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := a.containerClient().NewBlobClient(key).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, azureError(key, err)
+	}
+	return resp.Body, nil
+}
+
+// ContainerURL returns the URL of the configured container
+func (a *AzureBlobConnector) ContainerURL() string {
+	return a.containerClient().URL()
+}
+
+// Create implements connectors.ObjectStore by staging blocks of
+// Config.BlockSize as they fill up and committing the block list on Close.
+// Staged blocks of an abandoned write are never committed, so the previous
+// version of the blob stays in place.
+func (a *AzureBlobConnector) Create(ctx context.Context, key string, options connectors.WriteOptions) (io.WriteCloser, error) {
+	blockSize := a.Config.BlockSize
+	if blockSize <= 0 {
+		blockSize = 8 * 1024 * 1024
+	}
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	return &blockWriter{
+		ctx:         ctx,
+		client:      a.containerClient().NewBlockBlobClient(key),
+		contentType: options.ContentType,
+		prefix:      hex.EncodeToString(prefix),
+		buf:         make([]byte, 0, blockSize),
+	}, nil
+}
+
+// blockWriter stages full blocks and commits them on Close
+type blockWriter struct {
+	ctx         context.Context
+	client      *blockblob.Client
+	contentType string
+	// prefix makes block IDs unique to this write; all IDs of a blob must
+	// have the same length
+	prefix string
+	buf    []byte
+	ids    []string
+	err    error
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		if len(w.buf) == cap(w.buf) {
+			if w.err = w.stage(); w.err != nil {
+				return written, w.err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *blockWriter) stage() error {
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%08d", w.prefix, len(w.ids))))
+	body := streaming.NopCloser(bytes.NewReader(w.buf))
+	if _, err := w.client.StageBlock(w.ctx, id, body, nil); err != nil {
+		return fmt.Errorf("failed to stage block %d: %w", len(w.ids), err)
+	}
+	w.ids = append(w.ids, id)
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *blockWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.stage(); err != nil {
+			return err
+		}
+	}
+	options := &blockblob.CommitBlockListOptions{}
+	if w.contentType != "" {
+		options.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: &w.contentType}
+	}
+	_, err := w.client.CommitBlockList(w.ctx, w.ids, options)
+	return err
+}
+
+// OpenAppendBlob creates an append blob unless it already exists and
+// returns its size, where the next block will be appended
+func (a *AzureBlobConnector) OpenAppendBlob(ctx context.Context, key, contentType string) (int64, error) {
+	client := a.containerClient().NewAppendBlobClient(key)
+	options := &appendblob.CreateOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		},
+	}
+	if contentType != "" {
+		options.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: &contentType}
+	}
+	_, err := client.Create(ctx, options)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobAlreadyExists) {
+		return 0, err
+	}
+
+	props, err := client.GetProperties(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	if deref(props.BlobType) != blob.BlobTypeAppendBlob {
+		return 0, fmt.Errorf("%s is a %s, not an append blob", key, deref(props.BlobType))
+	}
+	return deref(props.ContentLength), nil
+}
+
+// AppendBlock appends a block of at most AzureMaxAppendBlock bytes to an
+// append blob of the given size. The size is checked as a precondition, so
+// a retry never appends a block twice: if an earlier attempt already
+// landed, AppendBlock succeeds without appending again. It fails if
+// another writer appended in between, and with ErrAppendBlobFull once the
+// blob holds as many blocks as it can.
+func (a *AzureBlobConnector) AppendBlock(ctx context.Context, key string, block []byte, size int64) error {
+	if len(block) > AzureMaxAppendBlock {
+		return fmt.Errorf("append block of %d bytes exceeds the %d byte limit", len(block), AzureMaxAppendBlock)
+	}
+	client := a.containerClient().NewAppendBlobClient(key)
+	_, err := client.AppendBlock(ctx, streaming.NopCloser(bytes.NewReader(block)), &appendblob.AppendBlockOptions{
+		AppendPositionAccessConditions: &appendblob.AppendPositionAccessConditions{AppendPosition: &size},
+	})
+	if bloberror.HasCode(err, bloberror.BlockCountExceedsLimit) {
+		return fmt.Errorf("%s: %w", key, ErrAppendBlobFull)
+	}
+	if !bloberror.HasCode(err, bloberror.AppendPositionConditionNotMet) {
+		return err
+	}
+
+	props, propsErr := client.GetProperties(ctx, nil)
+	if propsErr != nil {
+		return err
+	}
+	if deref(props.ContentLength) == size+int64(len(block)) {
+		return nil
+	}
+	return fmt.Errorf("%s was appended to by another writer: expected %d bytes, found %d", key, size, deref(props.ContentLength))
+}
+
+// Stat implements connectors.ObjectStore
+func (a *AzureBlobConnector) Stat(ctx context.Context, key string) (connectors.ObjectInfo, error) {
+	props, err := a.containerClient().NewBlobClient(key).GetProperties(ctx, nil)
+	if err != nil {
+		return connectors.ObjectInfo{}, azureError(key, err)
+	}
+	info := connectors.ObjectInfo{
+		Key:         key,
+		Size:        deref(props.ContentLength),
+		ModTime:     deref(props.LastModified),
+		ContentType: deref(props.ContentType),
+	}
+	if props.ETag != nil {
+		info.ETag = string(*props.ETag)
+	}
+	return info, nil
+}
+
+// Delete implements connectors.ObjectStore
+func (a *AzureBlobConnector) Delete(ctx context.Context, key string) error {
+	_, err := a.containerClient().NewBlobClient(key).Delete(ctx, nil)
+	return azureError(key, err)
+}
+
+// Copy implements connectors.ObjectStore with a server-side copy, waiting
+// for it to complete
+func (a *AzureBlobConnector) Copy(ctx context.Context, src, dst string) error {
+	containerClient := a.containerClient()
+	source := containerClient.NewBlobClient(src).URL()
+	target := containerClient.NewBlobClient(dst)
+
+	resp, err := target.StartCopyFromURL(ctx, source, nil)
+	if err != nil {
+		return azureError(src, err)
+	}
+	status := deref(resp.CopyStatus)
+	for status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+		props, err := target.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		status = deref(props.CopyStatus)
+		if status != blob.CopyStatusTypePending && status != blob.CopyStatusTypeSuccess {
+			return fmt.Errorf("copy of %s to %s %s: %s", src, dst, status, deref(props.CopyStatusDescription))
+		}
+	}
+	if status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("copy of %s to %s %s", src, dst, status)
+	}
+	return nil
+}
+
+// azureError wraps missing-blob errors in connectors.ErrObjectNotFound
+func azureError(key string, err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("%w: %s: %v", connectors.ErrObjectNotFound, key, err)
+	}
+	return err
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
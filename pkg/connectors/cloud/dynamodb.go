@@ -1,18 +1,25 @@
 package cloud
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
 )
 
 type DynamoDBConnector struct {
 	connectors.BaseConnector
-	Config DynamoDBConfig
-	client *dynamodb.DynamoDB
+	Config  DynamoDBConfig
+	client  *dynamodb.DynamoDB
+	streams *dynamodbstreams.DynamoDBStreams
+	table   *dynamodb.TableDescription
 }
 
 type DynamoDBConfig struct {
@@ -36,28 +43,41 @@ func NewDynamoDBConnector(config DynamoDBConfig) *DynamoDBConnector {
 }
 
 func (d *DynamoDBConnector) Connect() error {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(d.Config.Region),
-		Credentials: credentials.NewStaticCredentials(
-			d.Config.AccessKeyID,
-			d.Config.SecretAccessKey,
-			"",
-		),
-		Endpoint: aws.String(d.Config.Endpoint),
-	})
+	sess, err := d.session()
 	if err != nil {
 		return err
 	}
 
 	d.client = dynamodb.New(sess)
+	d.streams = dynamodbstreams.New(sess)
 
 	// Verify table exists
 	input := &dynamodb.DescribeTableInput{
 		TableName: aws.String(d.Config.TableName),
 	}
 
-	_, err = d.client.DescribeTable(input)
-	return err
+	result, err := d.client.DescribeTable(input)
+	if err != nil {
+		return err
+	}
+
+	d.table = result.Table
+	return nil
+}
+
+func (d *DynamoDBConnector) session() (*session.Session, error) {
+	config := &aws.Config{
+		Region: aws.String(d.Config.Region),
+		Credentials: credentials.NewStaticCredentials(
+			d.Config.AccessKeyID,
+			d.Config.SecretAccessKey,
+			"",
+		),
+	}
+	if d.Config.Endpoint != "" {
+		config.Endpoint = aws.String(d.Config.Endpoint)
+	}
+	return session.NewSession(config)
 }
 
 func (d *DynamoDBConnector) Disconnect() error {
@@ -130,36 +150,49 @@ func (d *DynamoDBConnector) Query(keyCondition string, expressionAttrValues map[
 		ExpressionAttributeValues: values,
 	}
 
-	result, err := d.client.Query(input)
+	var items []map[string]interface{}
+	var pageErr error
+	err = d.client.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageItems []map[string]interface{}
+		if pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageItems); pageErr != nil {
+			return false
+		}
+		items = append(items, pageItems...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	var items []map[string]interface{}
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items)
-	return items, err
+	return items, pageErr
 }
 
 func (d *DynamoDBConnector) Scan(filterExpression string, expressionAttrValues map[string]interface{}) ([]map[string]interface{}, error) {
-	values, err := dynamodbattribute.MarshalMap(expressionAttrValues)
-	if err != nil {
-		return nil, err
-	}
-
 	input := &dynamodb.ScanInput{
-		TableName:                 aws.String(d.Config.TableName),
-		FilterExpression:          aws.String(filterExpression),
-		ExpressionAttributeValues: values,
+		TableName: aws.String(d.Config.TableName),
+	}
+	if filterExpression != "" {
+		values, err := dynamodbattribute.MarshalMap(expressionAttrValues)
+		if err != nil {
+			return nil, err
+		}
+		input.FilterExpression = aws.String(filterExpression)
+		input.ExpressionAttributeValues = values
 	}
 
-	result, err := d.client.Scan(input)
+	var items []map[string]interface{}
+	var pageErr error
+	err := d.client.ScanPages(input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageItems []map[string]interface{}
+		if pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageItems); pageErr != nil {
+			return false
+		}
+		items = append(items, pageItems...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	var items []map[string]interface{}
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items)
-	return items, err
+	return items, pageErr
 }
 
 func (d *DynamoDBConnector) DeleteItem(key map[string]interface{}) error {
@@ -198,3 +231,117 @@ func (d *DynamoDBConnector) UpdateItem(key map[string]interface{}, updateExpress
 	_, err = d.client.UpdateItem(input)
 	return err
 }
+
+// Client returns the underlying DynamoDB client
+func (d *DynamoDBConnector) Client() *dynamodb.DynamoDB {
+	return d.client
+}
+
+// Streams returns the DynamoDB Streams client for the same endpoint
+func (d *DynamoDBConnector) Streams() *dynamodbstreams.DynamoDBStreams {
+	return d.streams
+}
+
+// StreamARN returns the ARN of the table's latest stream, or an error if
+// streams are not enabled on the table
+func (d *DynamoDBConnector) StreamARN() (string, error) {
+	if d.table == nil || d.table.LatestStreamArn == nil {
+		return "", fmt.Errorf("table %s has no stream enabled", d.Config.TableName)
+	}
+	return aws.StringValue(d.table.LatestStreamArn), nil
+}
+
+// StreamShards lists every shard of a stream, following DescribeStream
+// pagination
+func (d *DynamoDBConnector) StreamShards(ctx context.Context, streamARN string) ([]*dynamodbstreams.Shard, error) {
+	var shards []*dynamodbstreams.Shard
+	input := &dynamodbstreams.DescribeStreamInput{
+		StreamArn: aws.String(streamARN),
+	}
+	for {
+		result, err := d.streams.DescribeStreamWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, result.StreamDescription.Shards...)
+		if result.StreamDescription.LastEvaluatedShardId == nil {
+			return shards, nil
+		}
+		input.ExclusiveStartShardId = result.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+// KeyAttributes returns the table's hash key followed by its range key, if any
+func (d *DynamoDBConnector) KeyAttributes() []string {
+	if d.table == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(d.table.KeySchema))
+	for _, elem := range d.table.KeySchema {
+		if aws.StringValue(elem.KeyType) == dynamodb.KeyTypeHash {
+			keys = append([]string{aws.StringValue(elem.AttributeName)}, keys...)
+		} else {
+			keys = append(keys, aws.StringValue(elem.AttributeName))
+		}
+	}
+	return keys
+}
+
+// ScanPage reads a single page of a (possibly segmented) scan starting at
+// startKey. The returned key is nil once the segment is exhausted.
+func (d *DynamoDBConnector) ScanPage(ctx context.Context, input *dynamodb.ScanInput, startKey map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	page := *input
+	page.TableName = aws.String(d.Config.TableName)
+	page.ExclusiveStartKey = startKey
+
+	result, err := d.client.ScanWithContext(ctx, &page)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Items, result.LastEvaluatedKey, nil
+}
+
+// QueryPage reads a single page of a query starting at startKey. The
+// returned key is nil once the query is exhausted.
+func (d *DynamoDBConnector) QueryPage(ctx context.Context, input *dynamodb.QueryInput, startKey map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	page := *input
+	page.TableName = aws.String(d.Config.TableName)
+	page.ExclusiveStartKey = startKey
+
+	result, err := d.client.QueryWithContext(ctx, &page)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Items, result.LastEvaluatedKey, nil
+}
+
+// BatchWrite sends up to 25 write requests, resubmitting UnprocessedItems
+// with exponential backoff until they are accepted or maxRetries is reached
+func (d *DynamoDBConnector) BatchWrite(ctx context.Context, requests []*dynamodb.WriteRequest, maxRetries int) error {
+	pending := map[string][]*dynamodb.WriteRequest{d.Config.TableName: requests}
+	delay := 50 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		result, err := d.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: pending,
+		})
+		if err != nil {
+			return err
+		}
+
+		pending = result.UnprocessedItems
+		if len(pending[d.Config.TableName]) == 0 {
+			return nil
+		}
+		if attempt >= maxRetries {
+			return fmt.Errorf("%d items still unprocessed after %d retries", len(pending[d.Config.TableName]), maxRetries)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
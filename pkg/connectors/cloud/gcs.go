@@ -2,9 +2,16 @@ package cloud
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
 
 	"cloud.google.com/go/storage"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -20,8 +27,27 @@ type GCSConfig struct {
 	ProjectID       string
 	Bucket          string
 	CredentialsFile string
+	// CredentialsJSON holds the contents of a credentials file, for
+	// credentials kept in a secret store rather than on disk. Without
+	// either, Application Default Credentials are used.
+	CredentialsJSON string
+	// EmulatorHost points the client at an emulator such as
+	// fake-gcs-server, without authentication, e.g. "localhost:4443";
+	// defaults to the STORAGE_EMULATOR_HOST environment variable
+	EmulatorHost string
+	// ChunkSize is the chunk size of resumable uploads, each chunk being
+	// retried on its own; defaults to 16 MiB. Every open writer buffers
+	// one chunk.
+	ChunkSize int
+	// PreventOverwrite makes Create fail with ErrPreconditionFailed
+	// instead of replacing an existing object
+	PreventOverwrite bool
 }
 
+// ErrPreconditionFailed is returned, wrapped, when a conditional write
+// finds that the object's generation is not the expected one
+var ErrPreconditionFailed = errors.New("precondition failed")
+
 func NewGCSConnector(config GCSConfig) *GCSConnector {
 	return &GCSConnector{
 		BaseConnector: connectors.BaseConnector{
@@ -37,10 +63,36 @@ func NewGCSConnector(config GCSConfig) *GCSConnector {
 
 func (g *GCSConnector) Connect() error {
 	var err error
-	g.client, err = storage.NewClient(g.ctx, option.WithCredentialsFile(g.Config.CredentialsFile))
+	g.client, err = storage.NewClient(g.ctx, g.clientOptions()...)
 	return err
 }
 
+func (g *GCSConnector) clientOptions() []option.ClientOption {
+	host := g.Config.EmulatorHost
+	if host == "" {
+		host = os.Getenv("STORAGE_EMULATOR_HOST")
+	}
+	if host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return []option.ClientOption{
+			option.WithEndpoint(strings.TrimSuffix(host, "/") + "/storage/v1/"),
+			option.WithoutAuthentication(),
+			// Emulators serve the JSON API only
+			storage.WithJSONReads(),
+		}
+	}
+
+	switch {
+	case g.Config.CredentialsJSON != "":
+		return []option.ClientOption{option.WithCredentialsJSON([]byte(g.Config.CredentialsJSON))}
+	case g.Config.CredentialsFile != "":
+		return []option.ClientOption{option.WithCredentialsFile(g.Config.CredentialsFile)}
+	}
+	return nil
+}
+
 func (g *GCSConnector) Disconnect() error {
 	if g.client != nil {
 		return g.client.Close()
@@ -63,6 +115,12 @@ func (g *GCSConnector) GetConfig() interface{} {
 }
 
 // Additional GCS-specific methods
+
+// Client returns the underlying storage client
+func (g *GCSConnector) Client() *storage.Client {
+	return g.client
+}
+
 func (g *GCSConnector) ListBuckets() ([]*storage.BucketHandle, error) {
 	it := g.client.Buckets(g.ctx, g.Config.ProjectID)
 	var buckets []*storage.BucketHandle
@@ -95,3 +153,133 @@ func (g *GCSConnector) ListObjects(prefix string) ([]*storage.ObjectHandle, erro
 	}
 	return objects, nil
 }
+
+// List implements connectors.ObjectStore
+func (g *GCSConnector) List(ctx context.Context, options connectors.ListOptions, fn func(page []connectors.ObjectInfo) error) error {
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+	query := &storage.Query{Prefix: options.Prefix, StartOffset: options.StartAfter}
+	it := g.client.Bucket(g.Config.Bucket).Objects(ctx, query)
+
+	page := make([]connectors.ObjectInfo, 0, pageSize)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// StartOffset is inclusive; skip folder placeholders too
+		if attrs.Name == options.StartAfter || strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		page = append(page, gcsObjectInfo(attrs))
+		if len(page) == pageSize {
+			if err := fn(page); err != nil {
+				return err
+			}
+			page = make([]connectors.ObjectInfo, 0, pageSize)
+		}
+	}
+	if len(page) > 0 {
+		return fn(page)
+	}
+	return nil
+}
+
+func gcsObjectInfo(attrs *storage.ObjectAttrs) connectors.ObjectInfo {
+	return connectors.ObjectInfo{
+		Key:         attrs.Name,
+		Size:        attrs.Size,
+		ModTime:     attrs.Updated,
+		ETag:        attrs.Etag,
+		ContentType: attrs.ContentType,
+	}
+}
+
+// Open implements connectors.ObjectStore
+func (g *GCSConnector) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := g.client.Bucket(g.Config.Bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, gcsError(key, err)
+	}
+	return reader, nil
+}
+
+// Create implements connectors.ObjectStore with a resumable upload. With
+// Config.PreventOverwrite, it only writes objects that do not exist yet.
+func (g *GCSConnector) Create(ctx context.Context, key string, options connectors.WriteOptions) (io.WriteCloser, error) {
+	object := g.client.Bucket(g.Config.Bucket).Object(key)
+	if g.Config.PreventOverwrite {
+		object = object.If(storage.Conditions{DoesNotExist: true})
+	}
+	return g.newWriter(ctx, object, options), nil
+}
+
+// CreateIfGeneration writes an object only if its current generation is
+// generation, with 0 meaning that the object must not exist. Otherwise
+// Close fails with ErrPreconditionFailed.
+func (g *GCSConnector) CreateIfGeneration(ctx context.Context, key string, generation int64, options connectors.WriteOptions) (io.WriteCloser, error) {
+	conditions := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conditions = storage.Conditions{DoesNotExist: true}
+	}
+	object := g.client.Bucket(g.Config.Bucket).Object(key).If(conditions)
+	return g.newWriter(ctx, object, options), nil
+}
+
+func (g *GCSConnector) newWriter(ctx context.Context, object *storage.ObjectHandle, options connectors.WriteOptions) io.WriteCloser {
+	writer := object.NewWriter(ctx)
+	writer.ContentType = options.ContentType
+	if g.Config.ChunkSize > 0 {
+		writer.ChunkSize = g.Config.ChunkSize
+	}
+	return &gcsWriter{Writer: writer, key: object.ObjectName()}
+}
+
+// gcsWriter maps failed preconditions to ErrPreconditionFailed
+type gcsWriter struct {
+	*storage.Writer
+	key string
+}
+
+func (w *gcsWriter) Close() error {
+	err := w.Writer.Close()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %s: %v", ErrPreconditionFailed, w.key, err)
+	}
+	return err
+}
+
+// Stat implements connectors.ObjectStore
+func (g *GCSConnector) Stat(ctx context.Context, key string) (connectors.ObjectInfo, error) {
+	attrs, err := g.client.Bucket(g.Config.Bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		return connectors.ObjectInfo{}, gcsError(key, err)
+	}
+	return gcsObjectInfo(attrs), nil
+}
+
+// Delete implements connectors.ObjectStore
+func (g *GCSConnector) Delete(ctx context.Context, key string) error {
+	return gcsError(key, g.client.Bucket(g.Config.Bucket).Object(key).Delete(ctx))
+}
+
+// Copy implements connectors.ObjectStore with a server-side copy
+func (g *GCSConnector) Copy(ctx context.Context, src, dst string) error {
+	bucket := g.client.Bucket(g.Config.Bucket)
+	_, err := bucket.Object(dst).CopierFrom(bucket.Object(src)).Run(ctx)
+	return gcsError(src, err)
+}
+
+// gcsError wraps storage.ErrObjectNotExist in connectors.ErrObjectNotFound
+func gcsError(key string, err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s: %v", connectors.ErrObjectNotFound, key, err)
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package cloud
+
+import "io"
+
+// pipeWriter streams what is written to it into an upload running in the
+// background. Close waits for the upload to finish.
+type pipeWriter struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+func newPipeWriter(upload func(body io.Reader) error) *pipeWriter {
+	pr, pw := io.Pipe()
+	w := &pipeWriter{pipe: pw, done: make(chan error, 1)}
+	go func() {
+		err := upload(pr)
+		// Unblock writers if the upload stopped early
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+func (w *pipeWriter) Close() error {
+	w.pipe.Close()
+	return <-w.done
+}
@@ -1,7 +1,13 @@
 package cloud
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -23,8 +29,15 @@ type SQSConfig struct {
 	AccessKeyID     string
 	SecretAccessKey string
 	WaitTimeSeconds int64
+	Endpoint        string // Optional, for local SQS emulators
+	// MessageGroupID is the group SendMessage and SendMessageBatch use on
+	// FIFO queues
+	MessageGroupID string
 }
 
+// sqsMaxBatchEntries is the most entries any SQS batch call accepts
+const sqsMaxBatchEntries = 10
+
 func NewSQSConnector(config SQSConfig) *SQSConnector {
 	return &SQSConnector{
 		BaseConnector: connectors.BaseConnector{
@@ -38,14 +51,7 @@ func NewSQSConnector(config SQSConfig) *SQSConnector {
 }
 
 func (s *SQSConnector) Connect() error {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(s.Config.Region),
-		Credentials: credentials.NewStaticCredentials(
-			s.Config.AccessKeyID,
-			s.Config.SecretAccessKey,
-			"",
-		),
-	})
+	sess, err := s.session()
 	if err != nil {
 		return err
 	}
@@ -64,6 +70,21 @@ func (s *SQSConnector) Connect() error {
 	return nil
 }
 
+func (s *SQSConnector) session() (*session.Session, error) {
+	config := &aws.Config{
+		Region: aws.String(s.Config.Region),
+		Credentials: credentials.NewStaticCredentials(
+			s.Config.AccessKeyID,
+			s.Config.SecretAccessKey,
+			"",
+		),
+	}
+	if s.Config.Endpoint != "" {
+		config.Endpoint = aws.String(s.Config.Endpoint)
+	}
+	return session.NewSession(config)
+}
+
 func (s *SQSConnector) Disconnect() error {
 	// AWS SDK handles connection management
 	return nil
@@ -82,18 +103,24 @@ func (s *SQSConnector) GetConfig() interface{} {
 }
 
 // Additional SQS-specific methods
+
+// IsFIFO reports whether the queue is a FIFO queue, whose names must end
+// in ".fifo"
+func (s *SQSConnector) IsFIFO() bool {
+	return strings.HasSuffix(s.Config.QueueName, ".fifo")
+}
+
+// SendMessage sends a message. On FIFO queues it goes to the configured
+// MessageGroupID, deduplicated on the SHA-256 of its body, and
+// delaySeconds is ignored because FIFO queues only support queue delays.
 func (s *SQSConnector) SendMessage(messageBody string, delaySeconds int64, attributes map[string]string) (*sqs.SendMessageOutput, error) {
-	msgAttrs := make(map[string]*sqs.MessageAttributeValue)
-	for k, v := range attributes {
-		msgAttrs[k] = &sqs.MessageAttributeValue{
-			DataType:    aws.String("String"),
-			StringValue: aws.String(v),
-		}
+	if s.IsFIFO() {
+		return s.SendMessageToGroup(messageBody, s.Config.MessageGroupID, bodyDeduplicationID(messageBody), attributes)
 	}
 
 	input := &sqs.SendMessageInput{
 		DelaySeconds:      aws.Int64(delaySeconds),
-		MessageAttributes: msgAttrs,
+		MessageAttributes: messageAttributes(attributes),
 		MessageBody:       aws.String(messageBody),
 		QueueUrl:          aws.String(s.queueURL),
 	}
@@ -101,6 +128,44 @@ func (s *SQSConnector) SendMessage(messageBody string, delaySeconds int64, attri
 	return s.client.SendMessage(input)
 }
 
+// SendMessageToGroup sends a message to a FIFO queue. An empty
+// deduplicationID relies on the queue's content-based deduplication.
+func (s *SQSConnector) SendMessageToGroup(messageBody, groupID, deduplicationID string, attributes map[string]string) (*sqs.SendMessageOutput, error) {
+	if groupID == "" {
+		return nil, fmt.Errorf("message group ID is required for FIFO queues")
+	}
+
+	input := &sqs.SendMessageInput{
+		MessageAttributes: messageAttributes(attributes),
+		MessageBody:       aws.String(messageBody),
+		MessageGroupId:    aws.String(groupID),
+		QueueUrl:          aws.String(s.queueURL),
+	}
+	if deduplicationID != "" {
+		input.MessageDeduplicationId = aws.String(deduplicationID)
+	}
+
+	return s.client.SendMessage(input)
+}
+
+func messageAttributes(attributes map[string]string) map[string]*sqs.MessageAttributeValue {
+	msgAttrs := make(map[string]*sqs.MessageAttributeValue)
+	for k, v := range attributes {
+		msgAttrs[k] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	return msgAttrs
+}
+
+// bodyDeduplicationID mirrors content-based deduplication for queues that
+// do not have it enabled
+func bodyDeduplicationID(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
 func (s *SQSConnector) ReceiveMessages(maxMessages int64) ([]*sqs.Message, error) {
 	input := &sqs.ReceiveMessageInput{
 		MessageAttributeNames: []*string{
@@ -149,24 +214,29 @@ func (s *SQSConnector) GetQueueAttributes() (*sqs.GetQueueAttributesOutput, erro
 	return s.client.GetQueueAttributes(input)
 }
 
+// SendMessageBatch sends up to ten messages. On FIFO queues they go to the
+// configured MessageGroupID in order.
 func (s *SQSConnector) SendMessageBatch(messages []string, attributes []map[string]string) (*sqs.SendMessageBatchOutput, error) {
+	if s.IsFIFO() && s.Config.MessageGroupID == "" {
+		return nil, fmt.Errorf("message group ID is required for FIFO queues")
+	}
+
 	entries := make([]*sqs.SendMessageBatchRequestEntry, len(messages))
 
 	for i, msg := range messages {
-		msgAttrs := make(map[string]*sqs.MessageAttributeValue)
+		var attrs map[string]string
 		if i < len(attributes) {
-			for k, v := range attributes[i] {
-				msgAttrs[k] = &sqs.MessageAttributeValue{
-					DataType:    aws.String("String"),
-					StringValue: aws.String(v),
-				}
-			}
+			attrs = attributes[i]
 		}
 
 		entries[i] = &sqs.SendMessageBatchRequestEntry{
 			Id:                aws.String(fmt.Sprintf("msg-%d", i)),
 			MessageBody:       aws.String(msg),
-			MessageAttributes: msgAttrs,
+			MessageAttributes: messageAttributes(attrs),
+		}
+		if s.IsFIFO() {
+			entries[i].MessageGroupId = aws.String(s.Config.MessageGroupID)
+			entries[i].MessageDeduplicationId = aws.String(bodyDeduplicationID(msg))
 		}
 	}
 
@@ -177,3 +247,134 @@ func (s *SQSConnector) SendMessageBatch(messages []string, attributes []map[stri
 
 	return s.client.SendMessageBatch(input)
 }
+
+// Client returns the underlying SQS client
+func (s *SQSConnector) Client() *sqs.SQS {
+	return s.client
+}
+
+// QueueURL returns the URL of the queue resolved by Connect
+func (s *SQSConnector) QueueURL() string {
+	return s.queueURL
+}
+
+// VisibilityTimeout returns the queue's default visibility timeout
+func (s *SQSConnector) VisibilityTimeout(ctx context.Context) (time.Duration, error) {
+	result, err := s.client.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(s.queueURL),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameVisibilityTimeout)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.Atoi(aws.StringValue(result.Attributes[sqs.QueueAttributeNameVisibilityTimeout]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid visibility timeout: %w", err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Receive long-polls for up to maxMessages messages with all system and
+// message attributes. A zero visibility keeps the queue's default.
+func (s *SQSConnector) Receive(ctx context.Context, maxMessages int64, wait, visibility time.Duration) ([]*sqs.Message, error) {
+	input := &sqs.ReceiveMessageInput{
+		AttributeNames: []*string{
+			aws.String(sqs.MessageSystemAttributeNameAll),
+		},
+		MessageAttributeNames: []*string{
+			aws.String(sqs.QueueAttributeNameAll),
+		},
+		QueueUrl:            aws.String(s.queueURL),
+		MaxNumberOfMessages: aws.Int64(maxMessages),
+		WaitTimeSeconds:     aws.Int64(int64(wait / time.Second)),
+	}
+	if visibility > 0 {
+		input.VisibilityTimeout = aws.Int64(int64(visibility / time.Second))
+	}
+
+	result, err := s.client.ReceiveMessageWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
+
+// ChangeVisibilityBatch sets the visibility timeout of the messages with
+// the given receipt handles, ten at a time
+func (s *SQSConnector) ChangeVisibilityBatch(ctx context.Context, receiptHandles []string, visibility time.Duration) error {
+	for start := 0; start < len(receiptHandles); start += sqsMaxBatchEntries {
+		end := start + sqsMaxBatchEntries
+		if end > len(receiptHandles) {
+			end = len(receiptHandles)
+		}
+		entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, 0, end-start)
+		for i, handle := range receiptHandles[start:end] {
+			entries = append(entries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     aws.String(handle),
+				VisibilityTimeout: aws.Int64(int64(visibility / time.Second)),
+			})
+		}
+		result, err := s.client.ChangeMessageVisibilityBatchWithContext(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(s.queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+		if err := batchFailures(result.Failed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMessageBatch deletes the messages with the given receipt handles,
+// ten at a time
+func (s *SQSConnector) DeleteMessageBatch(ctx context.Context, receiptHandles []string) error {
+	for start := 0; start < len(receiptHandles); start += sqsMaxBatchEntries {
+		end := start + sqsMaxBatchEntries
+		if end > len(receiptHandles) {
+			end = len(receiptHandles)
+		}
+		entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, end-start)
+		for i, handle := range receiptHandles[start:end] {
+			entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(i)),
+				ReceiptHandle: aws.String(handle),
+			})
+		}
+		result, err := s.client.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+			QueueUrl: aws.String(s.queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+		if err := batchFailures(result.Failed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendEntries sends up to ten prepared entries in one SendMessageBatch
+// call. Entries that failed are reported in the output's Failed list.
+func (s *SQSConnector) SendEntries(ctx context.Context, entries []*sqs.SendMessageBatchRequestEntry) (*sqs.SendMessageBatchOutput, error) {
+	return s.client.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(s.queueURL),
+		Entries:  entries,
+	})
+}
+
+// batchFailures summarizes the failed entries of a batch call
+func batchFailures(failed []*sqs.BatchResultErrorEntry) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	messages := make([]string, len(failed))
+	for i, f := range failed {
+		messages[i] = fmt.Sprintf("%s: %s", aws.StringValue(f.Code), aws.StringValue(f.Message))
+	}
+	return fmt.Errorf("%d entries failed: %s", len(failed), strings.Join(messages, "; "))
+}
@@ -1,6 +1,10 @@
 package database
 
 import (
+	"fmt"
+	"math"
+	"math/big"
+
 	"github.com/gocql/gocql"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
 )
@@ -16,6 +20,8 @@ type CassandraConfig struct {
 	Keyspace string
 	Username string
 	Password string
+	// Consistency is the default consistency level, e.g. "LOCAL_QUORUM"
+	Consistency string
 }
 
 func NewCassandraConnector(config CassandraConfig) *CassandraConnector {
@@ -33,6 +39,13 @@ func NewCassandraConnector(config CassandraConfig) *CassandraConnector {
 func (c *CassandraConnector) Connect() error {
 	cluster := gocql.NewCluster(c.Config.Hosts...)
 	cluster.Keyspace = c.Config.Keyspace
+	if c.Config.Consistency != "" {
+		consistency, err := gocql.ParseConsistencyWrapper(c.Config.Consistency)
+		if err != nil {
+			return err
+		}
+		cluster.Consistency = consistency
+	}
 	cluster.Authenticator = gocql.PasswordAuthenticator{
 		Username: c.Config.Username,
 		Password: c.Config.Password,
@@ -67,3 +80,63 @@ func (c *CassandraConnector) Write(data interface{}) error {
 func (c *CassandraConnector) GetConfig() interface{} {
 	return c.Config
 }
+
+// Additional Cassandra-specific methods
+func (c *CassandraConnector) Session() *gocql.Session {
+	return c.session
+}
+
+// TableMetadata returns the schema of a table in the configured keyspace
+func (c *CassandraConnector) TableMetadata(table string) (*gocql.TableMetadata, error) {
+	keyspace, err := c.session.KeyspaceMetadata(c.Config.Keyspace)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := keyspace.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s.%s not found", c.Config.Keyspace, table)
+	}
+	return meta, nil
+}
+
+// PartitionKey returns the partition key column names of a table
+func (c *CassandraConnector) PartitionKey(table string) ([]string, error) {
+	meta, err := c.TableMetadata(table)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(meta.PartitionKey))
+	for i, col := range meta.PartitionKey {
+		columns[i] = col.Name
+	}
+	return columns, nil
+}
+
+// TokenRange is a half-open (Start, End] slice of the Murmur3 token ring
+type TokenRange struct {
+	Start int64
+	End   int64
+}
+
+// SplitTokenRing divides the full Murmur3 token ring into n contiguous ranges
+func SplitTokenRing(n int) []TokenRange {
+	if n < 1 {
+		n = 1
+	}
+
+	min := big.NewInt(math.MinInt64)
+	span := new(big.Int).Sub(big.NewInt(math.MaxInt64), min)
+	step := new(big.Int).Div(span, big.NewInt(int64(n)))
+
+	ranges := make([]TokenRange, n)
+	start := new(big.Int).Set(min)
+	for i := 0; i < n; i++ {
+		end := new(big.Int).Add(start, step)
+		if i == n-1 {
+			end = big.NewInt(math.MaxInt64)
+		}
+		ranges[i] = TokenRange{Start: start.Int64(), End: end.Int64()}
+		start = end
+	}
+	return ranges
+}
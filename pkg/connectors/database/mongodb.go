@@ -2,12 +2,26 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Record metadata keys used for MongoDB-origin records
+const (
+	MongoMetaDatabase    = "mongodb.database"
+	MongoMetaCollection  = "mongodb.collection"
+	MongoMetaOperation   = "mongodb.operation"
+	MongoMetaResumeToken = "mongodb.resume_token"
+	MongoMetaSnapshotID  = "mongodb.snapshot_id"
+	MongoMetaClusterTime = "mongodb.cluster_time"
+)
+
 type MongoDBConnector struct {
 	connectors.BaseConnector
 	Config   MongoDBConfig
@@ -36,7 +50,13 @@ func NewMongoDBConnector(config MongoDBConfig) *MongoDBConnector {
 }
 
 func (m *MongoDBConnector) Connect() error {
-	client, err := mongo.Connect(m.ctx, m.Config.Options)
+	// Options are applied on top of the URI so they can override it
+	opts := options.Client()
+	if m.Config.URI != "" {
+		opts.ApplyURI(m.Config.URI)
+	}
+
+	client, err := mongo.Connect(m.ctx, opts, m.Config.Options)
 	if err != nil {
 		return err
 	}
@@ -66,3 +86,97 @@ func (m *MongoDBConnector) Write(data interface{}) error {
 func (m *MongoDBConnector) GetConfig() interface{} {
 	return m.Config
 }
+
+// Additional MongoDB-specific methods
+func (m *MongoDBConnector) Database() *mongo.Database {
+	return m.database
+}
+
+func (m *MongoDBConnector) Collection(name string) *mongo.Collection {
+	return m.database.Collection(name)
+}
+
+// Watch opens a change stream on the named collection, or on the whole
+// database when collection is empty
+func (m *MongoDBConnector) Watch(ctx context.Context, collection string, pipeline interface{}, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+	if collection == "" {
+		return m.database.Watch(ctx, pipeline, opts)
+	}
+	return m.database.Collection(collection).Watch(ctx, pipeline, opts)
+}
+
+// BulkWrite runs the write models against the named collection
+func (m *MongoDBConnector) BulkWrite(ctx context.Context, collection string, models []mongo.WriteModel, ordered bool) (*mongo.BulkWriteResult, error) {
+	return m.database.Collection(collection).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ordered))
+}
+
+// NormalizeBSON converts decoded BSON values into plain Go values suitable
+// for pipeline.Record.Data: documents become maps, arrays become slices,
+// ObjectIDs become hex strings and dates become time.Time
+func NormalizeBSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bson.M:
+		return normalizeBSONMap(val)
+	case map[string]interface{}:
+		return normalizeBSONMap(val)
+	case bson.D:
+		out := make(map[string]interface{}, len(val))
+		for _, e := range val {
+			out[e.Key] = NormalizeBSON(e.Value)
+		}
+		return out
+	case bson.A:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = NormalizeBSON(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = NormalizeBSON(item)
+		}
+		return out
+	case primitive.ObjectID:
+		return val.Hex()
+	case primitive.DateTime:
+		return val.Time().UTC()
+	case primitive.Timestamp:
+		return time.Unix(int64(val.T), 0).UTC()
+	case primitive.Decimal128:
+		return val.String()
+	case primitive.Binary:
+		return val.Data
+	case primitive.Regex:
+		return val.Pattern
+	case primitive.Null, primitive.Undefined:
+		return nil
+	default:
+		return val
+	}
+}
+
+func normalizeBSONMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = NormalizeBSON(v)
+	}
+	return out
+}
+
+// FormatDocumentID renders a document _id as a string record ID
+func FormatDocumentID(id interface{}) string {
+	switch val := id.(type) {
+	case primitive.ObjectID:
+		return val.Hex()
+	case string:
+		return val
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(NormalizeBSON(val))
+	}
+}
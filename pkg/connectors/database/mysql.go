@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
@@ -73,3 +75,62 @@ func (m *MySQLConnector) Write(data interface{}) error {
 func (m *MySQLConnector) GetConfig() interface{} {
 	return m.Config
 }
+
+// NewTableWriter returns a TableWriter for the given table. Connect must be
+// called first.
+func (m *MySQLConnector) NewTableWriter(config TableWriterConfig) (*TableWriter, error) {
+	return newTableWriter(m.db, mysqlDialect{}, config)
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) quoteIdent(name string) string {
+	return quoteName(name, "`")
+}
+
+func (mysqlDialect) quoteTable(name string) string {
+	return quoteQualified(name, "`")
+}
+
+func (mysqlDialect) placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) columnType(t ColumnType) string {
+	switch t {
+	case ColumnInteger:
+		return "BIGINT"
+	case ColumnFloat:
+		return "DOUBLE"
+	case ColumnBoolean:
+		return "BOOLEAN"
+	case ColumnTimestamp:
+		return "DATETIME(6)"
+	case ColumnJSON:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+func (mysqlDialect) keyColumnType() string {
+	return "VARCHAR(255)"
+}
+
+func (mysqlDialect) upsertClause(key string, columns []string) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", key, key)
+	}
+	sets := make([]string, len(columns))
+	for i, col := range columns {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+func (mysqlDialect) listColumns(ctx context.Context, db *sql.DB, table string) (map[string]bool, error) {
+	schema, name := splitTableName(table)
+	query := `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?`
+	return queryColumns(ctx, db, query, schema, name)
+}
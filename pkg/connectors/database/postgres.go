@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
 	_ "github.com/lib/pq"
@@ -75,3 +77,62 @@ func (p *PostgresConnector) Write(data interface{}) error {
 func (p *PostgresConnector) GetConfig() interface{} {
 	return p.Config
 }
+
+// NewTableWriter returns a TableWriter for the given table. Connect must be
+// called first.
+func (p *PostgresConnector) NewTableWriter(config TableWriterConfig) (*TableWriter, error) {
+	return newTableWriter(p.db, postgresDialect{}, config)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) quoteIdent(name string) string {
+	return quoteName(name, `"`)
+}
+
+func (postgresDialect) quoteTable(name string) string {
+	return quoteQualified(name, `"`)
+}
+
+func (postgresDialect) placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (postgresDialect) columnType(t ColumnType) string {
+	switch t {
+	case ColumnInteger:
+		return "BIGINT"
+	case ColumnFloat:
+		return "DOUBLE PRECISION"
+	case ColumnBoolean:
+		return "BOOLEAN"
+	case ColumnTimestamp:
+		return "TIMESTAMPTZ"
+	case ColumnJSON:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+func (postgresDialect) keyColumnType() string {
+	return "TEXT"
+}
+
+func (postgresDialect) upsertClause(key string, columns []string) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", key)
+	}
+	sets := make([]string, len(columns))
+	for i, col := range columns {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(sets, ", "))
+}
+
+func (postgresDialect) listColumns(ctx context.Context, db *sql.DB, table string) (map[string]bool, error) {
+	schema, name := splitTableName(table)
+	query := `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2`
+	return queryColumns(ctx, db, query, schema, name)
+}
@@ -0,0 +1,469 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EvolutionPolicy controls how a TableWriter reacts to record fields that
+// have no matching column in the target table
+type EvolutionPolicy string
+
+const (
+	// EvolutionStrict rejects records containing unknown fields
+	EvolutionStrict EvolutionPolicy = "strict"
+	// EvolutionAddOnly adds missing columns with ALTER TABLE ADD COLUMN
+	EvolutionAddOnly EvolutionPolicy = "add_only"
+	// EvolutionIgnore silently drops unknown fields
+	EvolutionIgnore EvolutionPolicy = "ignore"
+)
+
+// ColumnType is a portable column type inferred from record values
+type ColumnType int
+
+const (
+	ColumnText ColumnType = iota
+	ColumnInteger
+	ColumnFloat
+	ColumnBoolean
+	ColumnTimestamp
+	ColumnJSON
+)
+
+// String returns the column type name
+func (t ColumnType) String() string {
+	switch t {
+	case ColumnInteger:
+		return "integer"
+	case ColumnFloat:
+		return "float"
+	case ColumnBoolean:
+		return "boolean"
+	case ColumnTimestamp:
+		return "timestamp"
+	case ColumnJSON:
+		return "json"
+	default:
+		return "text"
+	}
+}
+
+// Column describes a single table column
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// InferColumnType maps a Go value from pipeline.Record.Data to a column type.
+// Columns are never widened once created, so decoded JSON numbers are
+// floats even when whole, and strings are text even when they look like
+// timestamps: a later 1.5 or free-form string must still fit. Only Go
+// integers and time.Time values give integer and timestamp columns.
+func InferColumnType(v interface{}) ColumnType {
+	switch v.(type) {
+	case bool:
+		return ColumnBoolean
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return ColumnInteger
+	case float32, float64, json.Number:
+		return ColumnFloat
+	case time.Time:
+		return ColumnTimestamp
+	case string:
+		return ColumnText
+	case map[string]interface{}, []interface{}:
+		return ColumnJSON
+	default:
+		return ColumnText
+	}
+}
+
+// widenColumnType returns the narrowest type able to hold values of both a and b
+func widenColumnType(a, b ColumnType) ColumnType {
+	if a == b {
+		return a
+	}
+	if (a == ColumnInteger && b == ColumnFloat) || (a == ColumnFloat && b == ColumnInteger) {
+		return ColumnFloat
+	}
+	if a == ColumnJSON || b == ColumnJSON {
+		return ColumnJSON
+	}
+	return ColumnText
+}
+
+// InferColumns infers a column set from a batch of rows. Nil values do not
+// contribute to the inferred type; columns are returned sorted by name.
+func InferColumns(rows []map[string]interface{}) []Column {
+	types := make(map[string]ColumnType)
+	nullOnly := make(map[string]bool)
+	for _, row := range rows {
+		for name, v := range row {
+			if v == nil {
+				if _, ok := types[name]; !ok {
+					nullOnly[name] = true
+				}
+				continue
+			}
+			t := InferColumnType(v)
+			if existing, ok := types[name]; ok {
+				t = widenColumnType(existing, t)
+			}
+			types[name] = t
+			delete(nullOnly, name)
+		}
+	}
+	for name := range nullOnly {
+		types[name] = ColumnText
+	}
+
+	columns := make([]Column, 0, len(types))
+	for name, t := range types {
+		columns = append(columns, Column{Name: name, Type: t})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+// dialect captures the SQL differences between the supported databases
+type dialect interface {
+	// quoteIdent quotes a column name, quoteTable a table name that may be
+	// schema-qualified
+	quoteIdent(name string) string
+	quoteTable(name string) string
+	placeholder(n int) string
+	columnType(t ColumnType) string
+	keyColumnType() string
+	// upsertClause follows an INSERT to update the given quoted columns
+	// of the row whose key already exists
+	upsertClause(key string, columns []string) string
+	listColumns(ctx context.Context, db *sql.DB, table string) (map[string]bool, error)
+}
+
+// TableWriterConfig configures a TableWriter
+type TableWriterConfig struct {
+	Table string
+	// IDColumn receives pipeline.Record.ID; leave empty to skip it
+	IDColumn string
+	// AutoCreate runs CREATE TABLE when the table does not exist
+	AutoCreate bool
+	Policy     EvolutionPolicy
+	// Upsert updates the row with the same IDColumn instead of failing,
+	// so records written again after a retry or replay overwrite their
+	// rows. IDColumn must be the table's primary key or unique.
+	Upsert bool
+}
+
+// TableWriter inserts rows into a SQL table, creating and evolving it as
+// configured
+type TableWriter struct {
+	db      *sql.DB
+	dialect dialect
+	config  TableWriterConfig
+
+	mu      sync.Mutex
+	columns map[string]bool
+}
+
+func newTableWriter(db *sql.DB, d dialect, config TableWriterConfig) (*TableWriter, error) {
+	if db == nil {
+		return nil, fmt.Errorf("connector is not connected")
+	}
+	if config.Table == "" {
+		return nil, fmt.Errorf("table name is required")
+	}
+	if config.Policy == "" {
+		config.Policy = EvolutionIgnore
+	}
+	switch config.Policy {
+	case EvolutionStrict, EvolutionAddOnly, EvolutionIgnore:
+	default:
+		return nil, fmt.Errorf("unknown evolution policy: %s", config.Policy)
+	}
+	if config.Upsert && config.IDColumn == "" {
+		return nil, fmt.Errorf("upsert requires an id column")
+	}
+
+	return &TableWriter{
+		db:      db,
+		dialect: d,
+		config:  config,
+	}, nil
+}
+
+// GetConfig returns the writer configuration
+func (w *TableWriter) GetConfig() TableWriterConfig {
+	return w.config
+}
+
+// Columns returns the columns currently known to exist in the table
+func (w *TableWriter) Columns(ctx context.Context) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.loadColumns(ctx); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(w.columns))
+	for name := range w.columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (w *TableWriter) loadColumns(ctx context.Context) error {
+	if w.columns != nil {
+		return nil
+	}
+	columns, err := w.dialect.listColumns(ctx, w.db, w.config.Table)
+	if err != nil {
+		return fmt.Errorf("failed to list columns of %s: %w", w.config.Table, err)
+	}
+	w.columns = columns
+	return nil
+}
+
+// EnsureSchema makes the table match the given columns according to the
+// configured evolution policy. It returns the columns that can be written.
+func (w *TableWriter) EnsureSchema(ctx context.Context, columns []Column) ([]Column, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.loadColumns(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(w.columns) == 0 {
+		if !w.config.AutoCreate {
+			return nil, fmt.Errorf("table %s does not exist", w.config.Table)
+		}
+		if err := w.createTable(ctx, columns); err != nil {
+			return nil, err
+		}
+		return columns, nil
+	}
+
+	var writable, missing []Column
+	for _, col := range columns {
+		if w.columns[col.Name] {
+			writable = append(writable, col)
+		} else {
+			missing = append(missing, col)
+		}
+	}
+
+	if len(missing) == 0 {
+		return writable, nil
+	}
+
+	switch w.config.Policy {
+	case EvolutionStrict:
+		names := make([]string, len(missing))
+		for i, col := range missing {
+			names[i] = col.Name
+		}
+		return nil, fmt.Errorf("table %s has no columns %s", w.config.Table, strings.Join(names, ", "))
+	case EvolutionAddOnly:
+		for _, col := range missing {
+			if err := w.addColumn(ctx, col); err != nil {
+				return nil, err
+			}
+			writable = append(writable, col)
+		}
+	}
+
+	return writable, nil
+}
+
+func (w *TableWriter) createTable(ctx context.Context, columns []Column) error {
+	defs := make([]string, 0, len(columns)+1)
+	created := make(map[string]bool, len(columns)+1)
+	if w.config.IDColumn != "" {
+		defs = append(defs, fmt.Sprintf("%s %s PRIMARY KEY",
+			w.dialect.quoteIdent(w.config.IDColumn), w.dialect.keyColumnType()))
+		created[w.config.IDColumn] = true
+	}
+	for _, col := range columns {
+		if created[col.Name] {
+			continue
+		}
+		defs = append(defs, fmt.Sprintf("%s %s", w.dialect.quoteIdent(col.Name), w.dialect.columnType(col.Type)))
+		created[col.Name] = true
+	}
+
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+		w.dialect.quoteTable(w.config.Table), strings.Join(defs, ", "))
+	if _, err := w.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", w.config.Table, err)
+	}
+
+	w.columns = created
+	return nil
+}
+
+func (w *TableWriter) addColumn(ctx context.Context, col Column) error {
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+		w.dialect.quoteTable(w.config.Table), w.dialect.quoteIdent(col.Name), w.dialect.columnType(col.Type))
+	if _, err := w.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to add column %s to %s: %w", col.Name, w.config.Table, err)
+	}
+	w.columns[col.Name] = true
+	return nil
+}
+
+// InsertRows writes rows in a single transaction. ids, when non-nil, must
+// have the same length as rows and is written to the configured IDColumn,
+// the table's key, so every id must be set.
+func (w *TableWriter) InsertRows(ctx context.Context, ids []string, rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if ids != nil && len(ids) != len(rows) {
+		return fmt.Errorf("got %d ids for %d rows", len(ids), len(rows))
+	}
+	if w.config.IDColumn != "" {
+		for i, id := range ids {
+			if id == "" {
+				return fmt.Errorf("row %d has no id for column %s", i, w.config.IDColumn)
+			}
+		}
+	}
+
+	inferred := InferColumns(rows)
+	if w.config.IDColumn != "" {
+		filtered := inferred[:0]
+		for _, col := range inferred {
+			if col.Name != w.config.IDColumn {
+				filtered = append(filtered, col)
+			}
+		}
+		inferred = filtered
+	}
+
+	columns, err := w.EnsureSchema(ctx, inferred)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(columns)+1)
+	withID := w.config.IDColumn != "" && ids != nil
+	if withID {
+		names = append(names, w.dialect.quoteIdent(w.config.IDColumn))
+	}
+	for _, col := range columns {
+		names = append(names, w.dialect.quoteIdent(col.Name))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(names))
+	for i := range placeholders {
+		placeholders[i] = w.dialect.placeholder(i + 1)
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		w.dialect.quoteTable(w.config.Table), strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	if w.config.Upsert && withID {
+		stmt += " " + w.dialect.upsertClause(names[0], names[1:])
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	prepared, err := tx.PrepareContext(ctx, stmt)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer prepared.Close()
+
+	for i, row := range rows {
+		args := make([]interface{}, 0, len(names))
+		if withID {
+			args = append(args, ids[i])
+		}
+		for _, col := range columns {
+			v, err := sqlValue(row[col.Name])
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to convert column %s: %w", col.Name, err)
+			}
+			args = append(args, v)
+		}
+		if _, err := prepared.ExecContext(ctx, args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// sqlValue converts a record value into a database/sql argument
+func sqlValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case json.Number:
+		return val.String(), nil
+	default:
+		return val, nil
+	}
+}
+
+// splitTableName splits an optionally schema-qualified table name
+func splitTableName(table string) (string, string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// quoteName quotes a single identifier with q, which may contain dots
+func quoteName(name string, q string) string {
+	return q + strings.ReplaceAll(name, q, q+q) + q
+}
+
+// quoteQualified quotes each dot-separated part of a table name with q
+func quoteQualified(name string, q string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteName(part, q)
+	}
+	return strings.Join(parts, ".")
+}
+
+// queryColumns runs an information_schema query returning one column name per row
+func queryColumns(ctx context.Context, db *sql.DB, query string, args ...interface{}) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
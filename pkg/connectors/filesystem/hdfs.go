@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -18,7 +19,22 @@ type HDFSConnector struct {
 type HDFSConfig struct {
 	NameNodeAddrs []string
 	User          string
-	BasePath      string
+	// BasePath confines every key. Unlike LocalFSConfig there is no symlink
+	// policy, as the client fails on paths through HDFS symlinks.
+	BasePath string
+	// Kerberos enables Kerberos authentication; User is then taken from
+	// the principal
+	Kerberos *KerberosConfig
+	// DataTransferProtection is "authentication", "integrity" or
+	// "privacy", as in the dfs.data.transfer.protection property
+	DataTransferProtection string
+	// UseDatanodeHostname connects to datanodes by host name rather than
+	// IP address
+	UseDatanodeHostname bool
+	// Replication and BlockSize apply to the files Create writes; the
+	// cluster's defaults are used when zero
+	Replication int
+	BlockSize   int64
 }
 
 func NewHDFSConnector(config HDFSConfig) *HDFSConnector {
@@ -36,8 +52,17 @@ func NewHDFSConnector(config HDFSConfig) *HDFSConnector {
 func (h *HDFSConnector) Connect() error {
 	var err error
 	options := hdfs.ClientOptions{
-		Addresses: h.Config.NameNodeAddrs,
-		User:      h.Config.User,
+		Addresses:              h.Config.NameNodeAddrs,
+		User:                   h.Config.User,
+		UseDatanodeHostname:    h.Config.UseDatanodeHostname,
+		DataTransferProtection: h.Config.DataTransferProtection,
+	}
+	if h.Config.Kerberos != nil {
+		options.KerberosClient, err = h.Config.Kerberos.client()
+		if err != nil {
+			return err
+		}
+		options.KerberosServicePrincipleName = h.Config.Kerberos.servicePrincipalName()
 	}
 
 	h.client, err = hdfs.NewClient(options)
@@ -73,7 +98,10 @@ func (h *HDFSConnector) GetConfig() interface{} {
 
 // Additional HDFS-specific methods
 func (h *HDFSConnector) ListFiles(path string) ([]string, error) {
-	fullPath := filepath.Join(h.Config.BasePath, path)
+	fullPath, err := h.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
 	var files []string
 
 	fileInfos, err := h.client.ReadDir(fullPath)
@@ -95,12 +123,18 @@ func (h *HDFSConnector) ListFiles(path string) ([]string, error) {
 }
 
 func (h *HDFSConnector) ReadFile(path string) ([]byte, error) {
-	fullPath := filepath.Join(h.Config.BasePath, path)
+	fullPath, err := h.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
 	return h.client.ReadFile(fullPath)
 }
 
 func (h *HDFSConnector) WriteFile(path string, data []byte) error {
-	fullPath := filepath.Join(h.Config.BasePath, path)
+	fullPath, err := h.fullPath(path)
+	if err != nil {
+		return err
+	}
 
 	// Ensure parent directory exists
 	parent := filepath.Dir(fullPath)
@@ -119,8 +153,14 @@ func (h *HDFSConnector) WriteFile(path string, data []byte) error {
 }
 
 func (h *HDFSConnector) CopyFile(src, dst string) error {
-	srcPath := filepath.Join(h.Config.BasePath, src)
-	dstPath := filepath.Join(h.Config.BasePath, dst)
+	srcPath, err := h.fullPath(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := h.fullPath(dst)
+	if err != nil {
+		return err
+	}
 
 	reader, err := h.client.Open(srcPath)
 	if err != nil {
@@ -145,17 +185,198 @@ func (h *HDFSConnector) CopyFile(src, dst string) error {
 }
 
 func (h *HDFSConnector) DeleteFile(path string) error {
-	fullPath := filepath.Join(h.Config.BasePath, path)
+	fullPath, err := h.fullPath(path)
+	if err != nil {
+		return err
+	}
 	return h.client.Remove(fullPath)
 }
 
 func (h *HDFSConnector) FileExists(path string) bool {
-	fullPath := filepath.Join(h.Config.BasePath, path)
-	_, err := h.client.Stat(fullPath)
+	fullPath, err := h.fullPath(path)
+	if err != nil {
+		return false
+	}
+	_, err = h.client.Stat(fullPath)
 	return err == nil
 }
 
 func (h *HDFSConnector) GetFileInfo(path string) (os.FileInfo, error) {
-	fullPath := filepath.Join(h.Config.BasePath, path)
+	fullPath, err := h.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
 	return h.client.Stat(fullPath)
 }
+
+// fullPath resolves a path relative to BasePath, returning a
+// *RejectedPathError if it leads outside BasePath. There is no symlink
+// policy as for LocalFSConfig: HDFS symlinks are disabled by default in
+// Hadoop, and where they are enabled the client does not resolve them, so
+// any path through one fails instead of leaving BasePath.
+func (h *HDFSConnector) fullPath(key string) (string, error) {
+	return confine(h.Config.BasePath, key)
+}
+
+// Glob returns the keys of the files matching a connectors.MatchGlob
+// pattern relative to BasePath, e.g. "logs/**/*.gz", in lexical order
+func (h *HDFSConnector) Glob(pattern string) ([]string, error) {
+	var keys []string
+	err := h.List(context.Background(), connectors.ListOptions{Prefix: connectors.GlobPrefix(pattern)}, func(page []connectors.ObjectInfo) error {
+		for _, object := range page {
+			ok, err := connectors.MatchGlob(pattern, object.Key)
+			if err != nil {
+				return err
+			}
+			if ok {
+				keys = append(keys, object.Key)
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// createFile creates a file with the configured replication and block
+// size, filling in the cluster's defaults
+func (h *HDFSConnector) createFile(fullPath string) (*hdfs.FileWriter, error) {
+	if h.Config.Replication <= 0 && h.Config.BlockSize <= 0 {
+		return h.client.Create(fullPath)
+	}
+	replication, blockSize := h.Config.Replication, h.Config.BlockSize
+	if replication <= 0 || blockSize <= 0 {
+		defaults, err := h.client.ServerDefaults()
+		if err != nil {
+			return nil, err
+		}
+		if replication <= 0 {
+			replication = defaults.Replication
+		}
+		if blockSize <= 0 {
+			blockSize = defaults.BlockSize
+		}
+	}
+	return h.client.CreateFile(fullPath, replication, blockSize, 0644)
+}
+
+// Append opens a file for appending, creating it and its directory if
+// needed. The data is visible to readers as it is written and complete
+// once Close returns nil.
+func (h *HDFSConnector) Append(key string) (io.WriteCloser, error) {
+	fullPath, err := h.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := h.client.Append(fullPath)
+	if err == nil {
+		return writer, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := h.client.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, err
+	}
+	return h.createFile(fullPath)
+}
+
+// List implements connectors.ObjectStore
+func (h *HDFSConnector) List(ctx context.Context, options connectors.ListOptions, fn func(page []connectors.ObjectInfo) error) error {
+	root, err := h.fullPath(listRoot(options.Prefix))
+	if err != nil {
+		return err
+	}
+	var objects []connectors.ObjectInfo
+	err = h.client.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(h.Config.BasePath, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); listable(key, options) {
+			objects = append(objects, fileObjectInfo(key, info))
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return pageObjects(objects, options, fn)
+}
+
+// Open implements connectors.ObjectStore
+func (h *HDFSConnector) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	fullPath, err := h.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := h.client.Open(fullPath)
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	return reader, nil
+}
+
+// Create implements connectors.ObjectStore. The file is written next to
+// its final path with the configured replication and block size, and
+// renamed into place by Close.
+func (h *HDFSConnector) Create(ctx context.Context, key string, options connectors.WriteOptions) (io.WriteCloser, error) {
+	fullPath, err := h.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.client.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, err
+	}
+	tmp := tempPath(fullPath)
+	writer, err := h.createFile(tmp)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{
+		Writer: writer,
+		ctx:    ctx,
+		close:  writer.Close,
+		commit: func() error { return h.client.Rename(tmp, fullPath) },
+		abort:  func() { h.client.Remove(tmp) },
+	}, nil
+}
+
+// Stat implements connectors.ObjectStore
+func (h *HDFSConnector) Stat(ctx context.Context, key string) (connectors.ObjectInfo, error) {
+	fullPath, err := h.fullPath(key)
+	if err != nil {
+		return connectors.ObjectInfo{}, err
+	}
+	info, err := h.client.Stat(fullPath)
+	if err != nil {
+		return connectors.ObjectInfo{}, notFound(key, err)
+	}
+	return fileObjectInfo(key, info), nil
+}
+
+// Delete implements connectors.ObjectStore
+func (h *HDFSConnector) Delete(ctx context.Context, key string) error {
+	fullPath, err := h.fullPath(key)
+	if err != nil {
+		return err
+	}
+	return notFound(key, h.client.Remove(fullPath))
+}
+
+// Copy implements connectors.ObjectStore. HDFS has no server-side copy, so
+// the data passes through this process.
+func (h *HDFSConnector) Copy(ctx context.Context, src, dst string) error {
+	return copyObject(ctx, h, src, dst)
+}
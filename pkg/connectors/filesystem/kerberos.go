@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	krb "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// KerberosConfig authenticates to a kerberized Hadoop cluster. The client
+// logs in with KeytabPath if set, else with Password, else with the
+// tickets in the credential cache, as after kinit.
+type KerberosConfig struct {
+	// ServicePrincipalName of the namenodes, as in the
+	// dfs.namenode.kerberos.principal property; "_HOST" stands for each
+	// namenode's host name. Defaults to "nn/_HOST"; a realm is ignored.
+	ServicePrincipalName string
+	// Username and Realm name the client principal for keytab and
+	// password logins
+	Username string
+	Realm    string
+	// KeytabPath is the keytab holding the principal's keys
+	KeytabPath string
+	Password   string
+	// CCachePath defaults to KRB5CCNAME, then /tmp/krb5cc_<uid>
+	CCachePath string
+	// ConfigPath is the krb5.conf to use; defaults to KRB5_CONFIG, then
+	// /etc/krb5.conf
+	ConfigPath string
+}
+
+// servicePrincipalName returns the SPN without its realm
+func (c KerberosConfig) servicePrincipalName() string {
+	spn := c.ServicePrincipalName
+	if spn == "" {
+		spn = "nn/_HOST"
+	}
+	return strings.SplitN(spn, "@", 2)[0]
+}
+
+// client logs in and returns a Kerberos client
+func (c KerberosConfig) client() (*krb.Client, error) {
+	configPath := c.ConfigPath
+	if configPath == "" {
+		configPath = os.Getenv("KRB5_CONFIG")
+	}
+	if configPath == "" {
+		configPath = "/etc/krb5.conf"
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kerberos config %s: %w", configPath, err)
+	}
+
+	var client *krb.Client
+	switch {
+	case c.KeytabPath != "":
+		kt, err := keytab.Load(c.KeytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keytab %s: %w", c.KeytabPath, err)
+		}
+		client = krb.NewWithKeytab(c.Username, c.Realm, kt, cfg)
+	case c.Password != "":
+		client = krb.NewWithPassword(c.Username, c.Realm, c.Password, cfg)
+	default:
+		ccachePath := c.CCachePath
+		if ccachePath == "" {
+			ccachePath = strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+		}
+		if ccachePath == "" {
+			ccachePath = fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+		}
+		ccache, err := credentials.LoadCCache(ccachePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credential cache %s: %w", ccachePath, err)
+		}
+		if client, err = krb.NewFromCCache(ccache, cfg); err != nil {
+			return nil, fmt.Errorf("failed to use credential cache %s: %w", ccachePath, err)
+		}
+		return client, nil
+	}
+
+	if err := client.Login(); err != nil {
+		return nil, fmt.Errorf("failed to log in to kerberos: %w", err)
+	}
+	return client, nil
+}
@@ -1,6 +1,8 @@
 package filesystem
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -15,6 +17,10 @@ type LocalFSConnector struct {
 
 type LocalFSConfig struct {
 	BasePath string
+	// Symlinks is SymlinksConfined (default), SymlinksFollow or
+	// SymlinksDeny, applied alike to keys, Glob and List. Paths that climb
+	// out of BasePath with ".." are always rejected.
+	Symlinks string
 }
 
 func NewLocalFSConnector(config LocalFSConfig) *LocalFSConnector {
@@ -30,6 +36,9 @@ func NewLocalFSConnector(config LocalFSConfig) *LocalFSConnector {
 }
 
 func (l *LocalFSConnector) Connect() error {
+	if !validSymlinkPolicy(l.Config.Symlinks) {
+		return fmt.Errorf("unsupported symlink policy: %s", l.Config.Symlinks)
+	}
 	// Verify base path exists and is accessible
 	_, err := os.Stat(l.Config.BasePath)
 	return err
@@ -55,19 +64,35 @@ func (l *LocalFSConnector) GetConfig() interface{} {
 }
 
 // Additional Local FS-specific methods
+
+// ListFiles returns the paths of the files below path, relative to
+// BasePath. Symlinks the symlink policy rejects are left out, and symlinked
+// directories are not descended into.
 func (l *LocalFSConnector) ListFiles(path string) ([]string, error) {
-	fullPath := filepath.Join(l.Config.BasePath, path)
+	fullPath, err := l.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
 	var files []string
 
-	err := filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			relPath, err := filepath.Rel(l.Config.BasePath, path)
-			if err != nil {
-				return err
+		relPath, err := filepath.Rel(l.Config.BasePath, path)
+		if err != nil {
+			return err
+		}
+		// Walk does not follow symlinks, so stat their targets
+		if info.Mode()&os.ModeSymlink != 0 {
+			if checkSymlinks(l.Config.Symlinks, l.Config.BasePath, filepath.ToSlash(relPath), path) != nil {
+				return nil
 			}
+			if info, err = os.Stat(path); err != nil {
+				return nil
+			}
+		}
+		if !info.IsDir() {
 			files = append(files, relPath)
 		}
 		return nil
@@ -77,12 +102,18 @@ func (l *LocalFSConnector) ListFiles(path string) ([]string, error) {
 }
 
 func (l *LocalFSConnector) ReadFile(path string) ([]byte, error) {
-	fullPath := filepath.Join(l.Config.BasePath, path)
+	fullPath, err := l.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
 	return os.ReadFile(fullPath)
 }
 
 func (l *LocalFSConnector) WriteFile(path string, data []byte) error {
-	fullPath := filepath.Join(l.Config.BasePath, path)
+	fullPath, err := l.fullPath(path)
+	if err != nil {
+		return err
+	}
 
 	// Ensure directory exists
 	dir := filepath.Dir(fullPath)
@@ -94,8 +125,14 @@ func (l *LocalFSConnector) WriteFile(path string, data []byte) error {
 }
 
 func (l *LocalFSConnector) CopyFile(src, dst string) error {
-	srcPath := filepath.Join(l.Config.BasePath, src)
-	dstPath := filepath.Join(l.Config.BasePath, dst)
+	srcPath, err := l.fullPath(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := l.fullPath(dst)
+	if err != nil {
+		return err
+	}
 
 	sourceFile, err := os.Open(srcPath)
 	if err != nil {
@@ -120,12 +157,194 @@ func (l *LocalFSConnector) CopyFile(src, dst string) error {
 }
 
 func (l *LocalFSConnector) DeleteFile(path string) error {
-	fullPath := filepath.Join(l.Config.BasePath, path)
+	fullPath, err := l.fullPath(path)
+	if err != nil {
+		return err
+	}
 	return os.Remove(fullPath)
 }
 
 func (l *LocalFSConnector) FileExists(path string) bool {
-	fullPath := filepath.Join(l.Config.BasePath, path)
-	_, err := os.Stat(fullPath)
+	fullPath, err := l.fullPath(path)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(fullPath)
 	return err == nil
 }
+
+// fullPath resolves a path relative to BasePath, returning a
+// *RejectedPathError if it leads outside BasePath or breaks the symlink
+// policy
+func (l *LocalFSConnector) fullPath(key string) (string, error) {
+	fullPath, err := confine(l.Config.BasePath, key)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSymlinks(l.Config.Symlinks, l.Config.BasePath, key, fullPath); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// Glob returns the keys of the files matching a filepath.Match pattern
+// relative to BasePath, e.g. "logs/*.log", in lexical order. Files the
+// symlink policy rejects are left out.
+func (l *LocalFSConnector) Glob(pattern string) ([]string, error) {
+	fullPattern, err := confine(l.Config.BasePath, pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(fullPattern)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(matches))
+	for _, match := range matches {
+		rel, err := filepath.Rel(l.Config.BasePath, match)
+		if err != nil {
+			return nil, err
+		}
+		key := filepath.ToSlash(rel)
+		if isTempFile(key) || checkSymlinks(l.Config.Symlinks, l.Config.BasePath, key, match) != nil {
+			continue
+		}
+		info, err := os.Stat(match)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// OpenFile opens a file for reading, for callers that need to seek or stat
+// it, such as tailing readers
+func (l *LocalFSConnector) OpenFile(key string) (*os.File, error) {
+	fullPath, err := l.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	return file, nil
+}
+
+// FullPath returns the filesystem path of a key, or a *RejectedPathError
+func (l *LocalFSConnector) FullPath(key string) (string, error) {
+	return l.fullPath(key)
+}
+
+// List implements connectors.ObjectStore. Symlinked files are listed when
+// the symlink policy allows them, as Glob does; symlinked directories are
+// not descended into.
+func (l *LocalFSConnector) List(ctx context.Context, options connectors.ListOptions, fn func(page []connectors.ObjectInfo) error) error {
+	root, err := l.fullPath(listRoot(options.Prefix))
+	if err != nil {
+		return err
+	}
+	var objects []connectors.ObjectInfo
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(l.Config.BasePath, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		// Walk does not follow symlinks, so stat their targets
+		if info.Mode()&os.ModeSymlink != 0 {
+			if checkSymlinks(l.Config.Symlinks, l.Config.BasePath, key, path) != nil {
+				return nil
+			}
+			if info, err = os.Stat(path); err != nil {
+				return nil
+			}
+		}
+		if info.Mode().IsRegular() && listable(key, options) {
+			objects = append(objects, fileObjectInfo(key, info))
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return pageObjects(objects, options, fn)
+}
+
+// Open implements connectors.ObjectStore
+func (l *LocalFSConnector) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	fullPath, err := l.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	return file, nil
+}
+
+// Create implements connectors.ObjectStore. The file is written next to
+// its final path and renamed into place by Close.
+func (l *LocalFSConnector) Create(ctx context.Context, key string, options connectors.WriteOptions) (io.WriteCloser, error) {
+	fullPath, err := l.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(fullPath), tempPattern(fullPath))
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{
+		Writer: file,
+		ctx:    ctx,
+		close:  file.Close,
+		commit: func() error {
+			if err := os.Chmod(file.Name(), 0644); err != nil {
+				return err
+			}
+			return os.Rename(file.Name(), fullPath)
+		},
+		abort: func() { os.Remove(file.Name()) },
+	}, nil
+}
+
+// Stat implements connectors.ObjectStore
+func (l *LocalFSConnector) Stat(ctx context.Context, key string) (connectors.ObjectInfo, error) {
+	fullPath, err := l.fullPath(key)
+	if err != nil {
+		return connectors.ObjectInfo{}, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return connectors.ObjectInfo{}, notFound(key, err)
+	}
+	return fileObjectInfo(key, info), nil
+}
+
+// Delete implements connectors.ObjectStore
+func (l *LocalFSConnector) Delete(ctx context.Context, key string) error {
+	fullPath, err := l.fullPath(key)
+	if err != nil {
+		return err
+	}
+	return notFound(key, os.Remove(fullPath))
+}
+
+// Copy implements connectors.ObjectStore
+func (l *LocalFSConnector) Copy(ctx context.Context, src, dst string) error {
+	return copyObject(ctx, l, src, dst)
+}
@@ -0,0 +1,144 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors"
+)
+
+// Helpers shared by the ObjectStore implementations of the filesystem
+// connectors. Keys are paths relative to BasePath with "/" separators.
+
+// listRoot returns the directory to walk for a key prefix, which may end
+// in the middle of a file or directory name
+func listRoot(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	if dir := path.Dir(prefix); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+func fileObjectInfo(key string, info os.FileInfo) connectors.ObjectInfo {
+	return connectors.ObjectInfo{
+		Key:     key,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		ETag:    fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+	}
+}
+
+// pageObjects sorts objects by key, as the object stores list them, and
+// passes those after options.StartAfter to fn in pages
+func pageObjects(objects []connectors.ObjectInfo, options connectors.ListOptions, fn func(page []connectors.ObjectInfo) error) error {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	if options.StartAfter != "" {
+		n := sort.Search(len(objects), func(i int) bool { return objects[i].Key > options.StartAfter })
+		objects = objects[n:]
+	}
+
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+	for len(objects) > 0 {
+		n := pageSize
+		if n > len(objects) {
+			n = len(objects)
+		}
+		if err := fn(objects[:n]); err != nil {
+			return err
+		}
+		objects = objects[n:]
+	}
+	return nil
+}
+
+// listable reports whether a walked file belongs in a listing
+func listable(key string, options connectors.ListOptions) bool {
+	return strings.HasPrefix(key, options.Prefix) && !isTempFile(key)
+}
+
+// Files being written by Create are named ".<name>.tmp-<random>" next to
+// their final path, and renamed once complete
+const tempInfix = ".tmp-"
+
+func tempPattern(fullPath string) string {
+	return "." + path.Base(fullPath) + tempInfix + "*"
+}
+
+// tempPath names a temporary file for fullPath where CreateTemp is not
+// available
+func tempPath(fullPath string) string {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return path.Join(path.Dir(fullPath), strings.TrimSuffix(tempPattern(fullPath), "*")+hex.EncodeToString(suffix))
+}
+
+func isTempFile(key string) bool {
+	base := path.Base(key)
+	return strings.HasPrefix(base, ".") && strings.Contains(base, tempInfix)
+}
+
+func notFound(key string, err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s: %v", connectors.ErrObjectNotFound, key, err)
+	}
+	return err
+}
+
+// fileWriter writes to a temporary file and renames it into place on Close
+// unless the write failed or its context was cancelled
+type fileWriter struct {
+	io.Writer
+	ctx    context.Context
+	close  func() error
+	commit func() error
+	abort  func()
+}
+
+func (w *fileWriter) Close() error {
+	err := w.close()
+	if err == nil {
+		err = w.ctx.Err()
+	}
+	if err == nil {
+		err = w.commit()
+	}
+	if err != nil {
+		w.abort()
+	}
+	return err
+}
+
+// copyObject copies an object by streaming it through this process
+func copyObject(ctx context.Context, store connectors.ObjectStore, src, dst string) error {
+	reader, err := store.Open(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	writer, err := store.Create(ctx, dst, connectors.WriteOptions{})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		cancel()
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Symlink policies for LocalFSConfig.Symlinks
+const (
+	// SymlinksConfined follows symlinks only while the path they resolve
+	// to stays inside BasePath; it is the default
+	SymlinksConfined = "confined"
+	// SymlinksFollow follows every symlink, wherever it points
+	SymlinksFollow = "follow"
+	// SymlinksDeny rejects any path through a symlink below BasePath
+	SymlinksDeny = "deny"
+)
+
+var (
+	// ErrPathEscapesBase is the cause of a RejectedPathError for a path
+	// that leads outside BasePath, with ".." or through a symlink
+	ErrPathEscapesBase = errors.New("path escapes base path")
+	// ErrSymlinkNotAllowed is the cause of a RejectedPathError for a path
+	// through a symlink when symlinks are denied
+	ErrSymlinkNotAllowed = errors.New("symlink not allowed")
+)
+
+// RejectedPathError is returned for a path a filesystem connector refuses
+// to use. Paths are taken from configs and record fields, so they are
+// confined to BasePath. Use errors.Is with ErrPathEscapesBase or
+// ErrSymlinkNotAllowed to tell the causes apart.
+type RejectedPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *RejectedPathError) Error() string {
+	return fmt.Sprintf("rejected path %q: %v", e.Path, e.Err)
+}
+
+func (e *RejectedPathError) Unwrap() error {
+	return e.Err
+}
+
+// confine joins a "/"-separated path onto base, rejecting paths that climb
+// out of it. Absolute paths are taken as relative to base.
+func confine(base, key string) (string, error) {
+	full := filepath.Join(base, filepath.FromSlash(key))
+	if !within(base, full) {
+		return "", &RejectedPathError{Path: key, Err: ErrPathEscapesBase}
+	}
+	return full, nil
+}
+
+// within reports whether target is base or below it; both must be clean
+// and either both absolute or both relative
+func within(base, target string) bool {
+	rel, err := filepath.Rel(base, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkSymlinks applies a symlink policy to full, a confined path under
+// base. Symlinks can change after the check, so it guards against paths
+// from configs and records, not against processes racing to swap links.
+func checkSymlinks(policy, base, key, full string) error {
+	switch policy {
+	case SymlinksFollow:
+		return nil
+	case SymlinksDeny:
+		rel, err := filepath.Rel(base, full)
+		if err != nil || rel == "." {
+			return err
+		}
+		current := base
+		for _, element := range strings.Split(rel, string(filepath.Separator)) {
+			current = filepath.Join(current, element)
+			info, err := os.Lstat(current)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				return &RejectedPathError{Path: key, Err: ErrSymlinkNotAllowed}
+			}
+		}
+		return nil
+	default:
+		resolvedBase, err := filepath.EvalSymlinks(base)
+		if err != nil {
+			return err
+		}
+		resolved, err := resolveExisting(full)
+		if err != nil {
+			return err
+		}
+		if !within(resolvedBase, resolved) {
+			return &RejectedPathError{Path: key, Err: ErrPathEscapesBase}
+		}
+		return nil
+	}
+}
+
+// resolveExisting resolves the symlinks of the longest part of a path
+// that exists, for paths about to be created
+func resolveExisting(full string) (string, error) {
+	rest := ""
+	current := full
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// A dangling symlink still decides where a file is created
+		if target, err := os.Readlink(current); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(current), target)
+			}
+			return resolveExisting(filepath.Join(target, rest))
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return full, nil
+		}
+		rest = filepath.Join(filepath.Base(current), rest)
+		current = parent
+	}
+}
+
+func validSymlinkPolicy(policy string) bool {
+	switch policy {
+	case "", SymlinksConfined, SymlinksFollow, SymlinksDeny:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newTree creates a base directory and a directory outside it, linked as:
+//
+//	base/in/f
+//	base/inner     -> in/f
+//	base/outer     -> outside/secret
+//	base/outdir    -> outside
+//	base/dangling  -> outside/missing
+func newTree(t *testing.T) (base, outside string) {
+	t.Helper()
+	root := t.TempDir()
+	base = filepath.Join(root, "base")
+	outside = filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(base, "in"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(base, "in", "f"), filepath.Join(outside, "secret")} {
+		if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"inner":    filepath.Join("in", "f"),
+		"outer":    filepath.Join(outside, "secret"),
+		"outdir":   outside,
+		"dangling": filepath.Join(outside, "missing"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return base, outside
+}
+
+func TestConfine(t *testing.T) {
+	base := filepath.Join(string(filepath.Separator), "data")
+	tests := []struct {
+		key     string
+		want    string
+		escapes bool
+	}{
+		{key: "a/b.json", want: filepath.Join(base, "a", "b.json")},
+		{key: "a/../b.json", want: filepath.Join(base, "b.json")},
+		{key: "", want: base},
+		{key: "/etc/passwd", want: filepath.Join(base, "etc", "passwd")},
+		{key: "..", escapes: true},
+		{key: "../data2/x", escapes: true},
+		{key: "a/../../x", escapes: true},
+	}
+	for _, tt := range tests {
+		got, err := confine(base, tt.key)
+		if tt.escapes {
+			if !errors.Is(err, ErrPathEscapesBase) {
+				t.Errorf("confine(%q) error = %v, want ErrPathEscapesBase", tt.key, err)
+			}
+			var rejected *RejectedPathError
+			if !errors.As(err, &rejected) || rejected.Path != tt.key {
+				t.Errorf("confine(%q) error = %#v, want a RejectedPathError for the key", tt.key, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("confine(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckSymlinks(t *testing.T) {
+	base, _ := newTree(t)
+	tests := []struct {
+		policy string
+		key    string
+		want   error
+	}{
+		{policy: SymlinksConfined, key: "in/f"},
+		{policy: SymlinksConfined, key: "inner"},
+		{policy: SymlinksConfined, key: "missing/new"},
+		{policy: SymlinksConfined, key: "outer", want: ErrPathEscapesBase},
+		{policy: SymlinksConfined, key: "outdir/secret", want: ErrPathEscapesBase},
+		{policy: SymlinksConfined, key: "outdir/new/file", want: ErrPathEscapesBase},
+		{policy: SymlinksConfined, key: "dangling", want: ErrPathEscapesBase},
+		// The empty policy is the default, confined
+		{policy: "", key: "outer", want: ErrPathEscapesBase},
+		{policy: SymlinksFollow, key: "outer"},
+		{policy: SymlinksFollow, key: "outdir/new/file"},
+		{policy: SymlinksFollow, key: "dangling"},
+		{policy: SymlinksDeny, key: "in/f"},
+		{policy: SymlinksDeny, key: "missing/new"},
+		{policy: SymlinksDeny, key: ""},
+		{policy: SymlinksDeny, key: "inner", want: ErrSymlinkNotAllowed},
+		{policy: SymlinksDeny, key: "outer", want: ErrSymlinkNotAllowed},
+		{policy: SymlinksDeny, key: "outdir/secret", want: ErrSymlinkNotAllowed},
+		{policy: SymlinksDeny, key: "dangling", want: ErrSymlinkNotAllowed},
+	}
+	for _, tt := range tests {
+		full, err := confine(base, tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = checkSymlinks(tt.policy, base, tt.key, full)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("checkSymlinks(%q, %q) = %v, want nil", tt.policy, tt.key, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("checkSymlinks(%q, %q) = %v, want %v", tt.policy, tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestResolveExisting(t *testing.T) {
+	base, outside := newTree(t)
+	resolvedBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolvedOutside, err := filepath.EvalSymlinks(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: filepath.Join(base, "in", "f"), want: filepath.Join(resolvedBase, "in", "f")},
+		{path: filepath.Join(base, "inner"), want: filepath.Join(resolvedBase, "in", "f")},
+		{path: filepath.Join(base, "missing", "new"), want: filepath.Join(resolvedBase, "missing", "new")},
+		{path: filepath.Join(base, "outdir", "new", "file"), want: filepath.Join(resolvedOutside, "new", "file")},
+		{path: filepath.Join(base, "dangling"), want: filepath.Join(resolvedOutside, "missing")},
+		{path: filepath.Join(base, "dangling", "child"), want: filepath.Join(resolvedOutside, "missing", "child")},
+	}
+	for _, tt := range tests {
+		got, err := resolveExisting(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveExisting(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestListFilesSymlinks(t *testing.T) {
+	base, _ := newTree(t)
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: SymlinksConfined, want: []string{filepath.Join("in", "f"), "inner"}},
+		{policy: SymlinksDeny, want: []string{filepath.Join("in", "f")}},
+		// Symlinked directories are not descended into, whatever the policy
+		{policy: SymlinksFollow, want: []string{filepath.Join("in", "f"), "inner", "outer"}},
+	}
+	for _, tt := range tests {
+		fs := NewLocalFSConnector(LocalFSConfig{BasePath: base, Symlinks: tt.policy})
+		got, err := fs.ListFiles("")
+		if err != nil {
+			t.Fatalf("ListFiles with %q: %v", tt.policy, err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListFiles with %q = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
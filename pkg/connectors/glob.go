@@ -0,0 +1,57 @@
+package connectors
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether key matches pattern. Patterns use path.Match
+// syntax for each "/"-separated element, and an element that is exactly
+// "**" matches any number of elements, including none, so
+// "logs/**/*.gz" matches both "logs/a.gz" and "logs/2024/01/a.gz".
+func MatchGlob(pattern, key string) (bool, error) {
+	return matchElements(strings.Split(pattern, "/"), strings.Split(key, "/"))
+}
+
+func matchElements(pattern, key []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated "**" and try every split of key
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true, nil
+			}
+			for i := range key {
+				if ok, err := matchElements(pattern, key[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(key) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], key[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, key = pattern[1:], key[1:]
+	}
+	return len(key) == 0, nil
+}
+
+// GlobPrefix returns the directories at the start of pattern that contain
+// no wildcards, ending in "/", so listings can start there
+func GlobPrefix(pattern string) string {
+	elements := strings.Split(pattern, "/")
+	prefix := ""
+	for _, element := range elements[:len(elements)-1] {
+		if strings.ContainsAny(element, `*?[\`) {
+			break
+		}
+		prefix += element + "/"
+	}
+	return prefix
+}
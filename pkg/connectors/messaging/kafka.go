@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"fmt"
+
 	"github.com/IBM/sarama"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
 )
@@ -33,17 +35,17 @@ func NewKafkaConnector(config KafkaConfig) *KafkaConnector {
 func (k *KafkaConnector) Connect() error {
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
-	
+
 	producer, err := sarama.NewSyncProducer(k.Config.Brokers, config)
 	if err != nil {
 		return err
 	}
-	
+
 	consumer, err := sarama.NewConsumer(k.Config.Brokers, config)
 	if err != nil {
 		return err
 	}
-	
+
 	k.producer = producer
 	k.consumer = consumer
 	return nil
@@ -69,10 +71,44 @@ func (k *KafkaConnector) Read() (interface{}, error) {
 }
 
 func (k *KafkaConnector) Write(data interface{}) error {
-	// Implement write logic
-	return nil
+	if k.producer == nil {
+		return fmt.Errorf("kafka connector is not connected")
+	}
+
+	switch msg := data.(type) {
+	case *sarama.ProducerMessage:
+		_, _, err := k.producer.SendMessage(msg)
+		return err
+	case []*sarama.ProducerMessage:
+		return k.producer.SendMessages(msg)
+	case []byte:
+		_, _, err := k.producer.SendMessage(&sarama.ProducerMessage{
+			Topic: k.Config.Topic,
+			Value: sarama.ByteEncoder(msg),
+		})
+		return err
+	case string:
+		_, _, err := k.producer.SendMessage(&sarama.ProducerMessage{
+			Topic: k.Config.Topic,
+			Value: sarama.StringEncoder(msg),
+		})
+		return err
+	default:
+		return fmt.Errorf("unsupported kafka message type %T", data)
+	}
 }
 
 func (k *KafkaConnector) GetConfig() interface{} {
 	return k.Config
 }
+
+// Additional Kafka-specific methods
+
+// NewSyncProducer creates a producer for the configured brokers using a
+// caller-supplied sarama configuration, e.g. for idempotent or transactional
+// producers that need settings Connect does not apply
+func (k *KafkaConnector) NewSyncProducer(config *sarama.Config) (sarama.SyncProducer, error) {
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	return sarama.NewSyncProducer(k.Config.Brokers, config)
+}
@@ -0,0 +1,159 @@
+package messaging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Record metadata keys used for Kafka-origin records
+const (
+	KafkaMetaTopic         = "kafka.topic"
+	KafkaMetaPartition     = "kafka.partition"
+	KafkaMetaOffset        = "kafka.offset"
+	KafkaMetaKey           = "kafka.key"
+	KafkaMetaHeaders       = "kafka.headers"
+	KafkaMetaLeaderEpoch   = "kafka.leader_epoch"
+	KafkaMetaHighWaterMark = "kafka.high_water_mark"
+	KafkaMetaTimestamp     = "kafka.timestamp"
+)
+
+// Record metadata keys Kafka sources wrote before the kafka.* keys. Sources
+// still set them so existing filters and templates keep working.
+//
+// Deprecated: use KafkaMetaTopic, KafkaMetaPartition and KafkaMetaOffset.
+const (
+	KafkaMetaLegacyTopic     = "topic"
+	KafkaMetaLegacyPartition = "partition"
+	KafkaMetaLegacyOffset    = "offset"
+)
+
+// KafkaHeader is a single Kafka record header
+type KafkaHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// KafkaMetadata describes where a record was read from in Kafka. It is
+// stored in pipeline.Record.Metadata so that sinks and checkpointing can
+// recover the exact position, key and headers of the original message.
+type KafkaMetadata struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	// Key holds the raw message key, which need not be valid UTF-8
+	Key []byte
+	// Headers keeps the original order and any duplicate header keys
+	Headers []KafkaHeader
+	// LeaderEpoch is -1 when the client library does not report it
+	LeaderEpoch   int32
+	HighWaterMark int64
+	Timestamp     time.Time
+}
+
+// Encode renders the metadata as Record.Metadata entries. Binary values are
+// base64 encoded and headers are stored as a JSON array.
+func (m KafkaMetadata) Encode() map[string]string {
+	meta := map[string]string{
+		KafkaMetaTopic:     m.Topic,
+		KafkaMetaPartition: strconv.Itoa(m.Partition),
+		KafkaMetaOffset:    strconv.FormatInt(m.Offset, 10),
+	}
+	if m.Key != nil {
+		meta[KafkaMetaKey] = base64.StdEncoding.EncodeToString(m.Key)
+	}
+	if len(m.Headers) > 0 {
+		// Marshalling []KafkaHeader cannot fail
+		headers, _ := json.Marshal(m.Headers)
+		meta[KafkaMetaHeaders] = string(headers)
+	}
+	if m.LeaderEpoch >= 0 {
+		meta[KafkaMetaLeaderEpoch] = strconv.FormatInt(int64(m.LeaderEpoch), 10)
+	}
+	if m.HighWaterMark > 0 {
+		meta[KafkaMetaHighWaterMark] = strconv.FormatInt(m.HighWaterMark, 10)
+	}
+	if !m.Timestamp.IsZero() {
+		meta[KafkaMetaTimestamp] = m.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	return meta
+}
+
+// EncodeInto adds the metadata entries to an existing metadata map
+func (m KafkaMetadata) EncodeInto(meta map[string]string) {
+	for k, v := range m.Encode() {
+		meta[k] = v
+	}
+}
+
+// EncodeLegacyKafkaMetadata adds the legacy topic, partition and offset
+// entries to meta
+func EncodeLegacyKafkaMetadata(m KafkaMetadata, meta map[string]string) {
+	meta[KafkaMetaLegacyTopic] = m.Topic
+	meta[KafkaMetaLegacyPartition] = strconv.Itoa(m.Partition)
+	meta[KafkaMetaLegacyOffset] = strconv.FormatInt(m.Offset, 10)
+}
+
+// HasKafkaMetadata reports whether a metadata map carries a Kafka position
+func HasKafkaMetadata(meta map[string]string) bool {
+	_, hasTopic := meta[KafkaMetaTopic]
+	_, hasOffset := meta[KafkaMetaOffset]
+	return hasTopic && hasOffset
+}
+
+// DecodeKafkaMetadata parses metadata written by KafkaMetadata.Encode
+func DecodeKafkaMetadata(meta map[string]string) (KafkaMetadata, error) {
+	m := KafkaMetadata{LeaderEpoch: -1}
+	if !HasKafkaMetadata(meta) {
+		return m, fmt.Errorf("record has no kafka metadata")
+	}
+	m.Topic = meta[KafkaMetaTopic]
+
+	var err error
+	if m.Partition, err = strconv.Atoi(meta[KafkaMetaPartition]); err != nil {
+		return m, fmt.Errorf("invalid %s: %w", KafkaMetaPartition, err)
+	}
+	if m.Offset, err = strconv.ParseInt(meta[KafkaMetaOffset], 10, 64); err != nil {
+		return m, fmt.Errorf("invalid %s: %w", KafkaMetaOffset, err)
+	}
+	if v, ok := meta[KafkaMetaKey]; ok {
+		if m.Key, err = base64.StdEncoding.DecodeString(v); err != nil {
+			return m, fmt.Errorf("invalid %s: %w", KafkaMetaKey, err)
+		}
+	}
+	if v, ok := meta[KafkaMetaHeaders]; ok {
+		if err := json.Unmarshal([]byte(v), &m.Headers); err != nil {
+			return m, fmt.Errorf("invalid %s: %w", KafkaMetaHeaders, err)
+		}
+	}
+	if v, ok := meta[KafkaMetaLeaderEpoch]; ok {
+		epoch, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return m, fmt.Errorf("invalid %s: %w", KafkaMetaLeaderEpoch, err)
+		}
+		m.LeaderEpoch = int32(epoch)
+	}
+	if v, ok := meta[KafkaMetaHighWaterMark]; ok {
+		if m.HighWaterMark, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return m, fmt.Errorf("invalid %s: %w", KafkaMetaHighWaterMark, err)
+		}
+	}
+	if v, ok := meta[KafkaMetaTimestamp]; ok {
+		if m.Timestamp, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return m, fmt.Errorf("invalid %s: %w", KafkaMetaTimestamp, err)
+		}
+	}
+	return m, nil
+}
+
+// Header returns the value of the first header with the given key
+func (m KafkaMetadata) Header(key string) ([]byte, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,298 @@
+// Package messagingtest provides fakes of messaging services for tests
+package messagingtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+)
+
+// SchemaRegistry is an in-process registry implementing the subset of the
+// Confluent REST API used by messaging.SchemaRegistryConnector.
+// Compatibility is enforced for Avro schemas; other schema types are
+// always compatible.
+type SchemaRegistry struct {
+	mu            sync.Mutex
+	schemas       []messaging.RegistrySchema
+	subjects      map[string][]int
+	compatibility map[string]string
+	global        string
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewSchemaRegistry creates an empty fake registry with BACKWARD
+// compatibility
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		subjects:      make(map[string][]int),
+		compatibility: make(map[string]string),
+		global:        messaging.CompatibilityBackward,
+	}
+}
+
+// Start serves the registry on a random loopback port and returns its URL
+func (f *SchemaRegistry) Start() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to listen: %w", err)
+	}
+	f.listener = listener
+	f.server = &http.Server{Handler: f}
+	go f.server.Serve(listener)
+	return "http://" + listener.Addr().String(), nil
+}
+
+// Close stops a started registry
+func (f *SchemaRegistry) Close() error {
+	if f.server == nil {
+		return nil
+	}
+	return f.server.Close()
+}
+
+// ServeHTTP implements http.Handler
+func (f *SchemaRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodGet && len(parts) == 1 && parts[0] == "subjects":
+		subjects := make([]string, 0, len(f.subjects))
+		for subject := range f.subjects {
+			subjects = append(subjects, subject)
+		}
+		sort.Strings(subjects)
+		writeRegistryJSON(w, http.StatusOK, subjects)
+
+	case req.Method == http.MethodGet && len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		id, err := strconv.Atoi(parts[2])
+		if err != nil || id < 1 || id > len(f.schemas) {
+			writeRegistryError(w, http.StatusNotFound, messaging.RegistryErrSchemaNotFound, "Schema not found")
+			return
+		}
+		schema := f.schemas[id-1]
+		writeRegistryJSON(w, http.StatusOK, messaging.RegistrySchema{
+			SchemaType: schema.SchemaType,
+			Schema:     schema.Schema,
+			References: schema.References,
+		})
+
+	case len(parts) == 4 && parts[0] == "subjects" && parts[2] == "versions" && req.Method == http.MethodGet:
+		schema, status, code := f.version(parts[1], parts[3])
+		if status != http.StatusOK {
+			writeRegistryError(w, status, code, "Subject or version not found")
+			return
+		}
+		writeRegistryJSON(w, http.StatusOK, schema)
+
+	case len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions" && req.Method == http.MethodPost:
+		f.register(w, req, parts[1])
+
+	case len(parts) == 5 && parts[0] == "compatibility" && parts[1] == "subjects" && req.Method == http.MethodPost:
+		schema, ok := decodeRegistrySchema(w, req)
+		if !ok {
+			return
+		}
+		if _, status, code := f.version(parts[2], parts[4]); status != http.StatusOK {
+			writeRegistryError(w, status, code, "Subject or version not found")
+			return
+		}
+		messages := f.incompatibilities(parts[2], schema)
+		writeRegistryJSON(w, http.StatusOK, map[string]interface{}{
+			"is_compatible": len(messages) == 0,
+			"messages":      messages,
+		})
+
+	case len(parts) <= 2 && parts[0] == "config":
+		f.config(w, req, parts[1:])
+
+	default:
+		writeRegistryError(w, http.StatusNotFound, http.StatusNotFound, "Not found")
+	}
+}
+
+func (f *SchemaRegistry) register(w http.ResponseWriter, req *http.Request, subject string) {
+	schema, ok := decodeRegistrySchema(w, req)
+	if !ok {
+		return
+	}
+	if schema.SchemaType == "AVRO" || schema.SchemaType == "" {
+		if _, err := avro.Parse(schema.Schema); err != nil {
+			writeRegistryError(w, http.StatusUnprocessableEntity, messaging.RegistryErrInvalidSchema, err.Error())
+			return
+		}
+	}
+
+	for _, id := range f.subjects[subject] {
+		if sameRegistrySchema(f.schemas[id-1], schema) {
+			writeRegistryJSON(w, http.StatusOK, map[string]int{"id": id})
+			return
+		}
+	}
+	if messages := f.incompatibilities(subject, schema); len(messages) > 0 {
+		writeRegistryError(w, http.StatusConflict, http.StatusConflict, strings.Join(messages, "; "))
+		return
+	}
+
+	// Identical schemas share an ID across subjects
+	id := 0
+	for i, existing := range f.schemas {
+		if sameRegistrySchema(existing, schema) {
+			id = i + 1
+			break
+		}
+	}
+	if id == 0 {
+		f.schemas = append(f.schemas, schema)
+		id = len(f.schemas)
+	}
+	f.subjects[subject] = append(f.subjects[subject], id)
+	writeRegistryJSON(w, http.StatusOK, map[string]int{"id": id})
+}
+
+// version resolves a version number or "latest" within subject
+func (f *SchemaRegistry) version(subject, version string) (messaging.RegistrySchema, int, int) {
+	ids, ok := f.subjects[subject]
+	if !ok {
+		return messaging.RegistrySchema{}, http.StatusNotFound, messaging.RegistryErrSubjectNotFound
+	}
+	n := len(ids)
+	if version != "latest" {
+		v, err := strconv.Atoi(version)
+		if err != nil || v < 1 || v > len(ids) {
+			return messaging.RegistrySchema{}, http.StatusNotFound, messaging.RegistryErrVersionNotFound
+		}
+		n = v
+	}
+	schema := f.schemas[ids[n-1]-1]
+	schema.ID = ids[n-1]
+	schema.Subject = subject
+	schema.Version = n
+	return schema, http.StatusOK, 0
+}
+
+func (f *SchemaRegistry) config(w http.ResponseWriter, req *http.Request, subject []string) {
+	switch req.Method {
+	case http.MethodGet:
+		level := f.global
+		if len(subject) == 1 {
+			if l, ok := f.compatibility[subject[0]]; ok {
+				level = l
+			} else if req.URL.Query().Get("defaultToGlobal") != "true" {
+				writeRegistryError(w, http.StatusNotFound, messaging.RegistryErrSubjectNotFound, "Subject not found")
+				return
+			}
+		}
+		writeRegistryJSON(w, http.StatusOK, map[string]string{"compatibilityLevel": level})
+	case http.MethodPut:
+		var body struct {
+			Compatibility string `json:"compatibility"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeRegistryError(w, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(subject) == 1 {
+			f.compatibility[subject[0]] = body.Compatibility
+		} else {
+			f.global = body.Compatibility
+		}
+		writeRegistryJSON(w, http.StatusOK, body)
+	default:
+		writeRegistryError(w, http.StatusMethodNotAllowed, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// incompatibilities checks schema against the versions of subject that its
+// compatibility level covers
+func (f *SchemaRegistry) incompatibilities(subject string, schema messaging.RegistrySchema) []string {
+	ids := f.subjects[subject]
+	if len(ids) == 0 || (schema.SchemaType != "" && schema.SchemaType != "AVRO") {
+		return nil
+	}
+	level, ok := f.compatibility[subject]
+	if !ok {
+		level = f.global
+	}
+	if level == messaging.CompatibilityNone {
+		return nil
+	}
+	if !strings.HasSuffix(level, "_TRANSITIVE") {
+		ids = ids[len(ids)-1:]
+	}
+
+	next, err := avro.Parse(schema.Schema)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var messages []string
+	compat := avro.NewSchemaCompatibility()
+	for _, id := range ids {
+		prev, err := avro.Parse(f.schemas[id-1].Schema)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(level, "BACKWARD") || strings.HasPrefix(level, "FULL") {
+			if err := compat.Compatible(next, prev); err != nil {
+				messages = append(messages, fmt.Sprintf("cannot read schema %d: %v", id, err))
+			}
+		}
+		if strings.HasPrefix(level, "FORWARD") || strings.HasPrefix(level, "FULL") {
+			if err := compat.Compatible(prev, next); err != nil {
+				messages = append(messages, fmt.Sprintf("schema %d cannot read new schema: %v", id, err))
+			}
+		}
+	}
+	return messages
+}
+
+func sameRegistrySchema(a, b messaging.RegistrySchema) bool {
+	typeA, typeB := a.SchemaType, b.SchemaType
+	if typeA == "" {
+		typeA = "AVRO"
+	}
+	if typeB == "" {
+		typeB = "AVRO"
+	}
+	if typeA != typeB {
+		return false
+	}
+	if typeA == "AVRO" {
+		sa, errA := avro.Parse(a.Schema)
+		sb, errB := avro.Parse(b.Schema)
+		if errA == nil && errB == nil {
+			return sa.Fingerprint() == sb.Fingerprint()
+		}
+	}
+	return a.Schema == b.Schema
+}
+
+func decodeRegistrySchema(w http.ResponseWriter, req *http.Request) (messaging.RegistrySchema, bool) {
+	var schema messaging.RegistrySchema
+	if err := json.NewDecoder(req.Body).Decode(&schema); err != nil {
+		writeRegistryError(w, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		return schema, false
+	}
+	return schema, true
+}
+
+func writeRegistryJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeRegistryError(w http.ResponseWriter, status, code int, message string) {
+	writeRegistryJSON(w, status, &messaging.RegistryError{ErrorCode: code, Message: message})
+}
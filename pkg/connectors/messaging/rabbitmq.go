@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"fmt"
+
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
 	"github.com/streadway/amqp"
 )
@@ -18,6 +20,9 @@ type RabbitMQConfig struct {
 	ExchangeType string
 	Queue        string
 	RoutingKey   string
+	// QueueArgs are passed to the queue declaration, e.g.
+	// "x-dead-letter-exchange"
+	QueueArgs amqp.Table
 }
 
 func NewRabbitMQConnector(config RabbitMQConfig) *RabbitMQConnector {
@@ -40,32 +45,54 @@ func (r *RabbitMQConnector) Connect() error {
 
 	ch, err := conn.Channel()
 	if err != nil {
+		conn.Close()
 		return err
 	}
 
-	err = ch.ExchangeDeclare(
-		r.Config.Exchange,
-		r.Config.ExchangeType,
-		true,  // durable
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // arguments
-	)
-	if err != nil {
-		return err
+	// The default exchange cannot be declared or bound to
+	if r.Config.Exchange != "" {
+		err = ch.ExchangeDeclare(
+			r.Config.Exchange,
+			r.Config.ExchangeType,
+			true,  // durable
+			false, // auto-deleted
+			false, // internal
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			conn.Close()
+			return err
+		}
 	}
 
-	_, err = ch.QueueDeclare(
-		r.Config.Queue,
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
-	if err != nil {
-		return err
+	if r.Config.Queue != "" {
+		_, err = ch.QueueDeclare(
+			r.Config.Queue,
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			r.Config.QueueArgs,
+		)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		if r.Config.Exchange != "" {
+			err = ch.QueueBind(
+				r.Config.Queue,
+				r.Config.RoutingKey,
+				r.Config.Exchange,
+				false, // no-wait
+				nil,   // arguments
+			)
+			if err != nil {
+				conn.Close()
+				return err
+			}
+		}
 	}
 
 	r.conn = conn
@@ -88,15 +115,67 @@ func (r *RabbitMQConnector) Disconnect() error {
 }
 
 func (r *RabbitMQConnector) Read() (interface{}, error) {
-	// Implement read logic
-	return nil, nil
+	msg, ok, err := r.ch.Get(r.Config.Queue, false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return msg, nil
 }
 
 func (r *RabbitMQConnector) Write(data interface{}) error {
-	// Implement write logic
-	return nil
+	var msg amqp.Publishing
+	switch v := data.(type) {
+	case amqp.Publishing:
+		msg = v
+	case []byte:
+		msg = amqp.Publishing{Body: v, DeliveryMode: amqp.Persistent}
+	case string:
+		msg = amqp.Publishing{Body: []byte(v), DeliveryMode: amqp.Persistent}
+	default:
+		return fmt.Errorf("unsupported data type: %T", data)
+	}
+	return r.ch.Publish(r.Config.Exchange, r.Config.RoutingKey, false, false, msg)
 }
 
 func (r *RabbitMQConnector) GetConfig() interface{} {
 	return r.Config
 }
+
+// Additional RabbitMQ-specific methods
+
+// Channel returns the channel opened by Connect. Deliveries must be
+// acknowledged on the channel they were consumed from.
+func (r *RabbitMQConnector) Channel() *amqp.Channel {
+	return r.ch
+}
+
+// Consume starts a manually acknowledged consumer on the configured queue
+// with at most prefetch unacknowledged deliveries
+func (r *RabbitMQConnector) Consume(consumerTag string, prefetch int) (<-chan amqp.Delivery, error) {
+	if err := r.ch.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	return r.ch.Consume(
+		r.Config.Queue,
+		consumerTag,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // arguments
+	)
+}
+
+// EnableConfirms puts the channel in confirm mode and returns the channels
+// on which the broker reports confirmations and unroutable messages
+func (r *RabbitMQConnector) EnableConfirms(size int) (<-chan amqp.Confirmation, <-chan amqp.Return, error) {
+	if err := r.ch.Confirm(false); err != nil {
+		return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	confirms := r.ch.NotifyPublish(make(chan amqp.Confirmation, size))
+	returns := r.ch.NotifyReturn(make(chan amqp.Return, size))
+	return confirms, returns, nil
+}
@@ -3,6 +3,8 @@ package messaging
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/ivikasavnish/datapipe/pkg/connectors"
@@ -66,3 +68,64 @@ func (r *RedisConnector) Write(data interface{}) error {
 func (r *RedisConnector) GetConfig() interface{} {
 	return r.Config
 }
+
+// Additional Redis-specific methods
+
+// Client returns the underlying Redis client
+func (r *RedisConnector) Client() *redis.Client {
+	return r.client
+}
+
+// EnsureGroup creates a consumer group, and the stream if needed, starting
+// at start ("0" for the whole stream, "$" for new entries only). An existing
+// group is left untouched.
+func (r *RedisConnector) EnsureGroup(ctx context.Context, stream, group, start string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// AutoClaim transfers entries pending for longer than minIdle to consumer
+// and returns them with the cursor for the next call. XAUTOCLAIM is sent
+// as a raw command because its reply gained a third element in Redis 7.
+func (r *RedisConnector) AutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]redis.XMessage, string, error) {
+	reply, err := r.client.Do(ctx, "xautoclaim", stream, group, consumer,
+		minIdle.Milliseconds(), start, "count", count).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) < 2 {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM reply: %v", reply)
+	}
+	cursor, ok := parts[0].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM cursor: %v", parts[0])
+	}
+	entries, ok := parts[1].([]interface{})
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM entries: %v", parts[1])
+	}
+
+	messages := make([]redis.XMessage, 0, len(entries))
+	for _, entry := range entries {
+		// Entries deleted from the stream come back without fields
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 || pair[1] == nil {
+			continue
+		}
+		id, _ := pair[0].(string)
+		fields, _ := pair[1].([]interface{})
+		values := make(map[string]interface{}, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if name, ok := fields[i].(string); ok {
+				values[name] = fields[i+1]
+			}
+		}
+		messages = append(messages, redis.XMessage{ID: id, Values: values})
+	}
+	return messages, cursor, nil
+}
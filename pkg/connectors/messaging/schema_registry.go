@@ -0,0 +1,382 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors"
+)
+
+// Compatibility levels understood by Confluent-compatible schema registries
+const (
+	CompatibilityNone               = "NONE"
+	CompatibilityBackward           = "BACKWARD"
+	CompatibilityBackwardTransitive = "BACKWARD_TRANSITIVE"
+	CompatibilityForward            = "FORWARD"
+	CompatibilityForwardTransitive  = "FORWARD_TRANSITIVE"
+	CompatibilityFull               = "FULL"
+	CompatibilityFullTransitive     = "FULL_TRANSITIVE"
+)
+
+// Schema registry error codes
+const (
+	RegistryErrSubjectNotFound = 40401
+	RegistryErrVersionNotFound = 40402
+	RegistryErrSchemaNotFound  = 40403
+	RegistryErrInvalidSchema   = 42201
+)
+
+// ErrIncompatibleSchema is returned when a schema fails the compatibility
+// check of its subject
+var ErrIncompatibleSchema = errors.New("schema is incompatible with the registered versions")
+
+// SchemaRegistryConfig configures a schema registry client
+type SchemaRegistryConfig struct {
+	URL      string
+	Username string
+	Password string
+	// Timeout in seconds, 30 by default
+	Timeout int
+}
+
+// RegistrySchema is a schema as stored in the registry
+type RegistrySchema struct {
+	ID         int         `json:"id,omitempty"`
+	Subject    string      `json:"subject,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []SchemaRef `json:"references,omitempty"`
+}
+
+// SchemaRef references a schema registered under another subject
+type SchemaRef struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// RegistryError is an error response from the registry
+type RegistryError struct {
+	StatusCode int    `json:"-"`
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *RegistryError) Error() string {
+	return fmt.Sprintf("schema registry error %d: %s", e.ErrorCode, e.Message)
+}
+
+// SchemaRegistryConnector is a client for Confluent-compatible schema
+// registries. Schemas are cached by ID and registrations by subject.
+type SchemaRegistryConnector struct {
+	connectors.BaseConnector
+	Config SchemaRegistryConfig
+	client *http.Client
+
+	mu  sync.RWMutex
+	ids map[int]*RegistrySchema
+	// registered maps subject and schema text to the schema ID
+	registered map[string]int
+}
+
+// NewSchemaRegistryConnector creates a new schema registry connector
+func NewSchemaRegistryConnector(config SchemaRegistryConfig) *SchemaRegistryConnector {
+	return &SchemaRegistryConnector{
+		BaseConnector: connectors.BaseConnector{
+			Name:        "Schema Registry",
+			Description: "Confluent-compatible schema registry connector",
+			Version:     "1.0.0",
+			Type:        "messaging",
+		},
+		Config:     config,
+		ids:        make(map[int]*RegistrySchema),
+		registered: make(map[string]int),
+	}
+}
+
+func (r *SchemaRegistryConnector) Connect() error {
+	if r.Config.URL == "" {
+		return fmt.Errorf("schema registry URL is required")
+	}
+	timeout := r.Config.Timeout
+	if timeout <= 0 {
+		timeout = 30
+	}
+	r.client = &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+	}
+	return nil
+}
+
+func (r *SchemaRegistryConnector) Disconnect() error {
+	// HTTP client doesn't need explicit disconnection
+	return nil
+}
+
+func (r *SchemaRegistryConnector) Read() (interface{}, error) {
+	return r.Subjects(context.Background())
+}
+
+func (r *SchemaRegistryConnector) Write(data interface{}) error {
+	schema, ok := data.(RegistrySchema)
+	if !ok {
+		return fmt.Errorf("unsupported data type: %T", data)
+	}
+	_, err := r.Register(context.Background(), schema.Subject, schema)
+	return err
+}
+
+func (r *SchemaRegistryConnector) GetConfig() interface{} {
+	return r.Config
+}
+
+// Additional schema registry-specific methods
+
+// Subjects lists the registered subjects
+func (r *SchemaRegistryConnector) Subjects(ctx context.Context) ([]string, error) {
+	var subjects []string
+	if err := r.do(ctx, http.MethodGet, "/subjects", nil, &subjects); err != nil {
+		return nil, err
+	}
+	return subjects, nil
+}
+
+// SchemaByID returns the schema registered under id
+func (r *SchemaRegistryConnector) SchemaByID(ctx context.Context, id int) (*RegistrySchema, error) {
+	r.mu.RLock()
+	schema, ok := r.ids[id]
+	r.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema = &RegistrySchema{}
+	if err := r.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, schema); err != nil {
+		return nil, err
+	}
+	schema.ID = id
+
+	r.mu.Lock()
+	r.ids[id] = schema
+	r.mu.Unlock()
+	return schema, nil
+}
+
+// LatestSchema returns the latest version registered under subject
+func (r *SchemaRegistryConnector) LatestSchema(ctx context.Context, subject string) (*RegistrySchema, error) {
+	schema := &RegistrySchema{}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions/latest"
+	if err := r.do(ctx, http.MethodGet, path, nil, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Register registers a schema under subject and returns its ID. Registering
+// a schema that already exists returns the existing ID.
+func (r *SchemaRegistryConnector) Register(ctx context.Context, subject string, schema RegistrySchema) (int, error) {
+	key := registrationKey(subject, schema)
+	r.mu.RLock()
+	id, ok := r.registered[key]
+	r.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	var resp struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := r.do(ctx, http.MethodPost, path, registrationBody(schema), &resp); err != nil {
+		var regErr *RegistryError
+		if errors.As(err, &regErr) && regErr.StatusCode == http.StatusConflict {
+			return 0, fmt.Errorf("%w: %s", ErrIncompatibleSchema, regErr.Message)
+		}
+		return 0, err
+	}
+
+	schema.ID = resp.ID
+	schema.Subject = subject
+	r.mu.Lock()
+	r.registered[key] = resp.ID
+	r.ids[resp.ID] = &schema
+	r.mu.Unlock()
+	return resp.ID, nil
+}
+
+// CheckCompatibility tests a schema against the latest version of subject.
+// A subject with no versions accepts any schema. The returned messages
+// explain why an incompatible schema was rejected.
+func (r *SchemaRegistryConnector) CheckCompatibility(ctx context.Context, subject string, schema RegistrySchema) (bool, []string, error) {
+	var resp struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages"`
+	}
+	path := "/compatibility/subjects/" + url.PathEscape(subject) + "/versions/latest?verbose=true"
+	if err := r.do(ctx, http.MethodPost, path, registrationBody(schema), &resp); err != nil {
+		var regErr *RegistryError
+		if errors.As(err, &regErr) &&
+			(regErr.ErrorCode == RegistryErrSubjectNotFound || regErr.ErrorCode == RegistryErrVersionNotFound) {
+			return true, nil, nil
+		}
+		return false, nil, err
+	}
+	return resp.IsCompatible, resp.Messages, nil
+}
+
+// RegisterCompatible checks a schema for compatibility before registering
+// it, returning ErrIncompatibleSchema with the registry's reasons on failure
+func (r *SchemaRegistryConnector) RegisterCompatible(ctx context.Context, subject string, schema RegistrySchema) (int, error) {
+	r.mu.RLock()
+	id, ok := r.registered[registrationKey(subject, schema)]
+	r.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	compatible, messages, err := r.CheckCompatibility(ctx, subject, schema)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check compatibility: %w", err)
+	}
+	if !compatible {
+		if len(messages) == 0 {
+			return 0, fmt.Errorf("subject %s: %w", subject, ErrIncompatibleSchema)
+		}
+		return 0, fmt.Errorf("subject %s: %w: %s", subject, ErrIncompatibleSchema, strings.Join(messages, "; "))
+	}
+	return r.Register(ctx, subject, schema)
+}
+
+// Compatibility returns the compatibility level of subject, falling back to
+// the global level when the subject has none
+func (r *SchemaRegistryConnector) Compatibility(ctx context.Context, subject string) (string, error) {
+	var resp struct {
+		CompatibilityLevel string `json:"compatibilityLevel"`
+	}
+	path := "/config/" + url.PathEscape(subject) + "?defaultToGlobal=true"
+	if err := r.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.CompatibilityLevel, nil
+}
+
+// SetCompatibility sets the compatibility level of subject
+func (r *SchemaRegistryConnector) SetCompatibility(ctx context.Context, subject, level string) error {
+	body := map[string]string{"compatibility": level}
+	return r.do(ctx, http.MethodPut, "/config/"+url.PathEscape(subject), body, nil)
+}
+
+// Resolver adapts the connector to codec.SchemaResolver
+func (r *SchemaRegistryConnector) Resolver() codec.SchemaResolver {
+	return func(id int) (string, error) {
+		schema, err := r.SchemaByID(context.Background(), id)
+		if err != nil {
+			return "", err
+		}
+		return schema.Schema, nil
+	}
+}
+
+// BindCodec registers the codec's schema under subject after a
+// compatibility check and switches the codec to the Confluent wire format
+func (r *SchemaRegistryConnector) BindCodec(ctx context.Context, subject string, c codec.RegistryCodec) error {
+	if c.Schema() == "" {
+		return fmt.Errorf("%s codec has no schema to register", c.Name())
+	}
+	id, err := r.RegisterCompatible(ctx, subject, RegistrySchema{
+		SchemaType: c.SchemaType(),
+		Schema:     c.Schema(),
+	})
+	if err != nil {
+		return err
+	}
+	c.UseRegistry(id, r.Resolver())
+	return nil
+}
+
+// TopicSubject returns the subject for a topic's keys or values under the
+// default topic name strategy
+func TopicSubject(topic string, key bool) string {
+	if key {
+		return topic + "-key"
+	}
+	return topic + "-value"
+}
+
+func registrationKey(subject string, schema RegistrySchema) string {
+	return subject + "\x00" + schema.SchemaType + "\x00" + schema.Schema
+}
+
+// registrationBody omits the fields the registry assigns. AVRO is the
+// default schema type and is left out for older registries.
+func registrationBody(schema RegistrySchema) RegistrySchema {
+	body := RegistrySchema{
+		SchemaType: schema.SchemaType,
+		Schema:     schema.Schema,
+		References: schema.References,
+	}
+	if body.SchemaType == "AVRO" {
+		body.SchemaType = ""
+	}
+	return body
+}
+
+func (r *SchemaRegistryConnector) do(ctx context.Context, method, path string, body, out interface{}) error {
+	if r.client == nil {
+		return fmt.Errorf("schema registry is not connected")
+	}
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(r.Config.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if r.Config.Username != "" {
+		req.SetBasicAuth(r.Config.Username, r.Config.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("schema registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		regErr := &RegistryError{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(regErr); err != nil || regErr.Message == "" {
+			regErr.ErrorCode = resp.StatusCode
+			regErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return regErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode schema registry response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging/messagingtest"
+)
+
+const (
+	userV1 = `{"type":"record","name":"User","fields":[{"name":"id","type":"long"}]}`
+	// userV2 adds a field with a default, which BACKWARD allows
+	userV2 = `{"type":"record","name":"User","fields":[{"name":"id","type":"long"},{"name":"email","type":"string","default":""}]}`
+	// userV3 adds a field without a default, which BACKWARD rejects
+	userV3 = `{"type":"record","name":"User","fields":[{"name":"id","type":"long"},{"name":"age","type":"int"}]}`
+)
+
+func newRegistry(t *testing.T) *messaging.SchemaRegistryConnector {
+	t.Helper()
+	fake := messagingtest.NewSchemaRegistry()
+	url, err := fake.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fake.Close() })
+
+	registry := messaging.NewSchemaRegistryConnector(messaging.SchemaRegistryConfig{URL: url})
+	if err := registry.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestSchemaRegistryRegisterAndLookup(t *testing.T) {
+	ctx := context.Background()
+	registry := newRegistry(t)
+
+	id, err := registry.Register(ctx, "users-value", messaging.RegistrySchema{Schema: userV1})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	again, err := registry.Register(ctx, "users-value", messaging.RegistrySchema{Schema: userV1})
+	if err != nil {
+		t.Fatalf("Register again: %v", err)
+	}
+	if again != id {
+		t.Errorf("registering the same schema again returned ID %d, want %d", again, id)
+	}
+	shared, err := registry.Register(ctx, "accounts-value", messaging.RegistrySchema{Schema: userV1})
+	if err != nil {
+		t.Fatalf("Register under another subject: %v", err)
+	}
+	if shared != id {
+		t.Errorf("identical schema under another subject got ID %d, want %d", shared, id)
+	}
+
+	byID, err := registry.SchemaByID(ctx, id)
+	if err != nil {
+		t.Fatalf("SchemaByID: %v", err)
+	}
+	if byID.Schema != userV1 {
+		t.Errorf("SchemaByID returned %s, want %s", byID.Schema, userV1)
+	}
+
+	latest, err := registry.LatestSchema(ctx, "users-value")
+	if err != nil {
+		t.Fatalf("LatestSchema: %v", err)
+	}
+	if latest.ID != id || latest.Version != 1 || latest.Subject != "users-value" {
+		t.Errorf("LatestSchema returned ID %d version %d subject %q", latest.ID, latest.Version, latest.Subject)
+	}
+
+	subjects, err := registry.Subjects(ctx)
+	if err != nil {
+		t.Fatalf("Subjects: %v", err)
+	}
+	if len(subjects) != 2 || subjects[0] != "accounts-value" || subjects[1] != "users-value" {
+		t.Errorf("Subjects returned %v", subjects)
+	}
+
+	_, err = registry.SchemaByID(ctx, id+100)
+	var regErr *messaging.RegistryError
+	if !errors.As(err, &regErr) || regErr.ErrorCode != messaging.RegistryErrSchemaNotFound {
+		t.Errorf("SchemaByID of an unknown ID returned %v, want error %d", err, messaging.RegistryErrSchemaNotFound)
+	}
+}
+
+func TestSchemaRegistryRejectsInvalidSchema(t *testing.T) {
+	registry := newRegistry(t)
+
+	_, err := registry.Register(context.Background(), "users-value", messaging.RegistrySchema{Schema: `{"type":"record"}`})
+	var regErr *messaging.RegistryError
+	if !errors.As(err, &regErr) || regErr.ErrorCode != messaging.RegistryErrInvalidSchema {
+		t.Errorf("Register of an invalid schema returned %v, want error %d", err, messaging.RegistryErrInvalidSchema)
+	}
+}
+
+func TestSchemaRegistryCompatibility(t *testing.T) {
+	ctx := context.Background()
+	registry := newRegistry(t)
+
+	compatible, _, err := registry.CheckCompatibility(ctx, "users-value", messaging.RegistrySchema{Schema: userV3})
+	if err != nil || !compatible {
+		t.Fatalf("a subject without versions should accept any schema, got %v, %v", compatible, err)
+	}
+	if _, err := registry.RegisterCompatible(ctx, "users-value", messaging.RegistrySchema{Schema: userV1}); err != nil {
+		t.Fatalf("RegisterCompatible v1: %v", err)
+	}
+
+	compatible, _, err = registry.CheckCompatibility(ctx, "users-value", messaging.RegistrySchema{Schema: userV2})
+	if err != nil || !compatible {
+		t.Errorf("adding a field with a default should be backward compatible, got %v, %v", compatible, err)
+	}
+	compatible, messages, err := registry.CheckCompatibility(ctx, "users-value", messaging.RegistrySchema{Schema: userV3})
+	if err != nil {
+		t.Fatalf("CheckCompatibility v3: %v", err)
+	}
+	if compatible || len(messages) == 0 {
+		t.Errorf("adding a field without a default should be incompatible with reasons, got %v, %v", compatible, messages)
+	}
+
+	if _, err := registry.RegisterCompatible(ctx, "users-value", messaging.RegistrySchema{Schema: userV3}); !errors.Is(err, messaging.ErrIncompatibleSchema) {
+		t.Errorf("RegisterCompatible v3 returned %v, want ErrIncompatibleSchema", err)
+	}
+	if _, err := registry.Register(ctx, "users-value", messaging.RegistrySchema{Schema: userV3}); !errors.Is(err, messaging.ErrIncompatibleSchema) {
+		t.Errorf("Register v3 returned %v, want ErrIncompatibleSchema", err)
+	}
+
+	level, err := registry.Compatibility(ctx, "users-value")
+	if err != nil || level != messaging.CompatibilityBackward {
+		t.Errorf("Compatibility returned %q, %v, want the global %s", level, err, messaging.CompatibilityBackward)
+	}
+	if err := registry.SetCompatibility(ctx, "users-value", messaging.CompatibilityNone); err != nil {
+		t.Fatalf("SetCompatibility: %v", err)
+	}
+	id, err := registry.RegisterCompatible(ctx, "users-value", messaging.RegistrySchema{Schema: userV3})
+	if err != nil {
+		t.Fatalf("RegisterCompatible v3 with NONE: %v", err)
+	}
+	latest, err := registry.LatestSchema(ctx, "users-value")
+	if err != nil {
+		t.Fatalf("LatestSchema: %v", err)
+	}
+	if latest.ID != id || latest.Version != 2 {
+		t.Errorf("LatestSchema returned ID %d version %d, want ID %d version 2", latest.ID, latest.Version, id)
+	}
+}
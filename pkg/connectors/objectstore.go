@@ -0,0 +1,58 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrObjectNotFound is returned, possibly wrapped, by ObjectStore methods
+// when an object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes an object in an ObjectStore
+type ObjectInfo struct {
+	// Key is the object's name relative to the store, with "/" separators
+	Key     string
+	Size    int64
+	ModTime time.Time
+	// ETag changes whenever the object's content does. Filesystems, which
+	// have no such tag, derive it from the size and modification time.
+	ETag        string
+	ContentType string
+}
+
+// ListOptions selects the objects returned by ObjectStore.List
+type ListOptions struct {
+	// Prefix is a key prefix, not necessarily a directory
+	Prefix string
+	// StartAfter skips every key that sorts before it or equals it
+	StartAfter string
+	// PageSize caps the number of objects per page; zero lets the store
+	// choose
+	PageSize int
+}
+
+// WriteOptions configures ObjectStore.Create
+type WriteOptions struct {
+	ContentType string
+}
+
+// ObjectStore is the storage API shared by the S3, GCS, Azure Blob, HDFS
+// and local filesystem connectors, so that file sources and sinks can be
+// written once for all of them
+type ObjectStore interface {
+	// List calls fn with pages of the objects matching options, in key
+	// order. An error returned by fn stops the listing and is returned.
+	List(ctx context.Context, options ListOptions, fn func(page []ObjectInfo) error) error
+	// Open opens an object for streaming. The caller must close it.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Create starts writing an object, replacing any existing one. The
+	// object only becomes visible once Close returns nil; cancel ctx to
+	// abandon the write instead.
+	Create(ctx context.Context, key string, options WriteOptions) (io.WriteCloser, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	Copy(ctx context.Context, src, dst string) error
+}
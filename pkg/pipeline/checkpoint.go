@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// CheckpointStore persists source positions (offsets, resume tokens, paging
+// state) so that a source can resume where it left off
+type CheckpointStore interface {
+	// Load returns the checkpoint stored under key, or nil if there is none
+	Load(ctx context.Context, key string) ([]byte, error)
+	// Save stores the checkpoint under key, replacing any previous value
+	Save(ctx context.Context, key string, value []byte) error
+}
+
+// Committer is implemented by sources that track their position. The
+// pipeline calls Commit once the sink has written the records, so sources
+// should only advance their checkpoints from here. Run reads Committers
+// with Pull, one committed batch per run.
+type Committer interface {
+	Commit(ctx context.Context, records []Record) error
+}
+
+// Rejecter is implemented by sources that can hand records back, such as
+// message queues that redeliver unacknowledged messages. The pipeline calls
+// Reject with everything it consumed when the sink fails. Like Committers,
+// Rejecters are read with Pull.
+type Rejecter interface {
+	Reject(ctx context.Context, records []Record, cause error) error
+}
+
+// MemoryCheckpointStore is an in-memory CheckpointStore
+type MemoryCheckpointStore struct {
+	mu          sync.RWMutex
+	checkpoints map[string][]byte
+}
+
+// NewMemoryCheckpointStore creates a new in-memory checkpoint store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: make(map[string][]byte),
+	}
+}
+
+// Load implements CheckpointStore
+func (m *MemoryCheckpointStore) Load(ctx context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.checkpoints[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// Save implements CheckpointStore
+func (m *MemoryCheckpointStore) Save(ctx context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.checkpoints[key] = append([]byte(nil), value...)
+	return nil
+}
@@ -67,6 +67,15 @@ type Transformer interface {
 	Transform(ctx context.Context, in <-chan Record) (<-chan Record, error)
 }
 
+// FailingTransformer is implemented by transformers whose work can fail
+// after Transform returns, such as writing rejected records elsewhere. Run
+// calls Failed once the transformer's output is drained; an error fails
+// the run, and the records read are rejected instead of committed.
+type FailingTransformer interface {
+	Transformer
+	Failed() error
+}
+
 // Sink is an interface for data sinks
 type Sink interface {
 	Write(ctx context.Context, in <-chan Record) error
@@ -148,18 +157,35 @@ func (p *Pipeline) WithPushConfig(config *PushConfig) *Pipeline {
 	return p
 }
 
-// Run executes the pipeline
+// Run executes the pipeline. Sources that are Committers or Rejecters are
+// read in batches with Pull, so a pull config is required for them: a
+// streaming Read only ends when ctx is cancelled, and by then the sink can
+// no longer write the records that would be committed.
 func (p *Pipeline) Run(ctx context.Context) error {
+	_, commits := p.source.(Committer)
+	_, rejects := p.source.(Rejecter)
+	acknowledges := commits || rejects
+	if _, ok := p.source.(PullSource); acknowledges && (!ok || p.pullConfig == nil) {
+		return fmt.Errorf("source %T commits or rejects records in batches; configure it with WithPullConfig", p.source)
+	}
+
 	// Start reading from source
 	records, err := p.executePull(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to read from source: %w", err)
 	}
 
-	// Apply filters
+	// Apply filters, remembering everything read so the source can commit it
+	var consumedMu sync.Mutex
+	var consumed []Record
 	filteredRecords := make(chan Record)
 	go func() {
 		for record := range records {
+			if acknowledges {
+				consumedMu.Lock()
+				consumed = append(consumed, record)
+				consumedMu.Unlock()
+			}
 			for _, filter := range p.filters {
 				if !filter.Apply(record) {
 					p.metrics.Mu.Lock()
@@ -183,14 +209,52 @@ func (p *Pipeline) Run(ctx context.Context) error {
 		current = transformed
 	}
 
-	// Write to sink
-	if err := p.executePush(ctx, p.recordsToSlice(current)); err != nil {
-		return fmt.Errorf("failed to write to sink: %w", err)
+	// Write to sink, handing the records back to the source on failure
+	transformed := p.recordsToSlice(current)
+	if err := p.transformerFailure(); err != nil {
+		return p.reject(ctx, &consumedMu, consumed, fmt.Errorf("transformer failed: %w", err))
+	}
+	if err := p.executePush(ctx, transformed); err != nil {
+		return p.reject(ctx, &consumedMu, consumed, fmt.Errorf("failed to write to sink: %w", err))
+	}
+
+	// Let the source advance its checkpoints now that the sink has the records
+	consumedMu.Lock()
+	defer consumedMu.Unlock()
+	if committer, ok := p.source.(Committer); ok && len(consumed) > 0 {
+		if err := committer.Commit(ctx, consumed); err != nil {
+			return fmt.Errorf("failed to commit source: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// transformerFailure returns the first failure of a FailingTransformer
+func (p *Pipeline) transformerFailure() error {
+	for _, t := range p.transformers {
+		if failing, ok := t.(FailingTransformer); ok {
+			if err := failing.Failed(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reject hands the records read back to the source after a failed run and
+// returns err
+func (p *Pipeline) reject(ctx context.Context, mu *sync.Mutex, consumed []Record, err error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if rejecter, ok := p.source.(Rejecter); ok && len(consumed) > 0 {
+		if rejectErr := rejecter.Reject(ctx, consumed, err); rejectErr != nil {
+			return fmt.Errorf("%w (reject failed: %v)", err, rejectErr)
+		}
+	}
+	return err
+}
+
 // RunWithTimer executes the pipeline with timing configuration
 func (p *Pipeline) RunWithTimer(ctx context.Context) error {
 	if p.timer == nil {
@@ -317,5 +381,13 @@ func (p *Pipeline) Stop() error {
 func (p *Pipeline) GetMetrics() Metrics {
 	p.metrics.Mu.RLock()
 	defer p.metrics.Mu.RUnlock()
-	return *p.metrics
+	return Metrics{
+		RecordsProcessed: p.metrics.RecordsProcessed,
+		Errors:           p.metrics.Errors,
+		StartTime:        p.metrics.StartTime,
+		EndTime:          p.metrics.EndTime,
+		LastPullTime:     p.metrics.LastPullTime,
+		LastPushTime:     p.metrics.LastPushTime,
+		FilteredRecords:  p.metrics.FilteredRecords,
+	}
 }
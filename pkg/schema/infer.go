@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Infer derives a schema from sample records, for writers that need one
+// up front. Fields are neither required nor strict, and nullable. Types
+// seen together widen: integers and numbers to Number, anything else to
+// Any, as are fields that were only ever null. Go integers and integral
+// json.Numbers infer as Integer, while float64, which encoding/json
+// decodes every number to by default, infers as Number. Fields are sorted
+// by name.
+func Infer(name string, records ...map[string]interface{}) *Schema {
+	var fields []Field
+	for _, record := range records {
+		fields = mergeFields(fields, objectFields(record))
+	}
+	return &Schema{Name: name, Fields: resolveNulls(fields)}
+}
+
+// infer returns the field of a single value. Its Type is empty for nil,
+// until another sample shows the type. Array items are only nullable once
+// a null item is seen.
+func infer(name string, val interface{}) Field {
+	f := Field{Name: name, Nullable: true}
+	if val == nil {
+		return f
+	}
+	f.Type = inferType(val)
+
+	switch f.Type {
+	case Object:
+		f.Fields = objectFields(val.(map[string]interface{}))
+	case Array:
+		rv := reflect.ValueOf(val)
+		for i := 0; i < rv.Len(); i++ {
+			val := rv.Index(i).Interface()
+			item := infer("", val)
+			item.Nullable = val == nil
+			if f.Items != nil {
+				item = mergeField(*f.Items, item)
+			}
+			f.Items = &item
+		}
+	}
+	return f
+}
+
+func objectFields(object map[string]interface{}) []Field {
+	fields := make([]Field, 0, len(object))
+	for name, val := range object {
+		fields = append(fields, infer(name, val))
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+func mergeFields(a, b []Field) []Field {
+	index := make(map[string]int, len(a))
+	for i, f := range a {
+		index[f.Name] = i
+	}
+	for _, f := range b {
+		if i, ok := index[f.Name]; ok {
+			a[i] = mergeField(a[i], f)
+			continue
+		}
+		index[f.Name] = len(a)
+		a = append(a, f)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].Name < a[j].Name })
+	return a
+}
+
+// mergeField widens a to also describe the values of b
+func mergeField(a, b Field) Field {
+	a.Nullable = a.Nullable || b.Nullable
+	switch {
+	case b.Type == "":
+		return a
+	case a.Type == "":
+		b.Name, b.Nullable = a.Name, a.Nullable
+		return b
+	case a.Type == b.Type:
+		switch a.Type {
+		case Object:
+			a.Fields = mergeFields(a.Fields, b.Fields)
+		case Array:
+			if b.Items != nil {
+				items := *b.Items
+				if a.Items != nil {
+					items = mergeField(*a.Items, items)
+				}
+				a.Items = &items
+			}
+		}
+		return a
+	case (a.Type == Integer || a.Type == Number) && (b.Type == Integer || b.Type == Number):
+		a.Type = Number
+		return a
+	}
+	a.Type, a.Fields, a.Items = Any, nil, nil
+	return a
+}
+
+// resolveNulls types the fields only seen as null as Any
+func resolveNulls(fields []Field) []Field {
+	for i := range fields {
+		f := &fields[i]
+		switch f.Type {
+		case "":
+			f.Type = Any
+		case Object:
+			f.Fields = resolveNulls(f.Fields)
+		case Array:
+			if f.Items != nil {
+				f.Items = &resolveNulls([]Field{*f.Items})[0]
+			}
+		}
+	}
+	return fields
+}
+
+func inferType(val interface{}) Type {
+	switch x := val.(type) {
+	case string, []byte:
+		return String
+	case bool:
+		return Boolean
+	case time.Time:
+		return Timestamp
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return Integer
+		}
+		return Number
+	case float32, float64:
+		return Number
+	case map[string]interface{}:
+		return Object
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer
+	case reflect.Slice, reflect.Array:
+		return Array
+	}
+	return Any
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// jsonSchema is the subset of JSON Schema understood by FromJSONSchema
+type jsonSchema struct {
+	Title                string                 `json:"title"`
+	Type                 json.RawMessage        `json:"type"`
+	Format               string                 `json:"format"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+}
+
+// FromJSONSchema builds a schema from a JSON Schema document. It supports
+// type (including ["<type>", "null"] for nullable fields), properties,
+// required, items, additionalProperties and the date-time format.
+func FromJSONSchema(data []byte) (*Schema, error) {
+	var doc jsonSchema
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+
+	root, err := doc.field("")
+	if err != nil {
+		return nil, err
+	}
+	if root.Type != Object {
+		return nil, fmt.Errorf("JSON Schema root must be an object, got %s", root.Type)
+	}
+
+	s := &Schema{
+		Name:   doc.Title,
+		Fields: root.Fields,
+		Strict: root.Strict,
+	}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// FromJSONSchemaFile reads a JSON Schema document from disk
+func FromJSONSchemaFile(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON Schema: %w", err)
+	}
+	return FromJSONSchema(data)
+}
+
+func (j *jsonSchema) field(name string) (Field, error) {
+	f := Field{Name: name}
+
+	types, err := j.types()
+	if err != nil {
+		return f, fmt.Errorf("field %q: %w", name, err)
+	}
+	var declared []string
+	for _, t := range types {
+		if t == "null" {
+			f.Nullable = true
+			continue
+		}
+		declared = append(declared, t)
+	}
+
+	switch {
+	case len(declared) == 0:
+		f.Type = Any
+		if len(types) == 0 && j.Properties != nil {
+			f.Type = Object
+		}
+	case len(declared) > 1:
+		f.Type = Any
+	default:
+		switch declared[0] {
+		case "string":
+			f.Type = String
+			if j.Format == "date-time" {
+				f.Type = Timestamp
+			}
+		case "integer":
+			f.Type = Integer
+		case "number":
+			f.Type = Number
+		case "boolean":
+			f.Type = Boolean
+		case "object":
+			f.Type = Object
+		case "array":
+			f.Type = Array
+		default:
+			return f, fmt.Errorf("field %q: unsupported type %q", name, declared[0])
+		}
+	}
+
+	switch f.Type {
+	case Object:
+		f.Strict = j.AdditionalProperties != nil && !*j.AdditionalProperties
+		required := make(map[string]bool, len(j.Required))
+		for _, r := range j.Required {
+			required[r] = true
+		}
+		names := make([]string, 0, len(j.Properties))
+		for n := range j.Properties {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			child, err := j.Properties[n].field(n)
+			if err != nil {
+				return f, err
+			}
+			child.Required = required[n]
+			f.Fields = append(f.Fields, child)
+		}
+	case Array:
+		if j.Items != nil {
+			items, err := j.Items.field("")
+			if err != nil {
+				return f, err
+			}
+			f.Items = &items
+		}
+	}
+	return f, nil
+}
+
+func (j *jsonSchema) types() ([]string, error) {
+	if len(j.Type) == 0 {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(j.Type, &single); err == nil {
+		return []string{single}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(j.Type, &many); err != nil {
+		return nil, fmt.Errorf("invalid type: %s", j.Type)
+	}
+	return many, nil
+}
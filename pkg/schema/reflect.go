@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FromStruct derives a schema from a Go struct. Field names follow the json
+// tag. Pointer, slice and map fields are nullable; other fields are required
+// unless the tag has omitempty.
+func FromStruct(v interface{}) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema.FromStruct needs a struct, got %T", v)
+	}
+
+	fields, err := structFields(t)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{Name: t.Name(), Fields: fields}, nil
+}
+
+func structFields(t reflect.Type) ([]Field, error) {
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, omitEmpty := sf.Name, false
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				omitEmpty = omitEmpty || opt == "omitempty"
+			}
+		}
+
+		f, err := typeField(name, sf.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		f.Required = !omitEmpty && !f.Nullable
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func typeField(name string, t reflect.Type) (Field, error) {
+	f := Field{Name: name}
+	if t.Kind() == reflect.Ptr {
+		f.Nullable = true
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		f.Type = Timestamp
+		return f, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		f.Type = String
+	case reflect.Bool:
+		f.Type = Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.Type = Integer
+	case reflect.Float32, reflect.Float64:
+		f.Type = Number
+	case reflect.Struct:
+		fields, err := structFields(t)
+		if err != nil {
+			return f, err
+		}
+		f.Type = Object
+		f.Fields = fields
+	case reflect.Map:
+		f.Type = Object
+		f.Nullable = true
+	case reflect.Slice, reflect.Array:
+		f.Type = Array
+		f.Nullable = t.Kind() == reflect.Slice
+		items, err := typeField("", t.Elem())
+		if err != nil {
+			return f, err
+		}
+		f.Items = &items
+	case reflect.Interface:
+		f.Type = Any
+	default:
+		return f, fmt.Errorf("unsupported kind %s", t.Kind())
+	}
+	return f, nil
+}
@@ -0,0 +1,122 @@
+// Package schema describes the expected shape of pipeline.Record.Data and
+// validates records against it.
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a field value
+type Type string
+
+const (
+	String    Type = "string"
+	Integer   Type = "integer"
+	Number    Type = "number"
+	Boolean   Type = "boolean"
+	Timestamp Type = "timestamp"
+	Object    Type = "object"
+	Array     Type = "array"
+	// Any accepts every value, including nested ones
+	Any Type = "any"
+)
+
+// Field describes one field of a record or nested object
+type Field struct {
+	Name     string
+	Type     Type
+	Required bool
+	// Nullable allows an explicit nil value
+	Nullable bool
+	// Fields describes the members of an Object field
+	Fields []Field
+	// Strict rejects members of an Object field not listed in Fields
+	Strict bool
+	// Items describes the elements of an Array field
+	Items *Field
+}
+
+// Schema describes the fields of Record.Data
+type Schema struct {
+	Name   string
+	Fields []Field
+	// Strict rejects fields not listed in Fields
+	Strict bool
+}
+
+// New creates a schema from its fields
+func New(name string, fields ...Field) *Schema {
+	return &Schema{Name: name, Fields: fields}
+}
+
+// Check validates the field definitions themselves
+func (s *Schema) Check() error {
+	return checkFields("", s.Fields)
+}
+
+func checkFields(prefix string, fields []Field) error {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		path := joinPath(prefix, f.Name)
+		if f.Name == "" {
+			return fmt.Errorf("field in %q has no name", prefix)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate field %q", path)
+		}
+		seen[f.Name] = true
+
+		switch f.Type {
+		case String, Integer, Number, Boolean, Timestamp, Any:
+		case Object:
+			if err := checkFields(path, f.Fields); err != nil {
+				return err
+			}
+		case Array:
+			if f.Items != nil {
+				items := *f.Items
+				if items.Name == "" {
+					items.Name = "[]"
+				}
+				if err := checkFields(path, []Field{items}); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("field %q has unknown type %q", path, f.Type)
+		}
+	}
+	return nil
+}
+
+// FieldError describes why one field failed validation
+type FieldError struct {
+	// Path locates the field, e.g. "address.city" or "items[2].sku"
+	Path    string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError lists every field that failed validation
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		messages[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
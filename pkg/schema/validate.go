@@ -0,0 +1,322 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options controls validation
+type Options struct {
+	// Coerce converts convertible values to the declared type, e.g. "42"
+	// to an integer or an RFC 3339 string to a time.Time
+	Coerce bool
+}
+
+// Validate checks data against the schema. It returns the data with
+// coerced values, leaving the input unmodified, and a *ValidationError
+// listing every failing field.
+func (s *Schema) Validate(data map[string]interface{}, opts Options) (map[string]interface{}, error) {
+	v := validator{opts: opts}
+	out := v.object("", s.Fields, s.Strict, data)
+	if len(v.errors) > 0 {
+		return data, &ValidationError{Errors: v.errors}
+	}
+	return out, nil
+}
+
+type validator struct {
+	opts   Options
+	errors []FieldError
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) object(prefix string, fields []Field, strict bool, data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, val := range data {
+		out[k] = val
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+		path := joinPath(prefix, f.Name)
+		val, ok := data[f.Name]
+		if !ok {
+			if f.Required {
+				v.fail(path, "is required")
+			}
+			continue
+		}
+		out[f.Name] = v.value(path, f, val)
+	}
+
+	if strict {
+		var unknown []string
+		for k := range data {
+			if !known[k] {
+				unknown = append(unknown, k)
+			}
+		}
+		sort.Strings(unknown)
+		for _, k := range unknown {
+			v.fail(joinPath(prefix, k), "is not defined in the schema")
+		}
+	}
+	return out
+}
+
+func (v *validator) value(path string, f Field, val interface{}) interface{} {
+	if val == nil {
+		if !f.Nullable && f.Type != Any {
+			v.fail(path, "must not be null")
+		}
+		return nil
+	}
+
+	switch f.Type {
+	case Any:
+		return val
+	case String:
+		return v.string(path, val)
+	case Integer:
+		return v.integer(path, val)
+	case Number:
+		return v.number(path, val)
+	case Boolean:
+		return v.boolean(path, val)
+	case Timestamp:
+		return v.timestamp(path, val)
+	case Object:
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			v.fail(path, "must be an object, got %s", typeName(val))
+			return val
+		}
+		return v.object(path, f.Fields, f.Strict, m)
+	case Array:
+		return v.array(path, f, val)
+	}
+	v.fail(path, "has unknown type %q", f.Type)
+	return val
+}
+
+func (v *validator) array(path string, f Field, val interface{}) interface{} {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		v.fail(path, "must be an array, got %s", typeName(val))
+		return val
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		item := rv.Index(i).Interface()
+		if f.Items == nil {
+			out[i] = item
+			continue
+		}
+		out[i] = v.value(fmt.Sprintf("%s[%d]", path, i), *f.Items, item)
+	}
+	return out
+}
+
+func (v *validator) string(path string, val interface{}) interface{} {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	if v.opts.Coerce {
+		switch x := val.(type) {
+		case []byte:
+			return string(x)
+		case time.Time:
+			return x.Format(time.RFC3339Nano)
+		case bool, json.Number:
+			return fmt.Sprint(x)
+		}
+		if f, ok := toFloat(val); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+	v.fail(path, "must be a string, got %s", typeName(val))
+	return val
+}
+
+func (v *validator) integer(path string, val interface{}) interface{} {
+	switch x := val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i, ok := toInt(x)
+		if !ok {
+			v.fail(path, "%v is out of the 64-bit integer range", x)
+			return val
+		}
+		if v.opts.Coerce {
+			return i
+		}
+		return val
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			if v.opts.Coerce {
+				return i
+			}
+			return val
+		}
+		// Exponents and numbers beyond int64 are checked as floats
+		if f, err := x.Float64(); err == nil || math.IsInf(f, 0) {
+			return v.integralFloat(path, val, f)
+		}
+	case float32, float64:
+		f, _ := toFloat(x)
+		return v.integralFloat(path, val, f)
+	case string:
+		if v.opts.Coerce {
+			if i, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64); err == nil {
+				return i
+			}
+			v.fail(path, "cannot convert %q to an integer", x)
+			return val
+		}
+	}
+	v.fail(path, "must be an integer, got %s", typeName(val))
+	return val
+}
+
+// integralFloat accepts a float holding an integer in the int64 range
+func (v *validator) integralFloat(path string, val interface{}, f float64) interface{} {
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		v.fail(path, "must be an integer, got %v", val)
+		return val
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		v.fail(path, "%v is out of the 64-bit integer range", val)
+		return val
+	}
+	if v.opts.Coerce {
+		return int64(f)
+	}
+	return val
+}
+
+func (v *validator) number(path string, val interface{}) interface{} {
+	if f, ok := toFloat(val); ok {
+		if v.opts.Coerce {
+			return f
+		}
+		return val
+	}
+	if s, ok := val.(string); ok && v.opts.Coerce {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+			return f
+		}
+		v.fail(path, "cannot convert %q to a number", s)
+		return val
+	}
+	v.fail(path, "must be a number, got %s", typeName(val))
+	return val
+}
+
+func (v *validator) boolean(path string, val interface{}) interface{} {
+	if b, ok := val.(bool); ok {
+		return b
+	}
+	if s, ok := val.(string); ok && v.opts.Coerce {
+		if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			return b
+		}
+		v.fail(path, "cannot convert %q to a boolean", s)
+		return val
+	}
+	v.fail(path, "must be a boolean, got %s", typeName(val))
+	return val
+}
+
+// timestamp accepts time.Time and RFC 3339 strings. With coercion, strings
+// become time.Time and numbers are read as Unix seconds.
+func (v *validator) timestamp(path string, val interface{}) interface{} {
+	switch x := val.(type) {
+	case time.Time:
+		return x
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(x))
+		if err != nil {
+			v.fail(path, "must be an RFC 3339 timestamp, got %q", x)
+			return val
+		}
+		if v.opts.Coerce {
+			return t
+		}
+		return val
+	}
+	if f, ok := toFloat(val); ok && v.opts.Coerce {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	}
+	v.fail(path, "must be a timestamp, got %s", typeName(val))
+	return val
+}
+
+// toInt converts a Go integer to int64, failing for unsigned values that
+// do not fit
+func toInt(val interface{}) (int64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch x := val.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func typeName(val interface{}) string {
+	switch val.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float32, float64, json.Number:
+		return "number"
+	case time.Time:
+		return "timestamp"
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	}
+	return fmt.Sprintf("%T", val)
+}
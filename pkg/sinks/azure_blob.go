@@ -0,0 +1,248 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"text/template"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Azure Blob sink modes
+const (
+	// AzureBlobModeBlock writes rolled files as block blobs, staging blocks
+	// as they fill and committing them once a file is complete
+	AzureBlobModeBlock = "block"
+	// AzureBlobModeAppend appends every batch to a long-lived append blob
+	// per partition, for continuous log-style output
+	AzureBlobModeAppend = "append"
+)
+
+// AzureBlobSinkConfig configures an Azure Blob Storage sink
+type AzureBlobSinkConfig struct {
+	Connection cloud.AzureBlobConfig
+	ObjectSinkConfig
+	// Mode is AzureBlobModeBlock (default) or AzureBlobModeAppend. Append
+	// mode ignores Roll and Manifest.
+	Mode string
+	// AppendName names the append blob of each partition, which is
+	// Prefix + partition + AppendName + the codec's extension; defaults to
+	// "data". Once a blob holds the 50,000 blocks Azure allows, appends
+	// roll over to AppendName-00001, AppendName-00002 and so on.
+	AppendName string
+	// BatchSize is the number of records Write appends at a time in append
+	// mode; defaults to 1000
+	BatchSize int
+}
+
+// AzureBlobSink is an ObjectSink over an Azure Blob Storage container, or
+// in append mode a writer of append blobs. In append mode every block is
+// encoded and compressed on its own, so the codec must be appendable (see
+// codec.Appendable), such as JSON lines. Only one sink may append to a
+// blob at a time.
+type AzureBlobSink struct {
+	*ObjectSink
+	connector *cloud.AzureBlobConnector
+	config    AzureBlobSinkConfig
+	partition *template.Template
+	extension string
+
+	mu sync.Mutex
+	// sizes caches the size of the append blobs written so far
+	sizes map[string]int64
+	// parts holds the number of the blob each partition appends to. It
+	// starts at zero, so after a restart full blobs are skipped one by one.
+	parts map[string]int
+}
+
+// NewAzureBlobSink creates a new Azure Blob Storage sink
+func NewAzureBlobSink(config AzureBlobSinkConfig) (*AzureBlobSink, error) {
+	if config.Mode == "" {
+		config.Mode = AzureBlobModeBlock
+	}
+	if config.Mode != AzureBlobModeBlock && config.Mode != AzureBlobModeAppend {
+		return nil, fmt.Errorf("unsupported azure blob sink mode: %s", config.Mode)
+	}
+	if config.AppendName == "" {
+		config.AppendName = "data"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.Mode == AzureBlobModeAppend && config.Codec != nil {
+		if err := checkAppendable(config.Codec); err != nil {
+			return nil, err
+		}
+	}
+
+	connector := cloud.NewAzureBlobConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to azure blob storage: %w", err)
+	}
+
+	sink, err := NewObjectSink(connector, config.ObjectSinkConfig)
+	if err != nil {
+		return nil, err
+	}
+	sink.disconnect = connector.Disconnect
+	// NewObjectSink validated the template and filled in the defaults
+	config.ObjectSinkConfig = sink.config
+	partition, _ := parsePartition(config.Partition)
+
+	return &AzureBlobSink{
+		ObjectSink: sink,
+		connector:  connector,
+		config:     config,
+		partition:  partition,
+		extension:  codec.Extension(config.Codec) + codec.CompressionExtension(config.Compression),
+		sizes:      make(map[string]int64),
+		parts:      make(map[string]int),
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *AzureBlobSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	if s.config.Mode == AzureBlobModeBlock {
+		return s.ObjectSink.Write(ctx, in)
+	}
+
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.append(ctx, batch, pipeline.PushConfig{}); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.append(ctx, batch, pipeline.PushConfig{})
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *AzureBlobSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	if s.config.Mode == AzureBlobModeBlock {
+		return s.ObjectSink.Push(ctx, records, config)
+	}
+	return s.append(ctx, records, config)
+}
+
+// append appends records to the append blobs of their partitions, in
+// blocks that each hold whole records
+func (s *AzureBlobSink) append(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	groups := make(map[string][]pipeline.Record)
+	for _, record := range records {
+		key, err := s.appendKey(record)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+
+	for _, base := range keys {
+		blocks, err := s.blocks(groups[base])
+		if err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			var key string
+			err := withRetry(ctx, config, func() error {
+				for {
+					key = s.partKey(base)
+					size, ok := s.sizes[key]
+					if !ok {
+						var err error
+						if size, err = s.connector.OpenAppendBlob(ctx, key, s.config.Codec.ContentType()); err != nil {
+							return err
+						}
+						s.sizes[key] = size
+					}
+					err := s.connector.AppendBlock(ctx, key, block, size)
+					if errors.Is(err, cloud.ErrAppendBlobFull) {
+						// Roll over to the next blob of the partition
+						delete(s.sizes, key)
+						s.parts[base]++
+						continue
+					}
+					if err != nil {
+						// Read the size again on the next attempt
+						delete(s.sizes, key)
+						return err
+					}
+					s.sizes[key] = size + int64(len(block))
+					return nil
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("failed to append to %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// appendKey returns the name of a record's partition blob without the
+// rollover number
+func (s *AzureBlobSink) appendKey(record pipeline.Record) (string, error) {
+	partition, err := renderPartition(s.partition, record)
+	if err != nil {
+		return "", err
+	}
+	return s.config.Prefix + partition + s.config.AppendName, nil
+}
+
+// partKey returns the blob a partition currently appends to
+func (s *AzureBlobSink) partKey(base string) string {
+	if part := s.parts[base]; part > 0 {
+		return fmt.Sprintf("%s-%05d%s", base, part, s.extension)
+	}
+	return base + s.extension
+}
+
+// blocks encodes records into blocks of at most cloud.AzureMaxAppendBlock
+// bytes, halving the records of any block that comes out too large
+func (s *AzureBlobSink) blocks(records []pipeline.Record) ([][]byte, error) {
+	block, err := encodeRecords(s.config.Codec, s.config.Compression, records)
+	if err != nil {
+		return nil, err
+	}
+	if len(block) <= cloud.AzureMaxAppendBlock {
+		return [][]byte{block}, nil
+	}
+	if len(records) == 1 {
+		return nil, fmt.Errorf("record %s encodes to %d bytes, more than an append block holds", records[0].ID, len(block))
+	}
+
+	head, err := s.blocks(records[:len(records)/2])
+	if err != nil {
+		return nil, err
+	}
+	tail, err := s.blocks(records[len(records)/2:])
+	if err != nil {
+		return nil, err
+	}
+	return append(head, tail...), nil
+}
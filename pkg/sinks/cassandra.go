@@ -0,0 +1,232 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/database"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// CassandraSinkConfig configures a Cassandra table sink
+type CassandraSinkConfig struct {
+	Connection database.CassandraConfig
+	Table      string
+	// Consistency overrides the connection consistency for writes
+	Consistency string
+	// TTL expires written rows; zero keeps them forever
+	TTL time.Duration
+	// BatchSize caps the statements per unlogged batch; 1 disables batching
+	BatchSize int
+}
+
+// CassandraSink implements pipeline.PushSink for Cassandra. Records sharing
+// a partition key are written together in unlogged batches.
+type CassandraSink struct {
+	connector    *database.CassandraConnector
+	config       CassandraSinkConfig
+	partitionKey []string
+	columns      map[string]bool
+	consistency  *gocql.Consistency
+}
+
+// NewCassandraSink creates a new Cassandra sink
+func NewCassandraSink(config CassandraSinkConfig) (*CassandraSink, error) {
+	if config.Table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+
+	var consistency *gocql.Consistency
+	if config.Consistency != "" {
+		c, err := gocql.ParseConsistencyWrapper(config.Consistency)
+		if err != nil {
+			return nil, err
+		}
+		consistency = &c
+	}
+
+	connector := database.NewCassandraConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to cassandra: %w", err)
+	}
+
+	meta, err := connector.TableMetadata(config.Table)
+	if err != nil {
+		connector.Disconnect()
+		return nil, fmt.Errorf("failed to read table metadata: %w", err)
+	}
+
+	partitionKey := make([]string, len(meta.PartitionKey))
+	for i, col := range meta.PartitionKey {
+		partitionKey[i] = col.Name
+	}
+	columns := make(map[string]bool, len(meta.Columns))
+	for name := range meta.Columns {
+		columns[name] = true
+	}
+
+	return &CassandraSink{
+		connector:    connector,
+		config:       config,
+		partitionKey: partitionKey,
+		columns:      columns,
+		consistency:  consistency,
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *CassandraSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.writeBatch(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeBatch(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *CassandraSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	return withRetry(ctx, config, func() error {
+		return s.writeBatch(ctx, records)
+	})
+}
+
+// writeBatch groups records by partition key and writes each group
+func (s *CassandraSink) writeBatch(ctx context.Context, records []pipeline.Record) error {
+	groups := make(map[string][]pipeline.Record)
+	var order []string
+	for _, record := range records {
+		key, err := s.partitionOf(record)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		for start := 0; start < len(group); start += s.config.BatchSize {
+			end := start + s.config.BatchSize
+			if end > len(group) {
+				end = len(group)
+			}
+			if err := s.writePartition(ctx, group[start:end]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *CassandraSink) partitionOf(record pipeline.Record) (string, error) {
+	parts := make([]string, len(s.partitionKey))
+	for i, col := range s.partitionKey {
+		v, ok := record.Data[col]
+		if !ok || v == nil {
+			return "", fmt.Errorf("record %s is missing partition key column %s", record.ID, col)
+		}
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+// writePartition writes records of a single partition. gocql prepares and
+// caches each distinct statement, so rows with the same columns share one.
+func (s *CassandraSink) writePartition(ctx context.Context, records []pipeline.Record) error {
+	session := s.connector.Session()
+
+	if len(records) == 1 {
+		stmt, args, err := s.insertStatement(records[0])
+		if err != nil {
+			return err
+		}
+		query := session.Query(stmt, args...).WithContext(ctx)
+		if s.consistency != nil {
+			query = query.Consistency(*s.consistency)
+		}
+		if err := query.Exec(); err != nil {
+			return fmt.Errorf("failed to insert record: %w", err)
+		}
+		return nil
+	}
+
+	batch := session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+	if s.consistency != nil {
+		batch.SetConsistency(*s.consistency)
+	}
+	for _, record := range records {
+		stmt, args, err := s.insertStatement(record)
+		if err != nil {
+			return err
+		}
+		batch.Query(stmt, args...)
+	}
+	if err := session.ExecuteBatch(batch); err != nil {
+		return fmt.Errorf("failed to execute batch: %w", err)
+	}
+	return nil
+}
+
+// insertStatement builds an INSERT for the record. Only columns known from
+// the table metadata are accepted, so record fields never reach the CQL text
+// unchecked.
+func (s *CassandraSink) insertStatement(record pipeline.Record) (string, []interface{}, error) {
+	columns := make([]string, 0, len(record.Data))
+	for col := range record.Data {
+		if !s.columns[col] {
+			return "", nil, fmt.Errorf("table %s has no column %s", s.config.Table, col)
+		}
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	args := make([]interface{}, len(columns))
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, col := range columns {
+		args[i] = record.Data[col]
+		quoted[i] = `"` + col + `"`
+		placeholders[i] = "?"
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		s.config.Table, strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	if s.config.TTL > 0 {
+		stmt += " USING TTL ?"
+		args = append(args, int(s.config.TTL.Seconds()))
+	}
+	return stmt, args, nil
+}
+
+// Close implements pipeline.Sink
+func (s *CassandraSink) Close() error {
+	return s.connector.Disconnect()
+}
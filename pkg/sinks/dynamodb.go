@@ -0,0 +1,143 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// dynamoMaxBatch is the BatchWriteItem request limit
+const dynamoMaxBatch = 25
+
+// DynamoDBSinkConfig configures a DynamoDB table sink
+type DynamoDBSinkConfig struct {
+	Connection cloud.DynamoDBConfig
+	// BatchSize is capped at 25 items per request
+	BatchSize int
+	// MaxRetries bounds the resubmission of UnprocessedItems
+	MaxRetries int
+}
+
+// DynamoDBSink implements pipeline.PushSink using BatchWriteItem
+type DynamoDBSink struct {
+	connector *cloud.DynamoDBConnector
+	config    DynamoDBSinkConfig
+}
+
+// NewDynamoDBSink creates a new DynamoDB sink
+func NewDynamoDBSink(config DynamoDBSinkConfig) (*DynamoDBSink, error) {
+	if config.BatchSize <= 0 || config.BatchSize > dynamoMaxBatch {
+		config.BatchSize = dynamoMaxBatch
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = 8
+	}
+
+	connector := cloud.NewDynamoDBConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to dynamodb: %w", err)
+	}
+
+	return &DynamoDBSink{
+		connector: connector,
+		config:    config,
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *DynamoDBSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.writeBatch(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeBatch(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *DynamoDBSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	size := config.BatchSize
+	if size <= 0 || size > s.config.BatchSize {
+		size = s.config.BatchSize
+	}
+
+	for _, batch := range batchRecords(records, size) {
+		err := withRetry(ctx, config, func() error {
+			return s.writeBatch(ctx, batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBatch writes a batch of records. BatchWriteItem rejects requests that
+// touch the same key twice, so only the last record per key is sent.
+func (s *DynamoDBSink) writeBatch(ctx context.Context, batch []pipeline.Record) error {
+	keys := s.connector.KeyAttributes()
+	requests := make([]*dynamodb.WriteRequest, 0, len(batch))
+	positions := make(map[string]int, len(batch))
+	for _, record := range batch {
+		item, err := dynamodbattribute.MarshalMap(record.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record %s: %w", record.ID, err)
+		}
+		request := &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: item},
+		}
+
+		key := itemKey(keys, item)
+		if i, ok := positions[key]; ok {
+			requests[i] = request
+			continue
+		}
+		positions[key] = len(requests)
+		requests = append(requests, request)
+	}
+
+	if err := s.connector.BatchWrite(ctx, requests, s.config.MaxRetries); err != nil {
+		return fmt.Errorf("failed to batch write items: %w", err)
+	}
+	return nil
+}
+
+// itemKey renders the key attributes of an item as a comparable string
+func itemKey(keys []string, item map[string]*dynamodb.AttributeValue) string {
+	var key strings.Builder
+	for _, name := range keys {
+		if v, ok := item[name]; ok && v != nil {
+			key.WriteString(v.String())
+		}
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// Close implements pipeline.Sink
+func (s *DynamoDBSink) Close() error {
+	return s.connector.Disconnect()
+}
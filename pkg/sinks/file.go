@@ -0,0 +1,174 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/filesystem"
+)
+
+// RetentionConfig bounds the files a FileSink keeps. Only the sink's own
+// files under Prefix are considered: part-* files from any run and, for
+// MaxAge, their manifests. A zero limit is not enforced.
+type RetentionConfig struct {
+	// MaxAge deletes files last modified longer ago
+	MaxAge time.Duration
+	// MaxFiles keeps the newest files only
+	MaxFiles int
+	// MaxBytes keeps the newest files whose total size fits
+	MaxBytes int64
+}
+
+func (c RetentionConfig) enabled() bool {
+	return c.MaxAge > 0 || c.MaxFiles > 0 || c.MaxBytes > 0
+}
+
+// FileSinkConfig configures a local file sink
+type FileSinkConfig struct {
+	Connection filesystem.LocalFSConfig
+	ObjectSinkConfig
+	Retention RetentionConfig
+}
+
+// FileSink is an ObjectSink writing files under a local directory. Records
+// are buffered in hidden temporary files in BasePath, which listings and
+// globs skip, and each rolled file is renamed into place, so readers only
+// ever see complete files. Push renames its files before it returns. The
+// retention policy is applied after every roll.
+//
+// Any codec works, including codec.NewParquet: a file's encoder is closed,
+// writing the Parquet footer, before the file is renamed. Parquet holds a
+// row group in memory until it is full, so RollConfig.MaxBytes only sees
+// the bytes of row groups already written.
+type FileSink struct {
+	*ObjectSink
+	connector *filesystem.LocalFSConnector
+	retention RetentionConfig
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewFileSink creates a new local file sink
+func NewFileSink(config FileSinkConfig) (*FileSink, error) {
+	connector := filesystem.NewLocalFSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", config.Connection.BasePath, err)
+	}
+
+	sink, err := NewObjectSink(connector, config.ObjectSinkConfig)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileSink{ObjectSink: sink, connector: connector, retention: config.Retention}
+	// Buffer files are renamed, so they must be on the same filesystem
+	sink.roller.dir = config.Connection.BasePath
+	sink.put = s.rename
+	sink.disconnect = connector.Disconnect
+	if config.Retention.enabled() {
+		sink.saved = s.applyRetention
+	}
+	return s, nil
+}
+
+// rename moves a rolled file into place
+func (s *FileSink) rename(ctx context.Context, file *rolledFile, key string) error {
+	target, err := s.connector.FullPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// CreateTemp makes files only their owner can read
+	if err := os.Chmod(file.path, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.path, target)
+}
+
+// applyRetention deletes the files the retention policy no longer allows.
+// Failures are kept for Err rather than failing writes that succeeded.
+func (s *FileSink) applyRetention(ctx context.Context) {
+	if err := s.enforceRetention(ctx, time.Now()); err != nil {
+		s.mu.Lock()
+		s.err = fmt.Errorf("failed to apply retention: %w", err)
+		s.mu.Unlock()
+	}
+}
+
+func (s *FileSink) enforceRetention(ctx context.Context, now time.Time) error {
+	prefix := s.config.Prefix
+	manifests := prefix + "_manifests/"
+
+	var files, expired []connectors.ObjectInfo
+	err := s.connector.List(ctx, connectors.ListOptions{Prefix: prefix}, func(page []connectors.ObjectInfo) error {
+		for _, object := range page {
+			switch {
+			case strings.HasPrefix(object.Key, manifests):
+				if s.retention.MaxAge > 0 && now.Sub(object.ModTime) > s.retention.MaxAge {
+					expired = append(expired, object)
+				}
+			case strings.HasPrefix(path.Base(object.Key), "part-"):
+				files = append(files, object)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Newest first; keep files while every limit holds
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	var kept int
+	var keptBytes int64
+	for _, file := range files {
+		keep := (s.retention.MaxAge <= 0 || now.Sub(file.ModTime) <= s.retention.MaxAge) &&
+			(s.retention.MaxFiles <= 0 || kept < s.retention.MaxFiles) &&
+			(s.retention.MaxBytes <= 0 || keptBytes+file.Size <= s.retention.MaxBytes)
+		if keep {
+			kept++
+			keptBytes += file.Size
+			continue
+		}
+		expired = append(expired, file)
+	}
+
+	for _, file := range expired {
+		if err := s.connector.Delete(ctx, file.Key); err != nil {
+			return err
+		}
+		s.removeEmptyDirs(path.Dir(file.Key))
+	}
+	return nil
+}
+
+// removeEmptyDirs removes the partition directories left empty by
+// retention, up to the directory of Prefix
+func (s *FileSink) removeEmptyDirs(dir string) {
+	root := path.Dir(s.config.Prefix + "x")
+	for dir != root && dir != "." {
+		fullPath, err := s.connector.FullPath(dir)
+		if err != nil || os.Remove(fullPath) != nil {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// Err returns the last error that kept the retention policy from being
+// applied
+func (s *FileSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,37 @@
+package sinks
+
+import (
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+)
+
+// GCSSinkConfig configures a Google Cloud Storage sink. Files are written
+// with resumable uploads in chunks of Connection.ChunkSize; set
+// Connection.PreventOverwrite to never replace an existing object.
+type GCSSinkConfig struct {
+	Connection cloud.GCSConfig
+	ObjectSinkConfig
+}
+
+// GCSSink is an ObjectSink over a GCS bucket
+type GCSSink struct {
+	*ObjectSink
+	connector *cloud.GCSConnector
+}
+
+// NewGCSSink creates a new GCS sink
+func NewGCSSink(config GCSSinkConfig) (*GCSSink, error) {
+	connector := cloud.NewGCSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to gcs: %w", err)
+	}
+
+	sink, err := NewObjectSink(connector, config.ObjectSinkConfig)
+	if err != nil {
+		connector.Disconnect()
+		return nil, err
+	}
+	sink.disconnect = connector.Disconnect
+	return &GCSSink{ObjectSink: sink, connector: connector}, nil
+}
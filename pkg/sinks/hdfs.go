@@ -0,0 +1,180 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/filesystem"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// HDFS sink modes
+const (
+	// HDFSModeFiles writes rolled files, each renamed into place once
+	// complete
+	HDFSModeFiles = "files"
+	// HDFSModeAppend appends every batch to a long-lived file per
+	// partition, for continuous log-style output
+	HDFSModeAppend = "append"
+)
+
+// HDFSSinkConfig configures an HDFS sink. Files are created with
+// Connection.Replication and Connection.BlockSize.
+type HDFSSinkConfig struct {
+	Connection filesystem.HDFSConfig
+	ObjectSinkConfig
+	// Mode is HDFSModeFiles (default) or HDFSModeAppend. Append mode
+	// ignores Roll and Manifest.
+	Mode string
+	// AppendName names the file of each partition in append mode, which is
+	// Prefix + partition + AppendName + the codec's extension; defaults to
+	// "data"
+	AppendName string
+	// BatchSize is the number of records Write appends at a time in append
+	// mode; defaults to 1000
+	BatchSize int
+}
+
+// HDFSSink is an ObjectSink over a directory tree in HDFS, or in append
+// mode a writer of append-only files. In append mode every batch is
+// encoded and compressed on its own, so the codec must be appendable (see
+// codec.Appendable), and a batch whose append fails is appended again when
+// retried, so records may be written more than once. Only one sink may
+// append to a file at a time.
+type HDFSSink struct {
+	*ObjectSink
+	connector *filesystem.HDFSConnector
+	config    HDFSSinkConfig
+	partition *template.Template
+	extension string
+
+	mu sync.Mutex
+}
+
+// NewHDFSSink creates a new HDFS sink
+func NewHDFSSink(config HDFSSinkConfig) (*HDFSSink, error) {
+	if config.Mode == "" {
+		config.Mode = HDFSModeFiles
+	}
+	if config.Mode != HDFSModeFiles && config.Mode != HDFSModeAppend {
+		return nil, fmt.Errorf("unsupported hdfs sink mode: %s", config.Mode)
+	}
+	if config.AppendName == "" {
+		config.AppendName = "data"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.Mode == HDFSModeAppend && config.Codec != nil {
+		if err := checkAppendable(config.Codec); err != nil {
+			return nil, err
+		}
+	}
+
+	connector := filesystem.NewHDFSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to hdfs: %w", err)
+	}
+
+	sink, err := NewObjectSink(connector, config.ObjectSinkConfig)
+	if err != nil {
+		connector.Disconnect()
+		return nil, err
+	}
+	sink.disconnect = connector.Disconnect
+	// NewObjectSink validated the template and filled in the defaults
+	config.ObjectSinkConfig = sink.config
+	partition, _ := parsePartition(config.Partition)
+
+	return &HDFSSink{
+		ObjectSink: sink,
+		connector:  connector,
+		config:     config,
+		partition:  partition,
+		extension:  codec.Extension(config.Codec) + codec.CompressionExtension(config.Compression),
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *HDFSSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	if s.config.Mode == HDFSModeFiles {
+		return s.ObjectSink.Write(ctx, in)
+	}
+
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.append(ctx, batch, pipeline.PushConfig{}); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.append(ctx, batch, pipeline.PushConfig{})
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *HDFSSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	if s.config.Mode == HDFSModeFiles {
+		return s.ObjectSink.Push(ctx, records, config)
+	}
+	return s.append(ctx, records, config)
+}
+
+// append appends records to the files of their partitions
+func (s *HDFSSink) append(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	groups := make(map[string][]pipeline.Record)
+	for _, record := range records {
+		partition, err := renderPartition(s.partition, record)
+		if err != nil {
+			return err
+		}
+		key := s.config.Prefix + partition + s.config.AppendName + s.extension
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+
+	for _, key := range keys {
+		chunk, err := encodeRecords(s.config.Codec, s.config.Compression, groups[key])
+		if err != nil {
+			return err
+		}
+		err = withRetry(ctx, config, func() error {
+			writer, err := s.connector.Append(key)
+			if err != nil {
+				return err
+			}
+			if _, err := writer.Write(chunk); err != nil {
+				writer.Close()
+				return err
+			}
+			return writer.Close()
+		})
+		if err != nil {
+			return fmt.Errorf("failed to append to %s: %w", key, err)
+		}
+	}
+	return nil
+}
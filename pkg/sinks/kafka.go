@@ -0,0 +1,418 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// KeyExtractor returns a key for a record, such as the Kafka message key or
+// the SQS message group ID. A nil Kafka key lets the partitioner spread
+// records across partitions.
+type KeyExtractor func(record pipeline.Record) []byte
+
+// KeyFromID keys messages on Record.ID
+func KeyFromID(record pipeline.Record) []byte {
+	if record.ID == "" {
+		return nil
+	}
+	return []byte(record.ID)
+}
+
+// KeyFromField keys messages on the string form of a Record.Data field
+func KeyFromField(field string) KeyExtractor {
+	return func(record pipeline.Record) []byte {
+		v, ok := record.Data[field]
+		if !ok || v == nil {
+			return nil
+		}
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// KeyFromKafkaKey keys messages on the original key of Kafka-origin
+// records, falling back to Record.ID for other records
+func KeyFromKafkaKey(record pipeline.Record) []byte {
+	if meta, err := messaging.DecodeKafkaMetadata(record.Metadata); err == nil && meta.Key != nil {
+		return meta.Key
+	}
+	return KeyFromID(record)
+}
+
+// KafkaSinkConfig configures a Kafka sink
+type KafkaSinkConfig struct {
+	Brokers []string
+	Topic   string
+	// Version is the Kafka protocol version, e.g. "2.8.0"; idempotence needs
+	// at least 0.11 and zstd at least 2.1
+	Version string
+	// Codec encodes message values; defaults to JSON
+	Codec codec.Codec
+	// SchemaRegistry registers the schema of an Avro or Protobuf codec,
+	// after a compatibility check, and frames values with its ID
+	SchemaRegistry *messaging.SchemaRegistryConfig
+	// Subject defaults to "<topic>-value"
+	Subject string
+	// KeyExtractor defaults to KeyFromID
+	KeyExtractor KeyExtractor
+	// Partitioner is "hash" (default), "crc32", "random" or "roundrobin"
+	Partitioner string
+	// Headers maps Record.Metadata keys to Kafka header names
+	Headers map[string]string
+	// AllMetadataHeaders copies every metadata entry into a header of the
+	// same name
+	AllMetadataHeaders bool
+	// ForwardKafkaHeaders copies the original headers of Kafka-origin
+	// records, preserving their order and binary values
+	ForwardKafkaHeaders bool
+	// Compression is "none", "gzip", "snappy", "lz4" or "zstd"
+	Compression string
+	// RequiredAcks is "all" (default), "leader" or "none"
+	RequiredAcks    string
+	Idempotent      bool
+	TransactionalID string
+	MaxMessageBytes int
+	BatchSize       int
+}
+
+// KafkaPartitionMetrics holds delivery metrics for one partition
+type KafkaPartitionMetrics struct {
+	Delivered  int64
+	Failed     int64
+	Bytes      int64
+	LastOffset int64
+	LastSend   time.Time
+}
+
+// KafkaSink implements pipeline.PushSink for Kafka
+type KafkaSink struct {
+	connector *messaging.KafkaConnector
+	producer  sarama.SyncProducer
+	config    KafkaSinkConfig
+
+	mu      sync.RWMutex
+	metrics map[int32]*KafkaPartitionMetrics
+}
+
+// NewKafkaSink creates a new Kafka sink
+func NewKafkaSink(config KafkaSinkConfig) (*KafkaSink, error) {
+	if config.Topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	if config.KeyExtractor == nil {
+		config.KeyExtractor = KeyFromID
+	}
+	if config.Codec == nil {
+		config.Codec = codec.NewJSON()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+
+	if config.SchemaRegistry != nil {
+		if err := bindRegistryCodec(config); err != nil {
+			return nil, err
+		}
+	}
+
+	saramaConfig, err := kafkaProducerConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	connector := messaging.NewKafkaConnector(messaging.KafkaConfig{
+		Brokers: config.Brokers,
+		Topic:   config.Topic,
+	})
+	producer, err := connector.NewSyncProducer(saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+
+	return &KafkaSink{
+		connector: connector,
+		producer:  producer,
+		config:    config,
+		metrics:   make(map[int32]*KafkaPartitionMetrics),
+	}, nil
+}
+
+// bindRegistryCodec registers the codec schema so values are produced in
+// the Confluent wire format
+func bindRegistryCodec(config KafkaSinkConfig) error {
+	registryCodec, ok := config.Codec.(codec.RegistryCodec)
+	if !ok {
+		return fmt.Errorf("%s codec cannot be used with a schema registry", config.Codec.Name())
+	}
+	subject := config.Subject
+	if subject == "" {
+		subject = messaging.TopicSubject(config.Topic, false)
+	}
+
+	registry := messaging.NewSchemaRegistryConnector(*config.SchemaRegistry)
+	if err := registry.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to schema registry: %w", err)
+	}
+	if err := registry.BindCodec(context.Background(), subject, registryCodec); err != nil {
+		return fmt.Errorf("failed to register schema: %w", err)
+	}
+	return nil
+}
+
+func kafkaProducerConfig(config KafkaSinkConfig) (*sarama.Config, error) {
+	c := sarama.NewConfig()
+	c.Producer.RequiredAcks = sarama.WaitForAll
+
+	if config.Version != "" {
+		version, err := sarama.ParseKafkaVersion(config.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kafka version: %w", err)
+		}
+		c.Version = version
+	}
+
+	switch config.RequiredAcks {
+	case "", "all":
+	case "leader":
+		c.Producer.RequiredAcks = sarama.WaitForLocal
+	case "none":
+		c.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		return nil, fmt.Errorf("unknown required acks: %s", config.RequiredAcks)
+	}
+
+	switch config.Compression {
+	case "", "none":
+		c.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		c.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		c.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		c.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		c.Producer.Compression = sarama.CompressionZSTD
+	default:
+		return nil, fmt.Errorf("unknown compression codec: %s", config.Compression)
+	}
+
+	switch config.Partitioner {
+	case "", "hash":
+		c.Producer.Partitioner = sarama.NewHashPartitioner
+	case "crc32":
+		c.Producer.Partitioner = sarama.NewConsistentCRCHashPartitioner
+	case "random":
+		c.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "roundrobin":
+		c.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	default:
+		return nil, fmt.Errorf("unknown partitioner: %s", config.Partitioner)
+	}
+
+	if config.MaxMessageBytes > 0 {
+		c.Producer.MaxMessageBytes = config.MaxMessageBytes
+	}
+
+	// Idempotence and transactions share the same broker requirements
+	if config.Idempotent || config.TransactionalID != "" {
+		if !c.Version.IsAtLeast(sarama.V0_11_0_0) {
+			c.Version = sarama.V2_1_0_0
+		}
+		c.Producer.Idempotent = true
+		c.Producer.RequiredAcks = sarama.WaitForAll
+		c.Net.MaxOpenRequests = 1
+	}
+	if config.TransactionalID != "" {
+		c.Producer.Transaction.ID = config.TransactionalID
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid producer configuration: %w", err)
+	}
+	return c, nil
+}
+
+// Write implements pipeline.Sink
+func (s *KafkaSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.writeBatch(batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeBatch(batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *KafkaSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	size := config.BatchSize
+	if size <= 0 {
+		size = s.config.BatchSize
+	}
+
+	for _, batch := range batchRecords(records, size) {
+		err := withRetry(ctx, config, func() error {
+			return s.writeBatch(batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBatch produces a batch, inside a transaction when one is configured
+func (s *KafkaSink) writeBatch(batch []pipeline.Record) error {
+	messages := make([]*sarama.ProducerMessage, 0, len(batch))
+	for _, record := range batch {
+		msg, err := s.message(record)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, msg)
+	}
+
+	if s.producer.IsTransactional() {
+		if err := s.producer.BeginTxn(); err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+	}
+
+	err := s.producer.SendMessages(messages)
+	s.recordDelivery(messages, err)
+
+	if s.producer.IsTransactional() {
+		if err != nil {
+			if abortErr := s.producer.AbortTxn(); abortErr != nil {
+				return fmt.Errorf("failed to abort transaction after %v: %w", err, abortErr)
+			}
+			return fmt.Errorf("failed to produce messages: %w", err)
+		}
+		if err := s.producer.CommitTxn(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to produce messages: %w", err)
+	}
+	return nil
+}
+
+func (s *KafkaSink) message(record pipeline.Record) (*sarama.ProducerMessage, error) {
+	value, err := s.config.Codec.Encode(record.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: s.config.Topic,
+		Value: sarama.ByteEncoder(value),
+	}
+	if key := s.config.KeyExtractor(record); key != nil {
+		msg.Key = sarama.ByteEncoder(key)
+	}
+	if record.Timestamp > 0 {
+		msg.Timestamp = time.Unix(record.Timestamp, 0)
+	}
+
+	if s.config.ForwardKafkaHeaders && messaging.HasKafkaMetadata(record.Metadata) {
+		meta, err := messaging.DecodeKafkaMetadata(record.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka metadata of record %s: %w", record.ID, err)
+		}
+		for _, h := range meta.Headers {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{
+				Key:   []byte(h.Key),
+				Value: h.Value,
+			})
+		}
+	}
+
+	for metaKey, v := range record.Metadata {
+		name, ok := s.config.Headers[metaKey]
+		if !ok && s.config.AllMetadataHeaders {
+			name, ok = metaKey, true
+		}
+		if ok {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{
+				Key:   []byte(name),
+				Value: []byte(v),
+			})
+		}
+	}
+	return msg, nil
+}
+
+// recordDelivery updates the per-partition metrics after a send
+func (s *KafkaSink) recordDelivery(messages []*sarama.ProducerMessage, sendErr error) {
+	failed := make(map[*sarama.ProducerMessage]bool)
+	var producerErrs sarama.ProducerErrors
+	if errors.As(sendErr, &producerErrs) {
+		for _, perr := range producerErrs {
+			failed[perr.Msg] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, msg := range messages {
+		m, ok := s.metrics[msg.Partition]
+		if !ok {
+			m = &KafkaPartitionMetrics{}
+			s.metrics[msg.Partition] = m
+		}
+		if failed[msg] || (sendErr != nil && len(producerErrs) == 0) {
+			m.Failed++
+			continue
+		}
+		m.Delivered++
+		m.Bytes += int64(msg.Value.Length())
+		m.LastOffset = msg.Offset
+		m.LastSend = now
+	}
+}
+
+// PartitionMetrics returns a snapshot of delivery metrics keyed by partition
+func (s *KafkaSink) PartitionMetrics() map[int32]KafkaPartitionMetrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := make(map[int32]KafkaPartitionMetrics, len(s.metrics))
+	for partition, m := range s.metrics {
+		snapshot[partition] = *m
+	}
+	return snapshot
+}
+
+// Close implements pipeline.Sink
+func (s *KafkaSink) Close() error {
+	return s.producer.Close()
+}
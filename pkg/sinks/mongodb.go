@@ -0,0 +1,196 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/database"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoDBWriteMode selects the write model used for each record
+type MongoDBWriteMode string
+
+const (
+	// MongoDBInsert inserts every record as a new document
+	MongoDBInsert MongoDBWriteMode = "insert"
+	// MongoDBUpsert $sets the record fields on the document with _id = Record.ID
+	MongoDBUpsert MongoDBWriteMode = "upsert"
+	// MongoDBReplace replaces the document with _id = Record.ID
+	MongoDBReplace MongoDBWriteMode = "replace"
+)
+
+// MongoDBSinkConfig configures a MongoDB sink
+type MongoDBSinkConfig struct {
+	Connection database.MongoDBConfig
+	Collection string
+	Mode       MongoDBWriteMode
+	BatchSize  int
+	// Ordered stops a bulk write at the first failing operation
+	Ordered bool
+	// ObjectIDKeys stores Record.ID values that are valid hex ObjectIDs as
+	// ObjectIDs rather than strings
+	ObjectIDKeys bool
+	// ApplyDeletes turns records whose mongodb.operation metadata is
+	// "delete" into DeleteOne operations. Otherwise these records, which
+	// carry only the document key, are skipped.
+	ApplyDeletes bool
+}
+
+// MongoDBSink implements pipeline.PushSink using BulkWrite
+type MongoDBSink struct {
+	connector *database.MongoDBConnector
+	config    MongoDBSinkConfig
+}
+
+// NewMongoDBSink creates a new MongoDB sink
+func NewMongoDBSink(config MongoDBSinkConfig) (*MongoDBSink, error) {
+	if config.Collection == "" {
+		return nil, fmt.Errorf("collection is required")
+	}
+	if config.Mode == "" {
+		config.Mode = MongoDBInsert
+	}
+	switch config.Mode {
+	case MongoDBInsert, MongoDBUpsert, MongoDBReplace:
+	default:
+		return nil, fmt.Errorf("unknown write mode: %s", config.Mode)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+
+	connector := database.NewMongoDBConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to mongodb: %w", err)
+	}
+
+	return &MongoDBSink{
+		connector: connector,
+		config:    config,
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *MongoDBSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.writeBatch(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeBatch(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *MongoDBSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	size := config.BatchSize
+	if size <= 0 {
+		size = s.config.BatchSize
+	}
+
+	for _, batch := range batchRecords(records, size) {
+		err := withRetry(ctx, config, func() error {
+			return s.writeBatch(ctx, batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MongoDBSink) writeBatch(ctx context.Context, batch []pipeline.Record) error {
+	models := make([]mongo.WriteModel, 0, len(batch))
+	for _, record := range batch {
+		model, err := s.writeModel(record)
+		if err != nil {
+			return err
+		}
+		if model != nil {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	_, err := s.connector.BulkWrite(ctx, s.config.Collection, models, s.config.Ordered)
+	if err != nil {
+		return fmt.Errorf("failed to perform bulk write: %w", err)
+	}
+	return nil
+}
+
+// writeModel returns the operation for a record, or nil for a delete that
+// is not applied
+func (s *MongoDBSink) writeModel(record pipeline.Record) (mongo.WriteModel, error) {
+	doc := bson.M{}
+	for k, v := range record.Data {
+		doc[k] = v
+	}
+
+	if record.Metadata[database.MongoMetaOperation] == "delete" {
+		if !s.config.ApplyDeletes {
+			// Writing the bare key would blank or insert the document
+			return nil, nil
+		}
+		if record.ID == "" {
+			return nil, fmt.Errorf("delete requires a record ID")
+		}
+		return mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": s.documentID(record.ID)}), nil
+	}
+
+	if s.config.Mode == MongoDBInsert {
+		if record.ID != "" {
+			doc["_id"] = s.documentID(record.ID)
+		}
+		return mongo.NewInsertOneModel().SetDocument(doc), nil
+	}
+
+	if record.ID == "" {
+		return nil, fmt.Errorf("%s mode requires a record ID", s.config.Mode)
+	}
+	filter := bson.M{"_id": s.documentID(record.ID)}
+	delete(doc, "_id")
+
+	// An empty $set is rejected by the server, so fall back to a replace
+	if s.config.Mode == MongoDBReplace || len(doc) == 0 {
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true), nil
+	}
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": doc}).SetUpsert(true), nil
+}
+
+func (s *MongoDBSink) documentID(id string) interface{} {
+	if s.config.ObjectIDKeys {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			return oid
+		}
+	}
+	return id
+}
+
+// Close implements pipeline.Sink
+func (s *MongoDBSink) Close() error {
+	return s.connector.Disconnect()
+}
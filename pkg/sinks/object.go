@@ -0,0 +1,293 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// ObjectSinkConfig configures an ObjectSink
+type ObjectSinkConfig struct {
+	// Prefix is prepended to every key
+	Prefix string
+	// Partition is a text/template for the path between Prefix and the
+	// file name, executed with PartitionData, e.g. "dt={{.Date}}/hour={{.Hour}}/"
+	Partition string
+	// Codec encodes files; defaults to JSON lines
+	Codec codec.Codec
+	// Compression is codec.CompressionNone (default), CompressionGzip or
+	// CompressionZstd
+	Compression string
+	Roll        RollConfig
+	// Manifest writes a JSON manifest listing the objects of every flush
+	// under Prefix + "_manifests/"
+	Manifest bool
+	// FlushTimeout bounds the flush of open files when Write's context is
+	// cancelled or the sink is closed; defaults to one minute
+	FlushTimeout time.Duration
+}
+
+// ManifestEntry describes one object in a manifest
+type ManifestEntry struct {
+	Key       string `json:"key"`
+	Partition string `json:"partition,omitempty"`
+	Records   int    `json:"records"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Manifest lists the objects written by one flush
+type Manifest struct {
+	Created time.Time       `json:"created"`
+	Objects []ManifestEntry `json:"objects"`
+}
+
+// ObjectSink implements pipeline.PushSink by archiving records to any
+// connectors.ObjectStore in files rolled by record count, size and age.
+// Records are buffered in local temporary files. Push writes every file
+// before it returns, so records the source commits afterwards are durable;
+// Write keeps files open across the stream and writes them as they roll,
+// and writes the open files when its context is cancelled, which is how a
+// streaming pipeline stops. Close writes any files still open.
+type ObjectSink struct {
+	store  connectors.ObjectStore
+	config ObjectSinkConfig
+
+	mu     sync.Mutex
+	roller *roller
+	namer  *fileNamer
+
+	// put stores a rolled file under key; it uploads by default. saved
+	// is called after every save that stored files. disconnect, if set,
+	// is called by Close once the open files are written.
+	put        func(ctx context.Context, file *rolledFile, key string) error
+	saved      func(ctx context.Context)
+	disconnect func() error
+}
+
+// NewObjectSink creates a sink writing to store
+func NewObjectSink(store connectors.ObjectStore, config ObjectSinkConfig) (*ObjectSink, error) {
+	if config.Codec == nil {
+		config.Codec = codec.NewJSONLines()
+	}
+	if config.Compression == "" {
+		config.Compression = codec.CompressionNone
+	}
+	if _, err := codec.Compress(nil, config.Compression); err != nil {
+		return nil, err
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = time.Minute
+	}
+	partition, err := parsePartition(config.Partition)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &ObjectSink{
+		store:  store,
+		config: config,
+		roller: newRoller(config.Roll, config.Codec, config.Compression, partition),
+		namer:  newFileNamer(config.Codec, config.Compression),
+	}
+	sink.put = sink.upload
+	return sink, nil
+}
+
+// Write implements pipeline.Sink
+func (s *ObjectSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	ticker := time.NewTicker(s.checkInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.flushDetached(ctx); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-ticker.C:
+			s.mu.Lock()
+			rolled, err := s.roller.expired(time.Now())
+			if err == nil {
+				err = s.save(ctx, rolled, pipeline.PushConfig{})
+			}
+			s.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		case record, ok := <-in:
+			if !ok {
+				return s.Flush(ctx)
+			}
+			s.mu.Lock()
+			rolled, err := s.roller.write(record)
+			if err == nil && rolled != nil {
+				err = s.save(ctx, []*rolledFile{rolled}, pipeline.PushConfig{})
+			}
+			s.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Push implements pipeline.PushSink
+func (s *ObjectSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rolled []*rolledFile
+	for _, record := range records {
+		file, err := s.roller.write(record)
+		if err != nil {
+			s.roller.discard()
+			removeRolled(rolled)
+			return err
+		}
+		if file != nil {
+			rolled = append(rolled, file)
+		}
+	}
+	rest, err := s.roller.rollAll()
+	rolled = append(rolled, rest...)
+	if err != nil {
+		s.roller.discard()
+		removeRolled(rolled)
+		return err
+	}
+	return s.save(ctx, rolled, config)
+}
+
+// Flush uploads every open file
+func (s *ObjectSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rolled, err := s.roller.rollAll()
+	if err != nil {
+		removeRolled(rolled)
+		return err
+	}
+	return s.save(ctx, rolled, pipeline.PushConfig{})
+}
+
+// flushDetached flushes within FlushTimeout, whether or not ctx is done
+func (s *ObjectSink) flushDetached(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.config.FlushTimeout)
+	defer cancel()
+	return s.Flush(ctx)
+}
+
+// save writes rolled files to the store, then their manifest. The local
+// files are removed whether or not that succeeds.
+func (s *ObjectSink) save(ctx context.Context, rolled []*rolledFile, config pipeline.PushConfig) error {
+	defer removeRolled(rolled)
+	if len(rolled) == 0 {
+		return nil
+	}
+
+	manifest := Manifest{Created: time.Now().UTC()}
+	for _, file := range rolled {
+		key := s.config.Prefix + file.partition + s.namer.next()
+		err := withRetry(ctx, config, func() error {
+			return s.put(ctx, file, key)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+		manifest.Objects = append(manifest.Objects, ManifestEntry{
+			Key:       key,
+			Partition: file.partition,
+			Records:   file.records,
+			Bytes:     file.bytes,
+		})
+	}
+
+	if s.saved != nil {
+		defer s.saved(ctx)
+	}
+	if !s.config.Manifest {
+		return nil
+	}
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	key := s.config.Prefix + "_manifests/" + s.namer.run + fmt.Sprintf("-%05d.json", s.namer.sequence)
+	err = withRetry(ctx, config, func() error {
+		return putObject(ctx, s.store, key, bytes.NewReader(body), "application/json")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", key, err)
+	}
+	return nil
+}
+
+// upload writes a rolled file to the store
+func (s *ObjectSink) upload(ctx context.Context, file *rolledFile, key string) error {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return putObject(ctx, s.store, key, f, s.config.Codec.ContentType())
+}
+
+// putObject writes an object, abandoning it if the copy fails
+func putObject(ctx context.Context, store connectors.ObjectStore, key string, r io.Reader, contentType string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := store.Create(ctx, key, connectors.WriteOptions{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *ObjectSink) checkInterval() time.Duration {
+	interval := s.roller.config.MaxAge / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+func removeRolled(rolled []*rolledFile) {
+	for _, file := range rolled {
+		file.remove()
+	}
+}
+
+// Close implements pipeline.Sink. It writes the files still open; those
+// that cannot be written within FlushTimeout are dropped. A sink created
+// with NewObjectSink leaves the store open.
+func (s *ObjectSink) Close() error {
+	err := s.flushDetached(context.Background())
+
+	s.mu.Lock()
+	s.roller.discard()
+	s.mu.Unlock()
+
+	if s.disconnect != nil {
+		if disconnectErr := s.disconnect(); err == nil {
+			err = disconnectErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,258 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+	"github.com/streadway/amqp"
+)
+
+// RabbitMQSinkConfig configures a RabbitMQ publisher sink
+type RabbitMQSinkConfig struct {
+	Connection messaging.RabbitMQConfig
+	// RoutingKey is a text/template evaluated against each record, e.g.
+	// "orders.{{.Data.region}}". Defaults to Connection.RoutingKey.
+	RoutingKey string
+	// Codec encodes message bodies; defaults to JSON
+	Codec codec.Codec
+	// Transient publishes non-persistent messages
+	Transient bool
+	// Mandatory fails a batch when a message cannot be routed to a queue
+	Mandatory bool
+	// ConfirmTimeout bounds the wait for publisher confirms
+	ConfirmTimeout time.Duration
+	BatchSize      int
+}
+
+// RabbitMQSink implements pipeline.PushSink using publisher confirms
+type RabbitMQSink struct {
+	connector  *messaging.RabbitMQConnector
+	config     RabbitMQSinkConfig
+	routingKey *template.Template
+	confirms   <-chan amqp.Confirmation
+	returns    <-chan amqp.Return
+	// published is the delivery tag of the last published message
+	published uint64
+}
+
+// NewRabbitMQSink creates a new RabbitMQ sink
+func NewRabbitMQSink(config RabbitMQSinkConfig) (*RabbitMQSink, error) {
+	if config.Codec == nil {
+		config.Codec = codec.NewJSON()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.ConfirmTimeout <= 0 {
+		config.ConfirmTimeout = 30 * time.Second
+	}
+	if config.RoutingKey == "" {
+		config.RoutingKey = config.Connection.RoutingKey
+	}
+
+	var routingKey *template.Template
+	if strings.Contains(config.RoutingKey, "{{") {
+		tmpl, err := template.New("routing_key").Option("missingkey=error").Parse(config.RoutingKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid routing key template: %w", err)
+		}
+		routingKey = tmpl
+	}
+
+	connector := messaging.NewRabbitMQConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to rabbitmq: %w", err)
+	}
+	confirms, returns, err := connector.EnableConfirms(config.BatchSize)
+	if err != nil {
+		connector.Disconnect()
+		return nil, err
+	}
+
+	return &RabbitMQSink{
+		connector:  connector,
+		config:     config,
+		routingKey: routingKey,
+		confirms:   confirms,
+		returns:    returns,
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *RabbitMQSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.publishBatch(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.publishBatch(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *RabbitMQSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	size := config.BatchSize
+	if size <= 0 || size > s.config.BatchSize {
+		size = s.config.BatchSize
+	}
+
+	for _, batch := range batchRecords(records, size) {
+		err := withRetry(ctx, config, func() error {
+			return s.publishBatch(ctx, batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishBatch publishes a batch and waits until the broker has confirmed
+// every message. The batch never exceeds the confirm channel buffer.
+func (s *RabbitMQSink) publishBatch(ctx context.Context, batch []pipeline.Record) error {
+	ch := s.connector.Channel()
+	first := s.published + 1
+	// pending counts the message IDs of the batch, to tell its returns
+	// from those left over by an earlier batch that timed out
+	pending := make(map[string]int, len(batch))
+	for _, record := range batch {
+		msg, key, err := s.message(record, s.published+1)
+		if err != nil {
+			return err
+		}
+		pending[msg.MessageId]++
+		if err := ch.Publish(s.config.Connection.Exchange, key, s.config.Mandatory, false, msg); err != nil {
+			return fmt.Errorf("failed to publish record %s: %w", record.ID, err)
+		}
+		s.published++
+	}
+
+	timeout := time.NewTimer(s.config.ConfirmTimeout)
+	defer timeout.Stop()
+
+	var returned, nacked int
+	for confirmed := 0; confirmed < len(batch); {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("timed out waiting for %d publisher confirms", len(batch)-confirmed)
+		case ret, ok := <-s.returns:
+			if !ok {
+				return fmt.Errorf("channel closed while waiting for publisher confirms")
+			}
+			if takeReturn(pending, ret) {
+				returned++
+			}
+		case c, ok := <-s.confirms:
+			if !ok {
+				return fmt.Errorf("channel closed while waiting for publisher confirms")
+			}
+			// Skip late confirms from an earlier batch that timed out
+			if c.DeliveryTag < first {
+				continue
+			}
+			if !c.Ack {
+				nacked++
+			}
+			confirmed++
+		}
+	}
+
+	// Returns are delivered before the confirm of the same message
+	for drained := false; !drained; {
+		select {
+		case ret, ok := <-s.returns:
+			if !ok {
+				drained = true
+			} else if takeReturn(pending, ret) {
+				returned++
+			}
+		default:
+			drained = true
+		}
+	}
+
+	if nacked > 0 {
+		return fmt.Errorf("broker rejected %d of %d messages", nacked, len(batch))
+	}
+	if returned > 0 {
+		return fmt.Errorf("%d of %d messages could not be routed", returned, len(batch))
+	}
+	return nil
+}
+
+// takeReturn reports whether a returned message is one of the pending
+// messages of the batch, and removes it from pending
+func takeReturn(pending map[string]int, ret amqp.Return) bool {
+	if pending[ret.MessageId] == 0 {
+		return false
+	}
+	pending[ret.MessageId]--
+	return true
+}
+
+// message builds the publishing of a record. Records without an ID are
+// published with their delivery tag as message ID, so returns can still be
+// matched to their batch.
+func (s *RabbitMQSink) message(record pipeline.Record, tag uint64) (amqp.Publishing, string, error) {
+	body, err := s.config.Codec.Encode(record.Data)
+	if err != nil {
+		return amqp.Publishing{}, "", fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+	}
+
+	key := s.config.RoutingKey
+	if s.routingKey != nil {
+		var buf strings.Builder
+		if err := s.routingKey.Execute(&buf, record); err != nil {
+			return amqp.Publishing{}, "", fmt.Errorf("failed to render routing key for record %s: %w", record.ID, err)
+		}
+		key = buf.String()
+	}
+
+	msg := amqp.Publishing{
+		ContentType:  s.config.Codec.ContentType(),
+		DeliveryMode: amqp.Persistent,
+		MessageId:    record.ID,
+		Body:         body,
+	}
+	if msg.MessageId == "" {
+		msg.MessageId = strconv.FormatUint(tag, 10)
+	}
+	if s.config.Transient {
+		msg.DeliveryMode = amqp.Transient
+	}
+	if record.Timestamp > 0 {
+		msg.Timestamp = time.Unix(record.Timestamp, 0)
+	}
+	return msg, key, nil
+}
+
+// Close implements pipeline.Sink
+func (s *RabbitMQSink) Close() error {
+	return s.connector.Disconnect()
+}
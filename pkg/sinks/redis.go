@@ -0,0 +1,251 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Redis sink modes
+const (
+	// RedisModeStream appends each record to a stream with XADD
+	RedisModeStream = "stream"
+	// RedisModeHash writes each record to the hash KeyPrefix+Record.ID
+	RedisModeHash = "hash"
+	// RedisModePubSub publishes each record to a channel
+	RedisModePubSub = "pubsub"
+)
+
+// RedisSinkConfig configures a Redis sink
+type RedisSinkConfig struct {
+	Connection messaging.RedisConfig
+	// Mode is RedisModeStream (default), RedisModeHash or RedisModePubSub
+	Mode string
+	// Stream is the stream written in stream mode
+	Stream string
+	// MaxLen caps the stream length on every XADD; zero leaves it unbounded
+	MaxLen int64
+	// ApproxMaxLen trims with "~", which is much cheaper for Redis
+	ApproxMaxLen bool
+	// KeyPrefix is prepended to Record.ID to form the key in hash mode
+	KeyPrefix string
+	// TTL sets an expiry on hashes; zero keeps them forever
+	TTL time.Duration
+	// Channel is the channel published to in pub/sub mode
+	Channel string
+	// Codec encodes the record data into PayloadField of stream entries and
+	// hashes. Without it every top-level field is written separately. In
+	// pub/sub mode it encodes the message and defaults to JSON.
+	Codec        codec.Codec
+	PayloadField string
+	BatchSize    int
+}
+
+// RedisSink implements pipeline.PushSink, writing each batch in a single
+// pipeline round trip
+type RedisSink struct {
+	connector *messaging.RedisConnector
+	config    RedisSinkConfig
+}
+
+// NewRedisSink creates a new Redis sink
+func NewRedisSink(config RedisSinkConfig) (*RedisSink, error) {
+	if config.Mode == "" {
+		config.Mode = RedisModeStream
+	}
+	switch config.Mode {
+	case RedisModeStream:
+		if config.Stream == "" {
+			return nil, fmt.Errorf("stream is required in stream mode")
+		}
+	case RedisModeHash:
+	case RedisModePubSub:
+		if config.Channel == "" {
+			return nil, fmt.Errorf("channel is required in pubsub mode")
+		}
+		if config.Codec == nil {
+			config.Codec = codec.NewJSON()
+		}
+	default:
+		return nil, fmt.Errorf("unsupported redis sink mode: %s", config.Mode)
+	}
+	if config.Codec != nil && config.PayloadField == "" {
+		config.PayloadField = "payload"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	connector := messaging.NewRedisConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisSink{
+		connector: connector,
+		config:    config,
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *RedisSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.writeBatch(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeBatch(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *RedisSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	size := config.BatchSize
+	if size <= 0 {
+		size = s.config.BatchSize
+	}
+
+	for _, batch := range batchRecords(records, size) {
+		err := withRetry(ctx, config, func() error {
+			return s.writeBatch(ctx, batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBatch queues one command per record, plus an EXPIRE for hashes with
+// a TTL, and sends them as a single pipeline
+func (s *RedisSink) writeBatch(ctx context.Context, batch []pipeline.Record) error {
+	_, err := s.connector.Client().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, record := range batch {
+			if err := s.queue(ctx, pipe, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write batch to redis: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisSink) queue(ctx context.Context, pipe redis.Pipeliner, record pipeline.Record) error {
+	if s.config.Mode == RedisModePubSub {
+		body, err := s.config.Codec.Encode(record.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+		}
+		pipe.Publish(ctx, s.config.Channel, body)
+		return nil
+	}
+
+	values, err := s.values(record)
+	if err != nil {
+		return err
+	}
+
+	switch s.config.Mode {
+	case RedisModeStream:
+		args := &redis.XAddArgs{
+			Stream: s.config.Stream,
+			Values: values,
+		}
+		if s.config.MaxLen > 0 {
+			args.MaxLen = s.config.MaxLen
+			args.Approx = s.config.ApproxMaxLen
+		}
+		pipe.XAdd(ctx, args)
+	case RedisModeHash:
+		if record.ID == "" {
+			return fmt.Errorf("record has no ID to key the hash on")
+		}
+		key := s.config.KeyPrefix + record.ID
+		pipe.HSet(ctx, key, values)
+		if s.config.TTL > 0 {
+			pipe.Expire(ctx, key, s.config.TTL)
+		}
+	}
+	return nil
+}
+
+// values returns the fields written for a record in stream and hash mode
+func (s *RedisSink) values(record pipeline.Record) (map[string]interface{}, error) {
+	if s.config.Codec != nil {
+		body, err := s.config.Codec.Encode(record.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+		}
+		return map[string]interface{}{s.config.PayloadField: body}, nil
+	}
+
+	if len(record.Data) == 0 {
+		return nil, fmt.Errorf("record %s has no fields to write", record.ID)
+	}
+	values := make(map[string]interface{}, len(record.Data))
+	for k, v := range record.Data {
+		value, err := redisValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert field %s of record %s: %w", k, record.ID, err)
+		}
+		values[k] = value
+	}
+	return values, nil
+}
+
+// redisValue converts a field value to one Redis stores as a string.
+// Nested values are written as JSON.
+func redisValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string, []byte,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), nil
+	case json.Number:
+		return x.String(), nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close implements pipeline.Sink
+func (s *RedisSink) Close() error {
+	return s.connector.Disconnect()
+}
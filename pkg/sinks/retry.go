@@ -0,0 +1,56 @@
+package sinks
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// baseRetryDelay is the delay before the first retry of a failed push
+const baseRetryDelay = 100 * time.Millisecond
+
+// withRetry runs fn until it succeeds or config.MaxRetries retries have been
+// made, backing off by config.BackoffFactor between attempts
+func withRetry(ctx context.Context, config pipeline.PushConfig, fn func() error) error {
+	factor := config.BackoffFactor
+	if factor < 1 {
+		factor = 1
+	}
+
+	var err error
+	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := time.Duration(float64(baseRetryDelay) * math.Pow(factor, float64(attempt-1)))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// batchRecords splits records into slices of at most size records
+func batchRecords(records []pipeline.Record, size int) [][]pipeline.Record {
+	if size <= 0 || size >= len(records) {
+		if len(records) == 0 {
+			return nil
+		}
+		return [][]pipeline.Record{records}
+	}
+	batches := make([][]pipeline.Record, 0, (len(records)+size-1)/size)
+	for start := 0; start < len(records); start += size {
+		end := start + size
+		if end > len(records) {
+			end = len(records)
+		}
+		batches = append(batches, records[start:end])
+	}
+	return batches
+}
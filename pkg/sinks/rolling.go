@@ -0,0 +1,333 @@
+package sinks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// RollConfig controls when a file sink closes a file and starts the next.
+// A file is rolled as soon as any limit is reached.
+type RollConfig struct {
+	// MaxRecords defaults to 100000
+	MaxRecords int
+	// MaxBytes counts bytes after compression; defaults to 128 MiB
+	MaxBytes int64
+	// MaxAge is measured from the first record; defaults to five minutes
+	MaxAge time.Duration
+}
+
+func (c *RollConfig) setDefaults() {
+	if c.MaxRecords <= 0 {
+		c.MaxRecords = 100000
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = 128 * 1024 * 1024
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 5 * time.Minute
+	}
+}
+
+// PartitionData is what partition templates are executed with, e.g.
+// "dt={{.Date}}/hour={{.Hour}}/". Times come from Record.Timestamp in UTC,
+// or the current time for records without one.
+type PartitionData struct {
+	Record pipeline.Record
+	Time   time.Time
+	// Date is formatted as 2006-01-02
+	Date   string
+	Year   string
+	Month  string
+	Day    string
+	Hour   string
+	Minute string
+}
+
+func newPartitionData(record pipeline.Record) PartitionData {
+	t := time.Now().UTC()
+	if record.Timestamp > 0 {
+		t = time.Unix(record.Timestamp, 0).UTC()
+	}
+	return PartitionData{
+		Record: record,
+		Time:   t,
+		Date:   t.Format("2006-01-02"),
+		Year:   t.Format("2006"),
+		Month:  t.Format("01"),
+		Day:    t.Format("02"),
+		Hour:   t.Format("15"),
+		Minute: t.Format("04"),
+	}
+}
+
+// renderPartition executes a partition template for a record; a nil
+// template yields ""
+func renderPartition(tmpl *template.Template, record pipeline.Record) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, newPartitionData(record)); err != nil {
+		return "", fmt.Errorf("failed to render partition for record %s: %w", record.ID, err)
+	}
+	return buf.String(), nil
+}
+
+// encodeRecords encodes and compresses records into one self-contained
+// chunk, for sinks that append batches to a file. It rejects codecs whose
+// chunks do not concatenate, see codec.Appendable.
+func encodeRecords(c codec.Codec, compression string, records []pipeline.Record) ([]byte, error) {
+	if err := checkAppendable(c); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	compressor, err := codec.Compress(&buf, compression)
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := c.NewEncoder(compressor)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err := encoder.Encode(record.Data); err != nil {
+			return nil, fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func checkAppendable(c codec.Codec) error {
+	if !codec.Appendable(c) {
+		return fmt.Errorf("%s codec cannot append to files: use JSON lines, or CSV without a header row", c.Name())
+	}
+	return nil
+}
+
+// parsePartition parses a partition template; an empty one yields nil
+func parsePartition(partition string) (*template.Template, error) {
+	if partition == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("partition").Option("missingkey=error").Parse(partition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid partition template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// rolledFile is a closed file waiting to be uploaded
+type rolledFile struct {
+	partition string
+	path      string
+	records   int
+	bytes     int64
+}
+
+func (f *rolledFile) remove() {
+	os.Remove(f.path)
+}
+
+// openFile is a file still receiving records
+type openFile struct {
+	partition  string
+	file       *os.File
+	counter    *countingWriter
+	compressor io.WriteCloser
+	encoder    codec.Encoder
+	records    int
+	opened     time.Time
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// rollPattern names buffer files. They are hidden, and named like the
+// temporary files of the filesystem connectors, so listings skip them.
+const rollPattern = ".datapipe-roll.tmp-*"
+
+// roller buffers encoded records in temporary files, one per partition,
+// and hands them back once they are rolled
+type roller struct {
+	config      RollConfig
+	codec       codec.Codec
+	compression string
+	partition   *template.Template
+	files       map[string]*openFile
+	// dir holds the buffer files; the default temporary directory if empty
+	dir string
+}
+
+func newRoller(config RollConfig, c codec.Codec, compression string, partition *template.Template) *roller {
+	config.setDefaults()
+	return &roller{
+		config:      config,
+		codec:       c,
+		compression: compression,
+		partition:   partition,
+		files:       make(map[string]*openFile),
+	}
+}
+
+// write adds a record to its partition's file and returns the file if the
+// record made it reach a limit
+func (r *roller) write(record pipeline.Record) (*rolledFile, error) {
+	partition, err := renderPartition(r.partition, record)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := r.files[partition]
+	if !ok {
+		if f, err = r.open(partition); err != nil {
+			return nil, err
+		}
+		r.files[partition] = f
+	}
+
+	if err := f.encoder.Encode(record.Data); err != nil {
+		return nil, fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+	}
+	f.records++
+
+	if f.records >= r.config.MaxRecords || f.counter.n >= r.config.MaxBytes {
+		return r.roll(partition)
+	}
+	return nil, nil
+}
+
+func (r *roller) open(partition string) (*openFile, error) {
+	file, err := os.CreateTemp(r.dir, rollPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create buffer file: %w", err)
+	}
+	counter := &countingWriter{w: file}
+	compressor, err := codec.Compress(counter, r.compression)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	encoder, err := r.codec.NewEncoder(compressor)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &openFile{
+		partition:  partition,
+		file:       file,
+		counter:    counter,
+		compressor: compressor,
+		encoder:    encoder,
+		opened:     time.Now(),
+	}, nil
+}
+
+// roll closes a partition's file
+func (r *roller) roll(partition string) (*rolledFile, error) {
+	f := r.files[partition]
+	delete(r.files, partition)
+
+	err := f.encoder.Close()
+	if closeErr := f.compressor.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.file.Name())
+		return nil, fmt.Errorf("failed to finish file: %w", err)
+	}
+	return &rolledFile{
+		partition: partition,
+		path:      f.file.Name(),
+		records:   f.records,
+		bytes:     f.counter.n,
+	}, nil
+}
+
+// expired rolls the files that have been open for MaxAge
+func (r *roller) expired(now time.Time) ([]*rolledFile, error) {
+	var rolled []*rolledFile
+	for partition, f := range r.files {
+		if now.Sub(f.opened) < r.config.MaxAge {
+			continue
+		}
+		file, err := r.roll(partition)
+		if err != nil {
+			return rolled, err
+		}
+		rolled = append(rolled, file)
+	}
+	return rolled, nil
+}
+
+// rollAll rolls every open file
+func (r *roller) rollAll() ([]*rolledFile, error) {
+	var rolled []*rolledFile
+	for partition := range r.files {
+		file, err := r.roll(partition)
+		if err != nil {
+			return rolled, err
+		}
+		rolled = append(rolled, file)
+	}
+	return rolled, nil
+}
+
+// discard drops every open file
+func (r *roller) discard() {
+	for partition, f := range r.files {
+		f.file.Close()
+		os.Remove(f.file.Name())
+		delete(r.files, partition)
+	}
+}
+
+// fileNamer names rolled files uniquely across runs:
+// part-<run>-<sequence><extension>
+type fileNamer struct {
+	run       string
+	sequence  int
+	extension string
+}
+
+func newFileNamer(c codec.Codec, compression string) *fileNamer {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &fileNamer{
+		run:       time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		extension: codec.Extension(c) + codec.CompressionExtension(compression),
+	}
+}
+
+func (n *fileNamer) next() string {
+	n.sequence++
+	return fmt.Sprintf("part-%s-%05d%s", n.run, n.sequence, n.extension)
+}
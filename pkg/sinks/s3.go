@@ -0,0 +1,35 @@
+package sinks
+
+import (
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+)
+
+// S3SinkConfig configures an S3 sink. Files larger than
+// Connection.PartSize are written with multipart uploads.
+type S3SinkConfig struct {
+	Connection cloud.S3Config
+	ObjectSinkConfig
+}
+
+// S3Sink is an ObjectSink over an S3 bucket
+type S3Sink struct {
+	*ObjectSink
+	connector *cloud.S3Connector
+}
+
+// NewS3Sink creates a new S3 sink
+func NewS3Sink(config S3SinkConfig) (*S3Sink, error) {
+	connector := cloud.NewS3Connector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to s3: %w", err)
+	}
+
+	sink, err := NewObjectSink(connector, config.ObjectSinkConfig)
+	if err != nil {
+		return nil, err
+	}
+	sink.disconnect = connector.Disconnect
+	return &S3Sink{ObjectSink: sink, connector: connector}, nil
+}
@@ -0,0 +1,152 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/database"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// SQLSinkConfig configures a SQL table sink
+type SQLSinkConfig struct {
+	Table string
+	// IDColumn receives Record.ID; leave empty to skip it. It is the
+	// table's key, so a batch with a record without an ID fails.
+	IDColumn string
+	// AutoCreate creates the table from the first batch if it does not exist
+	AutoCreate bool
+	// Policy controls how fields without a matching column are handled
+	Policy database.EvolutionPolicy
+	// Upsert overwrites the row with the same IDColumn, so records written
+	// again after a replay do not fail the batch
+	Upsert    bool
+	BatchSize int
+}
+
+// SQLSink implements pipeline.PushSink for SQL databases, inferring column
+// types from Record.Data
+type SQLSink struct {
+	connector connectors.Connector
+	writer    *database.TableWriter
+	batchSize int
+}
+
+// NewPostgresSink creates a new PostgreSQL sink
+func NewPostgresSink(connConfig database.PostgresConfig, config SQLSinkConfig) (*SQLSink, error) {
+	connector := database.NewPostgresConnector(connConfig)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	writer, err := connector.NewTableWriter(tableWriterConfig(config))
+	if err != nil {
+		connector.Disconnect()
+		return nil, fmt.Errorf("failed to create table writer: %w", err)
+	}
+
+	return newSQLSink(connector, writer, config.BatchSize), nil
+}
+
+// NewMySQLSink creates a new MySQL sink
+func NewMySQLSink(connConfig database.MySQLConfig, config SQLSinkConfig) (*SQLSink, error) {
+	connector := database.NewMySQLConnector(connConfig)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to mysql: %w", err)
+	}
+
+	writer, err := connector.NewTableWriter(tableWriterConfig(config))
+	if err != nil {
+		connector.Disconnect()
+		return nil, fmt.Errorf("failed to create table writer: %w", err)
+	}
+
+	return newSQLSink(connector, writer, config.BatchSize), nil
+}
+
+func tableWriterConfig(config SQLSinkConfig) database.TableWriterConfig {
+	return database.TableWriterConfig{
+		Table:      config.Table,
+		IDColumn:   config.IDColumn,
+		AutoCreate: config.AutoCreate,
+		Policy:     config.Policy,
+		Upsert:     config.Upsert,
+	}
+}
+
+func newSQLSink(connector connectors.Connector, writer *database.TableWriter, batchSize int) *SQLSink {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &SQLSink{
+		connector: connector,
+		writer:    writer,
+		batchSize: batchSize,
+	}
+}
+
+// Write implements pipeline.Sink
+func (s *SQLSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.batchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.batchSize {
+				if err := s.writeBatch(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeBatch(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *SQLSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	size := config.BatchSize
+	if size <= 0 {
+		size = s.batchSize
+	}
+
+	for _, batch := range batchRecords(records, size) {
+		err := withRetry(ctx, config, func() error {
+			return s.writeBatch(ctx, batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLSink) writeBatch(ctx context.Context, batch []pipeline.Record) error {
+	ids := make([]string, len(batch))
+	rows := make([]map[string]interface{}, len(batch))
+	for i, record := range batch {
+		ids[i] = record.ID
+		rows[i] = record.Data
+	}
+
+	if err := s.writer.InsertRows(ctx, ids, rows); err != nil {
+		return fmt.Errorf("failed to write batch: %w", err)
+	}
+	return nil
+}
+
+// Close implements pipeline.Sink
+func (s *SQLSink) Close() error {
+	return s.connector.Disconnect()
+}
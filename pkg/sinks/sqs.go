@@ -0,0 +1,268 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+const (
+	// sqsMaxBatchEntries is the SendMessageBatch entry limit
+	sqsMaxBatchEntries = 10
+	// sqsMaxBatchBytes keeps a batch within the classic 256 KiB payload
+	// limit, which emulators still enforce
+	sqsMaxBatchBytes = 256 * 1024
+)
+
+// SQSSinkConfig configures an SQS sink
+type SQSSinkConfig struct {
+	Connection cloud.SQSConfig
+	// Codec encodes message bodies; defaults to JSON
+	Codec codec.Codec
+	// Delay postpones delivery of every message, up to 15 minutes
+	Delay time.Duration
+	// Attributes lists record metadata keys sent as string message
+	// attributes
+	Attributes []string
+	// BatchSize is the number of entries per SendMessageBatch, at most 10
+	BatchSize int
+	// GroupID extracts the MessageGroupId on FIFO queues; defaults to
+	// Connection.MessageGroupID for every record
+	GroupID KeyExtractor
+	// DeduplicationID extracts the MessageDeduplicationId on FIFO queues;
+	// defaults to the record ID. Returning nil relies on the queue's
+	// content-based deduplication.
+	DeduplicationID KeyExtractor
+}
+
+// SQSSink implements pipeline.PushSink over SendMessageBatch. Entries the
+// service reports as failed are retried on their own, in their original
+// order. On FIFO queues retried entries keep their deduplication IDs, so a
+// retry within the five-minute deduplication window never duplicates a
+// message.
+type SQSSink struct {
+	connector *cloud.SQSConnector
+	config    SQSSinkConfig
+	fifo      bool
+}
+
+// NewSQSSink creates a new SQS sink
+func NewSQSSink(config SQSSinkConfig) (*SQSSink, error) {
+	if config.Codec == nil {
+		config.Codec = codec.NewJSON()
+	}
+	if config.BatchSize <= 0 || config.BatchSize > sqsMaxBatchEntries {
+		config.BatchSize = sqsMaxBatchEntries
+	}
+	if config.Delay > 15*time.Minute {
+		return nil, fmt.Errorf("delay must be at most 15 minutes")
+	}
+
+	connector := cloud.NewSQSConnector(config.Connection)
+	fifo := connector.IsFIFO()
+	if fifo {
+		if config.Delay > 0 {
+			return nil, fmt.Errorf("FIFO queues do not support per-message delays")
+		}
+		if config.GroupID == nil {
+			if config.Connection.MessageGroupID == "" {
+				return nil, fmt.Errorf("FIFO queues require GroupID or Connection.MessageGroupID")
+			}
+			group := []byte(config.Connection.MessageGroupID)
+			config.GroupID = func(pipeline.Record) []byte { return group }
+		}
+		if config.DeduplicationID == nil {
+			config.DeduplicationID = KeyFromID
+		}
+	}
+
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to sqs: %w", err)
+	}
+
+	return &SQSSink{
+		connector: connector,
+		config:    config,
+		fifo:      fifo,
+	}, nil
+}
+
+// Write implements pipeline.Sink
+func (s *SQSSink) Write(ctx context.Context, in <-chan pipeline.Record) error {
+	batch := make([]pipeline.Record, 0, s.config.BatchSize)
+
+	for record := range in {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			batch = append(batch, record)
+
+			if len(batch) >= s.config.BatchSize {
+				if err := s.writeRecords(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+
+	// Write remaining records
+	if len(batch) > 0 {
+		return s.writeRecords(ctx, batch)
+	}
+
+	return nil
+}
+
+// Push implements pipeline.PushSink
+func (s *SQSSink) Push(ctx context.Context, records []pipeline.Record, config pipeline.PushConfig) error {
+	entries, err := s.entries(records)
+	if err != nil {
+		return err
+	}
+
+	for _, batch := range s.batches(entries) {
+		pending := batch
+		err := withRetry(ctx, config, func() error {
+			failed, err := s.sendBatch(ctx, pending)
+			if failed != nil {
+				pending = failed
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQSSink) writeRecords(ctx context.Context, records []pipeline.Record) error {
+	entries, err := s.entries(records)
+	if err != nil {
+		return err
+	}
+	for _, batch := range s.batches(entries) {
+		if _, err := s.sendBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendBatch sends one SendMessageBatch request. It returns the entries
+// that failed, and an error describing them, when the call partly failed.
+func (s *SQSSink) sendBatch(ctx context.Context, entries []*sqs.SendMessageBatchRequestEntry) ([]*sqs.SendMessageBatchRequestEntry, error) {
+	result, err := s.connector.SendEntries(ctx, entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message batch: %w", err)
+	}
+	if len(result.Failed) == 0 {
+		return nil, nil
+	}
+
+	// Keep the failed entries in request order so that a retry preserves
+	// the order of messages within a FIFO group
+	failedIDs := make(map[string]bool, len(result.Failed))
+	messages := make([]string, 0, len(result.Failed))
+	for _, f := range result.Failed {
+		failedIDs[aws.StringValue(f.Id)] = true
+		messages = append(messages, fmt.Sprintf("%s: %s", aws.StringValue(f.Code), aws.StringValue(f.Message)))
+	}
+	failed := make([]*sqs.SendMessageBatchRequestEntry, 0, len(result.Failed))
+	for _, entry := range entries {
+		if failedIDs[aws.StringValue(entry.Id)] {
+			failed = append(failed, entry)
+		}
+	}
+	return failed, fmt.Errorf("%d of %d messages failed: %s", len(result.Failed), len(entries), strings.Join(messages, "; "))
+}
+
+// entries builds one batch entry per record. Entry IDs only need to be
+// unique within a request, so the record's position is used.
+func (s *SQSSink) entries(records []pipeline.Record) ([]*sqs.SendMessageBatchRequestEntry, error) {
+	entries := make([]*sqs.SendMessageBatchRequestEntry, len(records))
+	for i, record := range records {
+		body, err := s.config.Codec.Encode(record.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+		}
+
+		entry := &sqs.SendMessageBatchRequestEntry{
+			Id:          aws.String(strconv.Itoa(i)),
+			MessageBody: aws.String(string(body)),
+		}
+		if s.config.Delay > 0 {
+			entry.DelaySeconds = aws.Int64(int64(s.config.Delay / time.Second))
+		}
+		if s.fifo {
+			group := s.config.GroupID(record)
+			if len(group) == 0 {
+				return nil, fmt.Errorf("record %s has no message group ID", record.ID)
+			}
+			entry.MessageGroupId = aws.String(string(group))
+			if dedup := s.config.DeduplicationID(record); len(dedup) > 0 {
+				entry.MessageDeduplicationId = aws.String(string(dedup))
+			}
+		}
+		for _, key := range s.config.Attributes {
+			value, ok := record.Metadata[key]
+			if !ok || value == "" {
+				continue
+			}
+			if entry.MessageAttributes == nil {
+				entry.MessageAttributes = make(map[string]*sqs.MessageAttributeValue)
+			}
+			entry.MessageAttributes[key] = &sqs.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(value),
+			}
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// batches splits entries into requests within the entry and payload limits
+func (s *SQSSink) batches(entries []*sqs.SendMessageBatchRequestEntry) [][]*sqs.SendMessageBatchRequestEntry {
+	var batches [][]*sqs.SendMessageBatchRequestEntry
+	var batch []*sqs.SendMessageBatchRequestEntry
+	size := 0
+	for _, entry := range entries {
+		n := entrySize(entry)
+		if len(batch) > 0 && (len(batch) >= s.config.BatchSize || size+n > sqsMaxBatchBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, entry)
+		size += n
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// entrySize is the payload size SQS counts for an entry: the body plus the
+// names, types and values of its attributes
+func entrySize(entry *sqs.SendMessageBatchRequestEntry) int {
+	n := len(aws.StringValue(entry.MessageBody))
+	for k, v := range entry.MessageAttributes {
+		n += len(k) + len(aws.StringValue(v.DataType)) + len(aws.StringValue(v.StringValue))
+	}
+	return n
+}
+
+// Close implements pipeline.Sink
+func (s *SQSSink) Close() error {
+	return s.connector.Disconnect()
+}
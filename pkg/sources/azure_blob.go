@@ -0,0 +1,41 @@
+package sources
+
+import (
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+)
+
+// AzureBlobSourceConfig configures an Azure Blob Storage source
+type AzureBlobSourceConfig struct {
+	Connection cloud.AzureBlobConfig
+	ObjectSourceConfig
+}
+
+// AzureBlobSource is an ObjectSource over an Azure Blob Storage container.
+// Record IDs are the blob URL followed by "#line". Block, append and page
+// blobs are all read.
+type AzureBlobSource struct {
+	*ObjectSource
+	connector *cloud.AzureBlobConnector
+}
+
+// NewAzureBlobSource creates a new Azure Blob Storage source
+func NewAzureBlobSource(config AzureBlobSourceConfig) (*AzureBlobSource, error) {
+	connector := cloud.NewAzureBlobConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to azure blob storage: %w", err)
+	}
+
+	source, err := NewObjectSource(connector, connector.ContainerURL(), config.ObjectSourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureBlobSource{ObjectSource: source, connector: connector}, nil
+}
+
+// Close implements pipeline.Source
+func (s *AzureBlobSource) Close() error {
+	s.ObjectSource.Close()
+	return s.connector.Disconnect()
+}
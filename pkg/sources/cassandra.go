@@ -0,0 +1,376 @@
+package sources
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocql/gocql"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/database"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Metadata keys set on records produced by CassandraSource
+const (
+	CassandraMetaTable     = "cassandra.table"
+	CassandraMetaRange     = "cassandra.range"
+	CassandraMetaPageState = "cassandra.page_state"
+	CassandraMetaRangeDone = "cassandra.range_done"
+)
+
+// CassandraSourceConfig configures a Cassandra table scan
+type CassandraSourceConfig struct {
+	Connection database.CassandraConfig
+	Table      string
+	// Columns to select; all columns when empty
+	Columns []string
+	// Splits is the number of token ranges the ring is divided into
+	Splits int
+	// Parallelism is the number of ranges scanned concurrently
+	Parallelism int
+	PageSize    int
+	// Consistency overrides the connection consistency for scans
+	Consistency string
+	// Checkpoints stores the paging state of every range; optional
+	Checkpoints   pipeline.CheckpointStore
+	CheckpointKey string
+}
+
+// cassandraRangeState is the checkpointed progress of one token range
+type cassandraRangeState struct {
+	PageState []byte `json:"page_state,omitempty"`
+	Done      bool   `json:"done,omitempty"`
+}
+
+// CassandraSource implements pipeline.PullSource with parallel token-range
+// scans of a Cassandra table. A page that fails stops the scan of its
+// range, which stays unfinished and resumes from its last committed page;
+// the error is reported by Err.
+type CassandraSource struct {
+	connector    *database.CassandraConnector
+	config       CassandraSourceConfig
+	ranges       []database.TokenRange
+	partitionKey []string
+	consistency  *gocql.Consistency
+
+	mu       sync.Mutex
+	progress map[int]cassandraRangeState
+	err      error
+}
+
+// NewCassandraSource creates a new Cassandra source
+func NewCassandraSource(config CassandraSourceConfig) (*CassandraSource, error) {
+	if config.Table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	if config.Splits <= 0 {
+		config.Splits = 16
+	}
+	if config.Parallelism <= 0 {
+		config.Parallelism = 4
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = 1000
+	}
+	if config.Checkpoints != nil && config.CheckpointKey == "" {
+		config.CheckpointKey = fmt.Sprintf("cassandra/%s/%s", config.Connection.Keyspace, config.Table)
+	}
+
+	var consistency *gocql.Consistency
+	if config.Consistency != "" {
+		c, err := gocql.ParseConsistencyWrapper(config.Consistency)
+		if err != nil {
+			return nil, err
+		}
+		consistency = &c
+	}
+
+	connector := database.NewCassandraConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to cassandra: %w", err)
+	}
+
+	partitionKey, err := connector.PartitionKey(config.Table)
+	if err != nil {
+		connector.Disconnect()
+		return nil, fmt.Errorf("failed to read table metadata: %w", err)
+	}
+
+	return &CassandraSource{
+		connector:    connector,
+		config:       config,
+		ranges:       database.SplitTokenRing(config.Splits),
+		partitionKey: partitionKey,
+		consistency:  consistency,
+	}, nil
+}
+
+// Read implements pipeline.Source. The channel is closed once every token
+// range has been scanned.
+func (s *CassandraSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	return s.scan(ctx, 0)
+}
+
+// Pull implements pipeline.PullSource. Each range contributes at most one
+// page per pull, resuming from the last committed paging state.
+func (s *CassandraSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	return s.scan(ctx, 1)
+}
+
+// scan reads every unfinished range; maxPages limits the pages read per
+// range, zero meaning no limit
+func (s *CassandraSource) scan(ctx context.Context, maxPages int) (<-chan pipeline.Record, error) {
+	if err := s.loadProgress(ctx); err != nil {
+		return nil, err
+	}
+
+	out := make(chan pipeline.Record)
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				s.scanRange(ctx, idx, maxPages, out)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		defer wg.Wait()
+		defer close(work)
+
+		for idx := range s.ranges {
+			s.mu.Lock()
+			done := s.progress[idx].Done
+			s.mu.Unlock()
+			if done {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case work <- idx:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *CassandraSource) scanRange(ctx context.Context, idx int, maxPages int, out chan<- pipeline.Record) {
+	quoted := make([]string, len(s.partitionKey))
+	for i, col := range s.partitionKey {
+		quoted[i] = `"` + col + `"`
+	}
+	tokenExpr := fmt.Sprintf("token(%s)", strings.Join(quoted, ", "))
+	columns := "*"
+	if len(s.config.Columns) > 0 {
+		columns = strings.Join(s.config.Columns, ", ")
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s > ? AND %s <= ?", columns, s.config.Table, tokenExpr, tokenExpr)
+
+	s.mu.Lock()
+	state := s.progress[idx].PageState
+	s.mu.Unlock()
+
+	tokenRange := s.ranges[idx]
+	for pages := 0; maxPages <= 0 || pages < maxPages; pages++ {
+		query := s.connector.Session().Query(stmt, tokenRange.Start, tokenRange.End).
+			WithContext(ctx).
+			PageSize(s.config.PageSize).
+			PageState(state)
+		if s.consistency != nil {
+			query = query.Consistency(*s.consistency)
+		}
+
+		iter := query.Iter()
+		rows := make([]map[string]interface{}, 0, iter.NumRows())
+		for i := 0; i < iter.NumRows(); i++ {
+			row := make(map[string]interface{})
+			if !iter.MapScan(row) {
+				break
+			}
+			rows = append(rows, row)
+		}
+		next := iter.PageState()
+		if err := iter.Close(); err != nil {
+			if ctx.Err() == nil {
+				s.setErr(fmt.Errorf("failed to scan token range %d: %w", idx, err))
+			}
+			return
+		}
+
+		for i, row := range rows {
+			meta := map[string]string{
+				CassandraMetaTable:     s.config.Table,
+				CassandraMetaRange:     strconv.Itoa(idx),
+				CassandraMetaPageState: base64.StdEncoding.EncodeToString(state),
+			}
+			// The last row of a page carries the position after the page
+			if i == len(rows)-1 {
+				meta[CassandraMetaPageState] = base64.StdEncoding.EncodeToString(next)
+				if len(next) == 0 {
+					meta[CassandraMetaRangeDone] = "true"
+				}
+			}
+
+			record := pipeline.Record{
+				ID:       s.recordID(row),
+				Data:     normalizeCassandraRow(row),
+				Metadata: meta,
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+
+		if len(next) == 0 {
+			if len(rows) == 0 {
+				// An empty range produces no record to commit, so mark it here
+				s.mu.Lock()
+				s.progress[idx] = cassandraRangeState{Done: true}
+				s.mu.Unlock()
+			}
+			return
+		}
+		state = next
+	}
+}
+
+// recordID joins the partition key values of a row
+func (s *CassandraSource) recordID(row map[string]interface{}) string {
+	parts := make([]string, len(s.partitionKey))
+	for i, col := range s.partitionKey {
+		parts[i] = fmt.Sprint(normalizeCassandraValue(row[col]))
+	}
+	return strings.Join(parts, ":")
+}
+
+func (s *CassandraSource) loadProgress(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.progress != nil {
+		return nil
+	}
+	s.progress = make(map[int]cassandraRangeState)
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+
+	checkpoint, err := s.config.Checkpoints.Load(ctx, s.config.CheckpointKey)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil
+	}
+
+	var saved map[string]cassandraRangeState
+	if err := json.Unmarshal(checkpoint, &saved); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	for key, state := range saved {
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || idx >= len(s.ranges) {
+			return fmt.Errorf("checkpoint does not match %d splits", len(s.ranges))
+		}
+		s.progress[idx] = state
+	}
+	return nil
+}
+
+// Commit implements pipeline.Committer by recording the paging state of the
+// last committed record of every range
+func (s *CassandraSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.progress == nil {
+		s.progress = make(map[int]cassandraRangeState)
+	}
+	for _, record := range records {
+		rangeIdx, ok := record.Metadata[CassandraMetaRange]
+		if !ok {
+			continue
+		}
+		idx, err := strconv.Atoi(rangeIdx)
+		if err != nil {
+			continue
+		}
+		state, err := base64.StdEncoding.DecodeString(record.Metadata[CassandraMetaPageState])
+		if err != nil {
+			return fmt.Errorf("invalid page state: %w", err)
+		}
+		s.progress[idx] = cassandraRangeState{
+			PageState: state,
+			Done:      record.Metadata[CassandraMetaRangeDone] == "true",
+		}
+	}
+
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+	saved := make(map[string]cassandraRangeState, len(s.progress))
+	for idx, state := range s.progress {
+		saved[strconv.Itoa(idx)] = state
+	}
+	checkpoint, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, checkpoint)
+}
+
+func (s *CassandraSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error that stopped the scan of a token range
+func (s *CassandraSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close implements pipeline.Source
+func (s *CassandraSource) Close() error {
+	return s.connector.Disconnect()
+}
+
+func normalizeCassandraRow(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		out[k] = normalizeCassandraValue(v)
+	}
+	return out
+}
+
+func normalizeCassandraValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case gocql.UUID:
+		return val.String()
+	case []gocql.UUID:
+		out := make([]interface{}, len(val))
+		for i, id := range val {
+			out[i] = id.String()
+		}
+		return out
+	default:
+		return val
+	}
+}
@@ -0,0 +1,388 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// DynamoDBMode selects how a DynamoDBSource reads the table
+type DynamoDBMode string
+
+const (
+	// DynamoDBScan reads the whole table, optionally in parallel segments
+	DynamoDBScan DynamoDBMode = "scan"
+	// DynamoDBQuery reads the items matching KeyCondition
+	DynamoDBQuery DynamoDBMode = "query"
+)
+
+// Metadata keys set on records produced by DynamoDBSource
+const (
+	DynamoDBMetaTable       = "dynamodb.table"
+	DynamoDBMetaSegment     = "dynamodb.segment"
+	DynamoDBMetaLastKey     = "dynamodb.last_evaluated_key"
+	DynamoDBMetaSegmentDone = "dynamodb.segment_done"
+)
+
+// DynamoDBSourceConfig configures a DynamoDB table source
+type DynamoDBSourceConfig struct {
+	Connection cloud.DynamoDBConfig
+	Mode       DynamoDBMode
+	IndexName  string
+	// KeyCondition is the KeyConditionExpression used in query mode
+	KeyCondition              string
+	FilterExpression          string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]interface{}
+	ConsistentRead            bool
+	// Segments is the number of parallel scan segments
+	Segments int
+	// PageSize limits the items evaluated per request
+	PageSize int64
+	// Checkpoints stores the LastEvaluatedKey of every segment; optional
+	Checkpoints   pipeline.CheckpointStore
+	CheckpointKey string
+}
+
+// dynamoSegmentState is the checkpointed progress of one scan segment
+type dynamoSegmentState struct {
+	LastKey map[string]*dynamodb.AttributeValue `json:"last_key,omitempty"`
+	Done    bool                                `json:"done,omitempty"`
+}
+
+// DynamoDBSource implements pipeline.PullSource with paginated scans and
+// queries. A failed request stops its segment, which resumes from its last
+// committed page; the error is reported by Err, as are items that cannot
+// be converted and are skipped.
+type DynamoDBSource struct {
+	connector *cloud.DynamoDBConnector
+	config    DynamoDBSourceConfig
+	values    map[string]*dynamodb.AttributeValue
+	keys      []string
+
+	mu       sync.Mutex
+	progress map[int]dynamoSegmentState
+	err      error
+}
+
+// NewDynamoDBSource creates a new DynamoDB source
+func NewDynamoDBSource(config DynamoDBSourceConfig) (*DynamoDBSource, error) {
+	if config.Mode == "" {
+		config.Mode = DynamoDBScan
+	}
+	if config.Mode == DynamoDBQuery {
+		if config.KeyCondition == "" {
+			return nil, fmt.Errorf("query mode requires a key condition")
+		}
+		// Queries cannot be split into segments
+		config.Segments = 1
+	}
+	if config.Segments <= 0 {
+		config.Segments = 1
+	}
+	if config.Checkpoints != nil && config.CheckpointKey == "" {
+		config.CheckpointKey = fmt.Sprintf("dynamodb/%s/%s", config.Connection.TableName, config.Mode)
+	}
+
+	values, err := dynamodbattribute.MarshalMap(config.ExpressionAttributeValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal expression values: %w", err)
+	}
+	if len(values) == 0 {
+		values = nil
+	}
+
+	connector := cloud.NewDynamoDBConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to dynamodb: %w", err)
+	}
+
+	return &DynamoDBSource{
+		connector: connector,
+		config:    config,
+		values:    values,
+		keys:      connector.KeyAttributes(),
+	}, nil
+}
+
+// Read implements pipeline.Source. The channel is closed once every segment
+// has been read.
+func (s *DynamoDBSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	return s.read(ctx, 0)
+}
+
+// Pull implements pipeline.PullSource. Each segment contributes at most one
+// page per pull, resuming from the last committed LastEvaluatedKey.
+func (s *DynamoDBSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	return s.read(ctx, 1)
+}
+
+func (s *DynamoDBSource) read(ctx context.Context, maxPages int) (<-chan pipeline.Record, error) {
+	if err := s.loadProgress(ctx); err != nil {
+		return nil, err
+	}
+
+	out := make(chan pipeline.Record)
+
+	var wg sync.WaitGroup
+	for segment := 0; segment < s.config.Segments; segment++ {
+		s.mu.Lock()
+		done := s.progress[segment].Done
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+			s.readSegment(ctx, segment, maxPages, out)
+		}(segment)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+func (s *DynamoDBSource) readSegment(ctx context.Context, segment int, maxPages int, out chan<- pipeline.Record) {
+	s.mu.Lock()
+	startKey := s.progress[segment].LastKey
+	s.mu.Unlock()
+
+	for pages := 0; maxPages <= 0 || pages < maxPages; pages++ {
+		items, lastKey, err := s.page(ctx, segment, startKey)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.setErr(fmt.Errorf("failed to read segment %d: %w", segment, err))
+			}
+			return
+		}
+
+		var encodedKey string
+		if lastKey != nil {
+			b, err := json.Marshal(lastKey)
+			if err != nil {
+				s.setErr(fmt.Errorf("failed to encode the position of segment %d: %w", segment, err))
+				return
+			}
+			encodedKey = string(b)
+		}
+
+		records := make([]pipeline.Record, 0, len(items))
+		for _, item := range items {
+			record, err := s.itemRecord(item)
+			if err != nil {
+				s.setErr(fmt.Errorf("skipped an item of segment %d: %w", segment, err))
+				continue
+			}
+			record.Metadata[DynamoDBMetaSegment] = strconv.Itoa(segment)
+			records = append(records, record)
+		}
+
+		if len(records) == 0 {
+			// Filtered pages yield no record to commit, so track them here
+			s.mu.Lock()
+			s.progress[segment] = dynamoSegmentState{LastKey: lastKey, Done: lastKey == nil}
+			s.mu.Unlock()
+		} else {
+			// The last record of a page carries the position after the page
+			last := records[len(records)-1].Metadata
+			last[DynamoDBMetaLastKey] = encodedKey
+			if lastKey == nil {
+				last[DynamoDBMetaSegmentDone] = "true"
+			}
+		}
+
+		for _, record := range records {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+
+		if lastKey == nil {
+			return
+		}
+		startKey = lastKey
+	}
+}
+
+func (s *DynamoDBSource) page(ctx context.Context, segment int, startKey map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	var names map[string]*string
+	if len(s.config.ExpressionAttributeNames) > 0 {
+		names = aws.StringMap(s.config.ExpressionAttributeNames)
+	}
+
+	if s.config.Mode == DynamoDBQuery {
+		input := &dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String(s.config.KeyCondition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: s.values,
+			ConsistentRead:            aws.Bool(s.config.ConsistentRead),
+		}
+		if s.config.IndexName != "" {
+			input.IndexName = aws.String(s.config.IndexName)
+		}
+		if s.config.FilterExpression != "" {
+			input.FilterExpression = aws.String(s.config.FilterExpression)
+		}
+		if s.config.PageSize > 0 {
+			input.Limit = aws.Int64(s.config.PageSize)
+		}
+		return s.connector.QueryPage(ctx, input, startKey)
+	}
+
+	input := &dynamodb.ScanInput{
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: s.values,
+		ConsistentRead:            aws.Bool(s.config.ConsistentRead),
+	}
+	if s.config.Segments > 1 {
+		input.Segment = aws.Int64(int64(segment))
+		input.TotalSegments = aws.Int64(int64(s.config.Segments))
+	}
+	if s.config.IndexName != "" {
+		input.IndexName = aws.String(s.config.IndexName)
+	}
+	if s.config.FilterExpression != "" {
+		input.FilterExpression = aws.String(s.config.FilterExpression)
+	}
+	if s.config.PageSize > 0 {
+		input.Limit = aws.Int64(s.config.PageSize)
+	}
+	return s.connector.ScanPage(ctx, input, startKey)
+}
+
+func (s *DynamoDBSource) itemRecord(item map[string]*dynamodb.AttributeValue) (pipeline.Record, error) {
+	var data map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(item, &data); err != nil {
+		return pipeline.Record{}, err
+	}
+
+	return pipeline.Record{
+		ID:   dynamoItemID(s.keys, data),
+		Data: data,
+		Metadata: map[string]string{
+			DynamoDBMetaTable: s.config.Connection.TableName,
+		},
+	}, nil
+}
+
+// dynamoItemID joins the key attribute values of an item
+func dynamoItemID(keys []string, data map[string]interface{}) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if v, ok := data[key]; ok {
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+func (s *DynamoDBSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error met while reading the table
+func (s *DynamoDBSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *DynamoDBSource) loadProgress(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.progress != nil {
+		return nil
+	}
+	s.progress = make(map[int]dynamoSegmentState)
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+
+	checkpoint, err := s.config.Checkpoints.Load(ctx, s.config.CheckpointKey)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil
+	}
+
+	var saved map[string]dynamoSegmentState
+	if err := json.Unmarshal(checkpoint, &saved); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	for key, state := range saved {
+		segment, err := strconv.Atoi(key)
+		if err != nil || segment < 0 || segment >= s.config.Segments {
+			return fmt.Errorf("checkpoint does not match %d segments", s.config.Segments)
+		}
+		s.progress[segment] = state
+	}
+	return nil
+}
+
+// Commit implements pipeline.Committer by recording the LastEvaluatedKey of
+// the last committed page of every segment
+func (s *DynamoDBSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.progress == nil {
+		s.progress = make(map[int]dynamoSegmentState)
+	}
+	for _, record := range records {
+		encoded, ok := record.Metadata[DynamoDBMetaLastKey]
+		if !ok {
+			continue
+		}
+		segment, err := strconv.Atoi(record.Metadata[DynamoDBMetaSegment])
+		if err != nil {
+			continue
+		}
+
+		state := dynamoSegmentState{Done: record.Metadata[DynamoDBMetaSegmentDone] == "true"}
+		if encoded != "" {
+			if err := json.Unmarshal([]byte(encoded), &state.LastKey); err != nil {
+				return fmt.Errorf("invalid last evaluated key: %w", err)
+			}
+		}
+		s.progress[segment] = state
+	}
+
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+	saved := make(map[string]dynamoSegmentState, len(s.progress))
+	for segment, state := range s.progress {
+		saved[strconv.Itoa(segment)] = state
+	}
+	checkpoint, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, checkpoint)
+}
+
+// Close implements pipeline.Source
+func (s *DynamoDBSource) Close() error {
+	return s.connector.Disconnect()
+}
@@ -0,0 +1,574 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Metadata keys set on records produced by DynamoDBStreamsSource
+const (
+	DynamoDBMetaEventName      = "dynamodb.event_name"
+	DynamoDBMetaEventID        = "dynamodb.event_id"
+	DynamoDBMetaShardID        = "dynamodb.shard_id"
+	DynamoDBMetaSequenceNumber = "dynamodb.sequence_number"
+)
+
+// DynamoDBStreamsConfig configures a DynamoDB Streams source
+type DynamoDBStreamsConfig struct {
+	Connection cloud.DynamoDBConfig
+	// StreamARN defaults to the table's latest stream
+	StreamARN string
+	// StartPosition is TRIM_HORIZON (default) or LATEST for shards without
+	// a checkpoint. A LATEST shard keeps its iterator between pulls until a
+	// change is committed; iterators expire after 15 minutes, so pull more
+	// often than that.
+	StartPosition string
+	// Envelope wraps each change as {event_name, keys, old_image, new_image};
+	// otherwise Data is the new image, or the old image for REMOVE events
+	Envelope bool
+	// BatchSize is the GetRecords limit per call
+	BatchSize int64
+	// PollInterval is the wait between rounds that returned no records
+	PollInterval time.Duration
+	// RefreshInterval controls how often new shards are discovered
+	RefreshInterval time.Duration
+	// Checkpoints stores the last sequence number of every shard; optional
+	Checkpoints   pipeline.CheckpointStore
+	CheckpointKey string
+}
+
+// streamShardState is the checkpointed progress of one stream shard. Shard
+// iterators expire after 15 minutes, so the sequence number is stored and
+// turned back into an AFTER_SEQUENCE_NUMBER iterator on resume.
+type streamShardState struct {
+	SequenceNumber string `json:"sequence_number,omitempty"`
+	Done           bool   `json:"done,omitempty"`
+}
+
+// DynamoDBStreamsSource implements pipeline.PullSource over DynamoDB Streams.
+// Parent shards are always drained before their children so that changes to
+// an item are emitted in order.
+//
+// Pulls share their shard iterators, so shards without a checkpoint that
+// start at LATEST do not miss the changes made between pulls. Rejected
+// changes are read again from the first one of each shard.
+//
+// Errors are reported by Err. A change that cannot be converted stops its
+// shard for the rest of the read, or until the next pull, so that it is
+// retried rather than skipped. A checkpoint the stream has already trimmed
+// resumes the shard at TRIM_HORIZON, losing the changes in between.
+type DynamoDBStreamsSource struct {
+	connector *cloud.DynamoDBConnector
+	config    DynamoDBStreamsConfig
+
+	// pullMu serializes pulls; puller holds their shard iterators
+	pullMu sync.Mutex
+	puller *shardReader
+
+	mu        sync.Mutex
+	committed map[string]streamShardState
+	// closed records the last sequence number emitted from shards whose
+	// end was reached; they are done once that number is committed
+	closed map[string]string
+	// resume holds the sequence number each shard is read again from,
+	// after a Reject or a change that could not be converted
+	resume map[string]string
+	err    error
+}
+
+// NewDynamoDBStreamsSource creates a new DynamoDB Streams source
+func NewDynamoDBStreamsSource(config DynamoDBStreamsConfig) (*DynamoDBStreamsSource, error) {
+	if config.StartPosition == "" {
+		config.StartPosition = dynamodbstreams.ShardIteratorTypeTrimHorizon
+	}
+	if config.StartPosition != dynamodbstreams.ShardIteratorTypeTrimHorizon &&
+		config.StartPosition != dynamodbstreams.ShardIteratorTypeLatest {
+		return nil, fmt.Errorf("unsupported start position: %s", config.StartPosition)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Minute
+	}
+
+	connector := cloud.NewDynamoDBConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to dynamodb: %w", err)
+	}
+
+	if config.StreamARN == "" {
+		arn, err := connector.StreamARN()
+		if err != nil {
+			connector.Disconnect()
+			return nil, err
+		}
+		config.StreamARN = arn
+	}
+	if config.Checkpoints != nil && config.CheckpointKey == "" {
+		config.CheckpointKey = "dynamodb-streams/" + config.StreamARN
+	}
+
+	return &DynamoDBStreamsSource{
+		connector: connector,
+		config:    config,
+		closed:    make(map[string]string),
+		resume:    make(map[string]string),
+	}, nil
+}
+
+// shardReader holds the live shard iterators of one read
+type shardReader struct {
+	shards    []*dynamodbstreams.Shard
+	iterators map[string]*string
+	finished  map[string]bool
+	// failed holds shards stopped at a change that could not be converted
+	failed    map[string]bool
+	refreshed time.Time
+}
+
+// Read implements pipeline.Source, following the stream until ctx is
+// cancelled
+func (s *DynamoDBStreamsSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	reader, err := s.newReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		for {
+			s.refreshIfDue(ctx, reader)
+
+			emitted, err := s.round(ctx, reader, out)
+			if err != nil {
+				return
+			}
+			if emitted == 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(s.config.PollInterval):
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. It performs a single GetRecords call
+// on every readable shard, continuing where the last pull stopped.
+func (s *DynamoDBStreamsSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	s.pullMu.Lock()
+	reader := s.puller
+	if reader == nil {
+		var err error
+		if reader, err = s.newReader(ctx); err != nil {
+			s.pullMu.Unlock()
+			return nil, err
+		}
+		s.puller = reader
+	} else {
+		s.refreshIfDue(ctx, reader)
+		// Shards stopped at a change are retried once per pull
+		reader.failed = make(map[string]bool)
+	}
+
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer s.pullMu.Unlock()
+		defer close(out)
+		s.round(ctx, reader, out)
+	}()
+
+	return out, nil
+}
+
+func (s *DynamoDBStreamsSource) newReader(ctx context.Context) (*shardReader, error) {
+	if err := s.loadCheckpoints(ctx); err != nil {
+		return nil, err
+	}
+
+	reader := &shardReader{
+		iterators: make(map[string]*string),
+		finished:  make(map[string]bool),
+		failed:    make(map[string]bool),
+	}
+	if err := s.refreshShards(ctx, reader); err != nil {
+		return nil, fmt.Errorf("failed to describe stream: %w", err)
+	}
+	return reader, nil
+}
+
+// refreshIfDue discovers new shards once RefreshInterval has passed
+func (s *DynamoDBStreamsSource) refreshIfDue(ctx context.Context, reader *shardReader) {
+	if time.Since(reader.refreshed) < s.config.RefreshInterval {
+		return
+	}
+	if err := s.refreshShards(ctx, reader); err != nil {
+		s.setErr(fmt.Errorf("failed to describe stream: %w", err))
+	}
+}
+
+func (s *DynamoDBStreamsSource) refreshShards(ctx context.Context, reader *shardReader) error {
+	shards, err := s.connector.StreamShards(ctx, s.config.StreamARN)
+	if err != nil {
+		return err
+	}
+	reader.shards = shards
+	reader.refreshed = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, shard := range shards {
+		id := aws.StringValue(shard.ShardId)
+		if s.committed[id].Done {
+			reader.finished[id] = true
+		}
+	}
+	return nil
+}
+
+// ready reports whether a shard may be read: its parent, if still retained
+// by the stream, must have been read to the end
+func (r *shardReader) ready(shard *dynamodbstreams.Shard) bool {
+	id := aws.StringValue(shard.ShardId)
+	if r.finished[id] || r.failed[id] {
+		return false
+	}
+
+	parent := aws.StringValue(shard.ParentShardId)
+	if parent == "" || r.finished[parent] {
+		return true
+	}
+	for _, other := range r.shards {
+		if aws.StringValue(other.ShardId) == parent {
+			return false
+		}
+	}
+	// The parent has been trimmed from the stream
+	return true
+}
+
+// round reads one batch from every ready shard and returns the number of
+// records emitted. It only fails when ctx is done; shard errors are
+// recorded with setErr and the shard is retried next round.
+func (s *DynamoDBStreamsSource) round(ctx context.Context, reader *shardReader, out chan<- pipeline.Record) (int, error) {
+	emitted := 0
+	for _, shard := range reader.shards {
+		if !reader.ready(shard) {
+			continue
+		}
+		id := aws.StringValue(shard.ShardId)
+
+		iterator := reader.iterators[id]
+		if iterator == nil {
+			var err error
+			iterator, err = s.shardIterator(ctx, id)
+			if err != nil {
+				if ctx.Err() != nil {
+					return emitted, ctx.Err()
+				}
+				s.setErr(err)
+				continue
+			}
+		}
+
+		result, err := s.connector.Streams().GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: iterator,
+			Limit:         aws.Int64(s.config.BatchSize),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return emitted, ctx.Err()
+			}
+			// Expired iterators are recreated from the checkpoint next round
+			s.setErr(fmt.Errorf("failed to read shard %s: %w", id, err))
+			delete(reader.iterators, id)
+			continue
+		}
+
+		var last string
+		for _, change := range result.Records {
+			record, err := s.changeRecord(id, change)
+			if err != nil {
+				s.setErr(fmt.Errorf("failed to convert change %s of shard %s: %w",
+					aws.StringValue(change.EventID), id, err))
+				reader.failed[id] = true
+				if change.Dynamodb != nil && change.Dynamodb.SequenceNumber != nil {
+					s.mu.Lock()
+					s.resume[id] = aws.StringValue(change.Dynamodb.SequenceNumber)
+					s.mu.Unlock()
+				}
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return emitted, ctx.Err()
+			case out <- record:
+				emitted++
+				last = record.Metadata[DynamoDBMetaSequenceNumber]
+			}
+		}
+
+		if reader.failed[id] {
+			delete(reader.iterators, id)
+			continue
+		}
+		reader.iterators[id] = result.NextShardIterator
+		if result.NextShardIterator == nil {
+			// The shard is closed and fully read; its children may start
+			reader.finished[id] = true
+			s.markClosed(id, last)
+		}
+	}
+	return emitted, nil
+}
+
+func (s *DynamoDBStreamsSource) shardIterator(ctx context.Context, shardID string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(s.config.StreamARN),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(s.config.StartPosition),
+	}
+
+	s.mu.Lock()
+	seq := s.committed[shardID].SequenceNumber
+	resume := s.resume[shardID]
+	s.mu.Unlock()
+	switch {
+	case resume != "":
+		seq = resume
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAtSequenceNumber)
+		input.SequenceNumber = aws.String(seq)
+	case seq != "":
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(seq)
+	}
+
+	result, err := s.connector.Streams().GetShardIteratorWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && seq != "" &&
+		aerr.Code() == dynamodbstreams.ErrCodeTrimmedDataAccessException {
+		// The checkpoint is older than the stream's 24 hour retention
+		s.setErr(fmt.Errorf("checkpoint %s of shard %s has been trimmed from the stream, resuming at %s: %w",
+			seq, shardID, dynamodbstreams.ShardIteratorTypeTrimHorizon, err))
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+		input.SequenceNumber = nil
+		result, err = s.connector.Streams().GetShardIteratorWithContext(ctx, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get iterator for shard %s: %w", shardID, err)
+	}
+	return result.ShardIterator, nil
+}
+
+func (s *DynamoDBStreamsSource) changeRecord(shardID string, change *dynamodbstreams.Record) (pipeline.Record, error) {
+	stream := change.Dynamodb
+	if stream == nil {
+		return pipeline.Record{}, fmt.Errorf("change has no stream record")
+	}
+
+	keys, err := unmarshalImage(stream.Keys)
+	if err != nil {
+		return pipeline.Record{}, err
+	}
+	oldImage, err := unmarshalImage(stream.OldImage)
+	if err != nil {
+		return pipeline.Record{}, err
+	}
+	newImage, err := unmarshalImage(stream.NewImage)
+	if err != nil {
+		return pipeline.Record{}, err
+	}
+
+	eventName := aws.StringValue(change.EventName)
+	var data map[string]interface{}
+	if s.config.Envelope {
+		data = map[string]interface{}{
+			"event_name": eventName,
+			"keys":       keys,
+		}
+		if oldImage != nil {
+			data["old_image"] = oldImage
+		}
+		if newImage != nil {
+			data["new_image"] = newImage
+		}
+	} else {
+		switch {
+		case newImage != nil:
+			data = newImage
+		case oldImage != nil:
+			data = oldImage
+		default:
+			data = keys
+		}
+	}
+
+	var timestamp int64
+	if stream.ApproximateCreationDateTime != nil {
+		timestamp = stream.ApproximateCreationDateTime.Unix()
+	}
+
+	return pipeline.Record{
+		ID:   dynamoItemID(s.connector.KeyAttributes(), keys),
+		Data: data,
+		Metadata: map[string]string{
+			DynamoDBMetaTable:          s.config.Connection.TableName,
+			DynamoDBMetaEventName:      eventName,
+			DynamoDBMetaEventID:        aws.StringValue(change.EventID),
+			DynamoDBMetaShardID:        shardID,
+			DynamoDBMetaSequenceNumber: aws.StringValue(stream.SequenceNumber),
+		},
+		Timestamp: timestamp,
+	}, nil
+}
+
+func unmarshalImage(image map[string]*dynamodb.AttributeValue) (map[string]interface{}, error) {
+	if image == nil {
+		return nil, nil
+	}
+	var data map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(image, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// markClosed records that a shard has been read to its end
+func (s *DynamoDBStreamsSource) markClosed(shardID string, lastSequence string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lastSequence == "" {
+		lastSequence = s.committed[shardID].SequenceNumber
+	}
+	s.closed[shardID] = lastSequence
+	if lastSequence == s.committed[shardID].SequenceNumber {
+		state := s.committed[shardID]
+		state.Done = true
+		s.committed[shardID] = state
+	}
+}
+
+func (s *DynamoDBStreamsSource) loadCheckpoints(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.committed != nil {
+		return nil
+	}
+	s.committed = make(map[string]streamShardState)
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+
+	checkpoint, err := s.config.Checkpoints.Load(ctx, s.config.CheckpointKey)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil
+	}
+	if err := json.Unmarshal(checkpoint, &s.committed); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Commit implements pipeline.Committer by recording the last committed
+// sequence number of every shard
+func (s *DynamoDBStreamsSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.committed == nil {
+		s.committed = make(map[string]streamShardState)
+	}
+	for _, record := range records {
+		shardID := record.Metadata[DynamoDBMetaShardID]
+		seq := record.Metadata[DynamoDBMetaSequenceNumber]
+		if shardID == "" || seq == "" {
+			continue
+		}
+		state := streamShardState{SequenceNumber: seq}
+		if last, ok := s.closed[shardID]; ok && last == seq {
+			state.Done = true
+		}
+		s.committed[shardID] = state
+		// The checkpoint now leads to any change still to be read again
+		delete(s.resume, shardID)
+	}
+
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+	checkpoint, err := json.Marshal(s.committed)
+	if err != nil {
+		return err
+	}
+	return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, checkpoint)
+}
+
+// Reject implements pipeline.Rejecter. The next pull reads every shard
+// with rejected records again from the first of them.
+func (s *DynamoDBStreamsSource) Reject(ctx context.Context, records []pipeline.Record, cause error) error {
+	s.pullMu.Lock()
+	defer s.pullMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rewound := make(map[string]bool)
+	for _, record := range records {
+		shardID := record.Metadata[DynamoDBMetaShardID]
+		seq := record.Metadata[DynamoDBMetaSequenceNumber]
+		if shardID == "" || seq == "" || rewound[shardID] {
+			continue
+		}
+		// Records of a shard arrive in order, so the first is the earliest
+		rewound[shardID] = true
+		s.resume[shardID] = seq
+		if s.puller != nil {
+			delete(s.puller.iterators, shardID)
+			delete(s.puller.finished, shardID)
+		}
+	}
+	return nil
+}
+
+func (s *DynamoDBStreamsSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error met while reading the stream
+func (s *DynamoDBStreamsSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close implements pipeline.Source
+func (s *DynamoDBStreamsSource) Close() error {
+	return s.connector.Disconnect()
+}
@@ -0,0 +1,560 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/filesystem"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Record metadata keys set by FileSource
+const (
+	FileMetaPath = "file.path"
+	// FileMetaInode identifies the file across renames
+	FileMetaInode = "file.inode"
+	// FileMetaOffset is the byte offset just past the record's line
+	FileMetaOffset = "file.offset"
+)
+
+// FileSourceConfig configures a tailing file source
+type FileSourceConfig struct {
+	Connection filesystem.LocalFSConfig
+	// Pattern selects files relative to BasePath with filepath.Match
+	// syntax, e.g. "logs/*.log"
+	Pattern string
+	// Codec decodes every line; by default lines are kept as raw bytes in
+	// the "line" field
+	Codec codec.Codec
+	// FromEnd starts files that have no checkpoint at their end, like
+	// tail -F, instead of their beginning. It only applies to the files
+	// found by the first scan: files created later are read in full.
+	FromEnd bool
+	// PollInterval is how often files are checked for new data, rotation
+	// and truncation, and the pattern matched again; defaults to 250ms
+	PollInterval time.Duration
+	// MaxLineSize bounds a line; longer lines are skipped without being
+	// buffered. Defaults to 1 MiB.
+	MaxLineSize int
+	// OnSkip is called for every line skipped because it is too long or
+	// fails to decode; Err only keeps the last such error
+	OnSkip func(err error)
+	// Checkpoints stores the committed offset of every file; optional
+	Checkpoints pipeline.CheckpointStore
+	// CheckpointKey defaults to "file/" + BasePath + "/" + Pattern
+	CheckpointKey string
+}
+
+// fileState is the checkpointed progress of one file, keyed by its ID
+type fileState struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+// FileSource implements pipeline.PullSource by tailing the files matching
+// a pattern. Files are followed by inode, as tail -F does: a file renamed
+// away by log rotation is read to its end and dropped once its last line
+// is committed, the file that replaces it is read from the start, and a
+// file truncated in place is read again from the start. A line is only
+// emitted once its newline has been written, except for the last line of
+// a file rotated away.
+//
+// Read tails the files until its context is cancelled. Pull returns the
+// lines available at the time of the call. Commit records the offset of
+// every file by inode, so a restart neither skips nor repeats committed
+// lines, even if the files were rotated in between.
+type FileSource struct {
+	connector *filesystem.LocalFSConnector
+	config    FileSourceConfig
+
+	// readMu serializes readers; files and scanned belong to the reader
+	readMu  sync.Mutex
+	files   map[string]*tailedFile
+	scanned bool
+
+	mu        sync.Mutex
+	committed map[string]fileState
+	// retired holds the IDs of files rotated away whose last line was
+	// committed, so their offsets are no longer worth keeping
+	retired map[string]bool
+	err     error
+}
+
+// tailedFile is an open file being followed
+type tailedFile struct {
+	id     string
+	key    string
+	path   string
+	file   *os.File
+	reader *bufio.Reader
+	// offset is where reader stands in the file, start where reading
+	// began and last the offset of the last record emitted
+	offset int64
+	start  int64
+	last   int64
+	// partial holds a line whose newline has not been written yet; once
+	// it is longer than MaxLineSize only its length is kept in skipped
+	partial []byte
+	skipped int64
+	// gone marks a file that no longer matches the pattern; it is read to
+	// its end and closed once its last line is committed
+	gone bool
+}
+
+// NewFileSource creates a new tailing file source
+func NewFileSource(config FileSourceConfig) (*FileSource, error) {
+	if config.Pattern == "" {
+		return nil, fmt.Errorf("file pattern is required")
+	}
+	if _, err := filepath.Match(config.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid file pattern: %w", err)
+	}
+	if config.Codec == nil {
+		config.Codec = codec.NewRaw("line")
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 250 * time.Millisecond
+	}
+	if config.MaxLineSize <= 0 {
+		config.MaxLineSize = 1024 * 1024
+	}
+	if config.Checkpoints != nil && config.CheckpointKey == "" {
+		config.CheckpointKey = "file/" + config.Connection.BasePath + "/" + config.Pattern
+	}
+
+	connector := filesystem.NewLocalFSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", config.Connection.BasePath, err)
+	}
+
+	return &FileSource{
+		connector: connector,
+		config:    config,
+		files:     make(map[string]*tailedFile),
+		retired:   make(map[string]bool),
+	}, nil
+}
+
+// Read implements pipeline.Source
+func (s *FileSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	if err := s.loadCheckpoints(ctx); err != nil {
+		return nil, err
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+		s.readMu.Lock()
+		defer s.readMu.Unlock()
+
+		for {
+			emitted := s.poll(ctx, 0, func(record pipeline.Record) bool {
+				select {
+				case <-ctx.Done():
+					return false
+				case out <- record:
+					return true
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if emitted == 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(s.config.PollInterval):
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. It returns up to BatchSize of the
+// lines available now without waiting for more.
+func (s *FileSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	if err := s.loadCheckpoints(ctx); err != nil {
+		return nil, err
+	}
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+		s.readMu.Lock()
+		defer s.readMu.Unlock()
+
+		s.poll(ctx, batchSize, func(record pipeline.Record) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- record:
+				return true
+			}
+		})
+	}()
+
+	return out, nil
+}
+
+// poll matches the pattern again, then emits the complete lines of every
+// file, up to max records unless max is 0. It returns the number of
+// records emitted.
+func (s *FileSource) poll(ctx context.Context, max int, emit func(pipeline.Record) bool) int {
+	if err := s.scan(); err != nil {
+		s.setErr(fmt.Errorf("failed to match %s: %w", s.config.Pattern, err))
+	}
+
+	files := make([]*tailedFile, 0, len(s.files))
+	for _, f := range s.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].key < files[j].key })
+
+	emitted := 0
+	for _, f := range files {
+		drained := false
+		for max == 0 || emitted < max {
+			if ctx.Err() != nil {
+				return emitted
+			}
+			record, ok, err := s.next(f)
+			if err != nil {
+				s.setErr(fmt.Errorf("failed to read %s: %w", f.key, err))
+				break
+			}
+			if !ok {
+				drained = true
+				break
+			}
+			if !emit(record) {
+				return emitted
+			}
+			emitted++
+		}
+		if f.gone && drained {
+			s.retire(f)
+		}
+	}
+	return emitted
+}
+
+// retire closes a file rotated away and read to its end once its last
+// line has been committed. Until then the file stays open, so Reject can
+// still rewind it.
+func (s *FileSource) retire(f *tailedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	committed := f.start
+	if state, ok := s.committed[f.id]; ok {
+		committed = state.Offset
+	}
+	if committed < f.last {
+		return
+	}
+	f.file.Close()
+	delete(s.files, f.id)
+	s.retired[f.id] = true
+	delete(s.committed, f.id)
+}
+
+// scan opens the files that newly match the pattern, notices truncated
+// files and marks the files that stopped matching as gone
+func (s *FileSource) scan() error {
+	keys, err := s.connector.Glob(s.config.Pattern)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		path, err := s.connector.FullPath(key)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			// Removed since the glob
+			continue
+		}
+		id := fileID(key, info)
+		seen[id] = true
+
+		if f, ok := s.files[id]; ok {
+			f.key, f.path = key, path
+			f.gone = false
+			if info.Size() < f.offset {
+				// Truncated in place: the committed offset no longer
+				// points into the same content
+				if err := f.seek(0); err != nil {
+					return err
+				}
+				f.start, f.last = 0, 0
+				s.mu.Lock()
+				delete(s.committed, id)
+				s.mu.Unlock()
+			}
+			continue
+		}
+
+		f, err := s.open(key, path, id, info)
+		if err != nil {
+			s.setErr(fmt.Errorf("failed to open %s: %w", key, err))
+			continue
+		}
+		s.files[id] = f
+	}
+
+	for id, f := range s.files {
+		if !seen[id] {
+			f.gone = true
+		}
+	}
+	s.scanned = true
+	return nil
+}
+
+func (s *FileSource) open(key, path, id string, info os.FileInfo) (*tailedFile, error) {
+	file, err := s.connector.OpenFile(key)
+	if err != nil {
+		return nil, err
+	}
+	f := &tailedFile{id: id, key: key, path: path, file: file}
+
+	var start int64
+	s.mu.Lock()
+	state, ok := s.committed[id]
+	// The inode of a retired file may have been reused
+	delete(s.retired, id)
+	s.mu.Unlock()
+	switch {
+	case ok && state.Offset <= info.Size():
+		start = state.Offset
+	case !ok && s.config.FromEnd && !s.scanned:
+		start = info.Size()
+	}
+
+	if err := f.seek(start); err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.start, f.last = start, start
+	return f, nil
+}
+
+// seek moves to offset, dropping any buffered data
+func (f *tailedFile) seek(offset int64) error {
+	if _, err := f.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	f.reader = bufio.NewReader(f.file)
+	f.offset = offset
+	f.partial, f.skipped = nil, 0
+	return nil
+}
+
+// next returns the record of the next complete line, if any
+func (s *FileSource) next(f *tailedFile) (pipeline.Record, bool, error) {
+	for {
+		chunk, err := f.reader.ReadSlice('\n')
+		f.offset += int64(len(chunk))
+		switch {
+		case err == bufio.ErrBufferFull:
+			s.buffer(f, chunk)
+			continue
+		case err == io.EOF:
+			s.buffer(f, chunk)
+			if !f.gone || (len(f.partial) == 0 && f.skipped == 0) {
+				return pipeline.Record{}, false, nil
+			}
+			// The file was rotated away, so its last line is complete
+			chunk = nil
+		case err != nil:
+			return pipeline.Record{}, false, err
+		}
+		if len(f.partial) > 0 {
+			chunk = append(f.partial, chunk...)
+		}
+		size := f.skipped + int64(len(chunk))
+		f.partial, f.skipped = nil, 0
+
+		line := bytes.TrimRight(chunk, "\r\n")
+		size -= int64(len(chunk) - len(line))
+		if size == 0 {
+			continue
+		}
+		if size > int64(s.config.MaxLineSize) {
+			s.skip(f, fmt.Errorf("line of %d bytes is longer than %d", size, s.config.MaxLineSize))
+			continue
+		}
+		data, err := s.config.Codec.Decode(line)
+		if err != nil {
+			s.skip(f, err)
+			continue
+		}
+		f.last = f.offset
+		return s.record(f, data), true, nil
+	}
+}
+
+// buffer keeps part of a line whose newline has not been read yet. Once
+// the line is longer than MaxLineSize, allowing for a carriage return, it
+// is only counted, to be skipped.
+func (s *FileSource) buffer(f *tailedFile, chunk []byte) {
+	if f.skipped == 0 && len(f.partial)+len(chunk) <= s.config.MaxLineSize+1 {
+		f.partial = append(f.partial, chunk...)
+		return
+	}
+	f.skipped += int64(len(f.partial) + len(chunk))
+	f.partial = nil
+}
+
+// skip reports a line that is not emitted
+func (s *FileSource) skip(f *tailedFile, err error) {
+	err = fmt.Errorf("skipped line ending at offset %d of %s: %w", f.offset, f.key, err)
+	s.setErr(err)
+	if s.config.OnSkip != nil {
+		s.config.OnSkip(err)
+	}
+}
+
+func (s *FileSource) record(f *tailedFile, data map[string]interface{}) pipeline.Record {
+	offset := strconv.FormatInt(f.offset, 10)
+	return pipeline.Record{
+		ID:   fmt.Sprintf("file://%s#%s", filepath.ToSlash(f.path), offset),
+		Data: data,
+		Metadata: map[string]string{
+			FileMetaPath:   f.path,
+			FileMetaInode:  f.id,
+			FileMetaOffset: offset,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+func (s *FileSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error that made the source skip a line or a file
+func (s *FileSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *FileSource) loadCheckpoints(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.committed != nil {
+		return nil
+	}
+	s.committed = make(map[string]fileState)
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+
+	checkpoint, err := s.config.Checkpoints.Load(ctx, s.config.CheckpointKey)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil
+	}
+	if err := json.Unmarshal(checkpoint, &s.committed); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Commit implements pipeline.Committer by recording the offset past the
+// last committed line of every file. Files rotated away are dropped from
+// the checkpoint once their last line is committed.
+func (s *FileSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.committed == nil {
+		s.committed = make(map[string]fileState)
+	}
+	for _, record := range records {
+		id, ok := record.Metadata[FileMetaInode]
+		if !ok || s.retired[id] {
+			continue
+		}
+		offset, err := strconv.ParseInt(record.Metadata[FileMetaOffset], 10, 64)
+		if err != nil {
+			continue
+		}
+		if state, ok := s.committed[id]; ok && state.Offset >= offset {
+			continue
+		}
+		s.committed[id] = fileState{Path: record.Metadata[FileMetaPath], Offset: offset}
+	}
+
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+	checkpoint, err := json.Marshal(s.committed)
+	if err != nil {
+		return err
+	}
+	return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, checkpoint)
+}
+
+// Reject implements pipeline.Rejecter by rewinding the files of the
+// records to their last committed line, so the next pull reads them again
+func (s *FileSource) Reject(ctx context.Context, records []pipeline.Record, cause error) error {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		f, ok := s.files[record.Metadata[FileMetaInode]]
+		if !ok {
+			continue
+		}
+		offset := f.start
+		if state, ok := s.committed[f.id]; ok {
+			offset = state.Offset
+		}
+		if offset < f.offset {
+			if err := f.seek(offset); err != nil {
+				return fmt.Errorf("failed to rewind %s: %w", f.key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements pipeline.Source
+func (s *FileSource) Close() error {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	for id, f := range s.files {
+		f.file.Close()
+		delete(s.files, id)
+	}
+	return s.connector.Disconnect()
+}
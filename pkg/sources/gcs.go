@@ -0,0 +1,41 @@
+package sources
+
+import (
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+)
+
+// GCSSourceConfig configures a Google Cloud Storage object source
+type GCSSourceConfig struct {
+	Connection cloud.GCSConfig
+	ObjectSourceConfig
+}
+
+// GCSSource is an ObjectSource over a GCS bucket. Record IDs are
+// "gs://bucket/key#line".
+type GCSSource struct {
+	*ObjectSource
+	connector *cloud.GCSConnector
+}
+
+// NewGCSSource creates a new GCS source
+func NewGCSSource(config GCSSourceConfig) (*GCSSource, error) {
+	connector := cloud.NewGCSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to gcs: %w", err)
+	}
+
+	source, err := NewObjectSource(connector, "gs://"+config.Connection.Bucket, config.ObjectSourceConfig)
+	if err != nil {
+		connector.Disconnect()
+		return nil, err
+	}
+	return &GCSSource{ObjectSource: source, connector: connector}, nil
+}
+
+// Close implements pipeline.Source
+func (s *GCSSource) Close() error {
+	s.ObjectSource.Close()
+	return s.connector.Disconnect()
+}
@@ -0,0 +1,50 @@
+package sources
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/filesystem"
+)
+
+// HDFSSourceConfig configures an HDFS source. Keys are paths relative to
+// Connection.BasePath; Pattern may use "**" to match files at any depth.
+type HDFSSourceConfig struct {
+	Connection filesystem.HDFSConfig
+	ObjectSourceConfig
+}
+
+// HDFSSource is an ObjectSource over a directory tree in HDFS. Files are
+// streamed rather than loaded whole. Record IDs are
+// "hdfs://namenode/base/key#line".
+type HDFSSource struct {
+	*ObjectSource
+	connector *filesystem.HDFSConnector
+}
+
+// NewHDFSSource creates a new HDFS source
+func NewHDFSSource(config HDFSSourceConfig) (*HDFSSource, error) {
+	connector := filesystem.NewHDFSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to hdfs: %w", err)
+	}
+
+	namenode := ""
+	if len(config.Connection.NameNodeAddrs) > 0 {
+		namenode = config.Connection.NameNodeAddrs[0]
+	}
+	location := "hdfs://" + namenode + strings.TrimSuffix(path.Clean("/"+config.Connection.BasePath), "/")
+	source, err := NewObjectSource(connector, location, config.ObjectSourceConfig)
+	if err != nil {
+		connector.Disconnect()
+		return nil, err
+	}
+	return &HDFSSource{ObjectSource: source, connector: connector}, nil
+}
+
+// Close implements pipeline.Source
+func (s *HDFSSource) Close() error {
+	s.ObjectSource.Close()
+	return s.connector.Disconnect()
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package sources
+
+import "os"
+
+// fileID identifies a file by its key where files have no inode number, so
+// renamed files are read again
+func fileID(key string, info os.FileInfo) string {
+	return key
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package sources
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileID identifies a file across renames by its inode number
+func fileID(key string, info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return strconv.FormatUint(uint64(stat.Ino), 10)
+	}
+	return key
+}
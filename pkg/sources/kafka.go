@@ -2,32 +2,415 @@ package sources
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
 	"github.com/ivikasavnish/datapipe/pkg/pipeline"
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Start offsets for KafkaSourceConfig.StartOffset
+const (
+	KafkaOffsetEarliest  = "earliest"
+	KafkaOffsetLatest    = "latest"
+	KafkaOffsetTimestamp = "timestamp"
 )
 
+// KafkaSASLConfig configures SASL authentication
+type KafkaSASLConfig struct {
+	// Mechanism is "PLAIN", "SCRAM-SHA-256" or "SCRAM-SHA-512"
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// KafkaTLSConfig configures TLS for broker connections
+type KafkaTLSConfig struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// KafkaSourceConfig configures a Kafka source
+type KafkaSourceConfig struct {
+	Brokers []string
+	// Topics to consume; more than one topic requires a GroupID
+	Topics []string
+	// TopicPattern subscribes to every topic matching the regular
+	// expression. Matching topics are resolved when the source is created.
+	TopicPattern string
+	GroupID      string
+	// Partition is read when no GroupID is set
+	Partition int
+	// StartOffset is "earliest", "latest" (default) or "timestamp". For
+	// consumer groups it only applies to partitions without committed offsets.
+	StartOffset string
+	StartTime   time.Time
+	// IsolationLevel is "read_uncommitted" (default) or "read_committed"
+	IsolationLevel string
+	SASL           *KafkaSASLConfig
+	TLS            *KafkaTLSConfig
+	MinBytes       int
+	MaxBytes       int
+	MaxWait        time.Duration
+	// Codec decodes message values; defaults to JSON, or to Avro when a
+	// SchemaRegistry is set. Messages that fail to decode are skipped and
+	// reported by Err.
+	Codec codec.Codec
+	// SchemaRegistry resolves the writer schemas of Confluent wire format
+	// values
+	SchemaRegistry *messaging.SchemaRegistryConfig
+	// CommitInterval batches the offset commits made by Commit; zero
+	// commits synchronously
+	CommitInterval time.Duration
+	// CommitTimeout bounds Commit, which still commits when the context of
+	// the pipeline run is cancelled after the sink accepted the records;
+	// defaults to 30 seconds
+	CommitTimeout time.Duration
+}
+
 // KafkaSource implements pipeline.Source for Kafka
 type KafkaSource struct {
 	reader *kafka.Reader
+	config KafkaSourceConfig
+
+	mu  sync.Mutex
+	err error
 }
 
 // NewKafkaSource creates a new Kafka source
 func NewKafkaSource(brokers []string, topic string, groupID string) (*KafkaSource, error) {
-	reader := kafka.NewReader(kafka.ReaderConfig{
+	return NewKafkaSourceWithConfig(KafkaSourceConfig{
 		Brokers:  brokers,
-		Topic:    topic,
+		Topics:   []string{topic},
 		GroupID:  groupID,
 		MinBytes: 10e3, // 10KB
 		MaxBytes: 10e6, // 10MB
 	})
+}
+
+// NewKafkaSourceWithConfig creates a new Kafka source from a full configuration
+func NewKafkaSourceWithConfig(config KafkaSourceConfig) (*KafkaSource, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("at least one broker is required")
+	}
+	if config.StartOffset == "" {
+		config.StartOffset = KafkaOffsetLatest
+	}
+	if config.SchemaRegistry != nil {
+		if err := useRegistryCodec(&config); err != nil {
+			return nil, err
+		}
+	}
+	if config.Codec == nil {
+		config.Codec = codec.NewJSON()
+	}
+	if config.CommitTimeout <= 0 {
+		config.CommitTimeout = 30 * time.Second
+	}
+
+	dialer, transport, err := kafkaDialer(config)
+	if err != nil {
+		return nil, err
+	}
+	client := &kafka.Client{
+		Addr:      kafka.TCP(config.Brokers...),
+		Transport: transport,
+	}
+
+	ctx := context.Background()
+	topics, err := resolveTopics(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) > 1 && config.GroupID == "" {
+		return nil, fmt.Errorf("consuming %d topics requires a consumer group", len(topics))
+	}
+
+	readerConfig := kafka.ReaderConfig{
+		Brokers:        config.Brokers,
+		GroupID:        config.GroupID,
+		Dialer:         dialer,
+		MinBytes:       config.MinBytes,
+		MaxBytes:       config.MaxBytes,
+		MaxWait:        config.MaxWait,
+		CommitInterval: config.CommitInterval,
+		StartOffset:    kafka.LastOffset,
+	}
+	if config.StartOffset == KafkaOffsetEarliest {
+		readerConfig.StartOffset = kafka.FirstOffset
+	}
+
+	switch config.IsolationLevel {
+	case "", "read_uncommitted":
+		readerConfig.IsolationLevel = kafka.ReadUncommitted
+	case "read_committed":
+		readerConfig.IsolationLevel = kafka.ReadCommitted
+	default:
+		return nil, fmt.Errorf("unknown isolation level: %s", config.IsolationLevel)
+	}
+
+	if config.GroupID == "" {
+		readerConfig.Topic = topics[0]
+		readerConfig.Partition = config.Partition
+	} else if len(topics) == 1 {
+		readerConfig.Topic = topics[0]
+	} else {
+		readerConfig.GroupTopics = topics
+	}
+
+	switch config.StartOffset {
+	case KafkaOffsetEarliest, KafkaOffsetLatest:
+	case KafkaOffsetTimestamp:
+		if config.StartTime.IsZero() {
+			return nil, fmt.Errorf("timestamp start offset requires a start time")
+		}
+		if config.GroupID != "" {
+			if err := seedGroupOffsets(ctx, client, config.GroupID, topics, config.StartTime); err != nil {
+				return nil, fmt.Errorf("failed to seed group offsets: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown start offset: %s", config.StartOffset)
+	}
+
+	reader := kafka.NewReader(readerConfig)
+	if config.StartOffset == KafkaOffsetTimestamp && config.GroupID == "" {
+		if err := reader.SetOffsetAt(ctx, config.StartTime); err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to seek to start time: %w", err)
+		}
+	}
 
 	return &KafkaSource{
 		reader: reader,
+		config: config,
 	}, nil
 }
 
-// Read implements pipeline.Source
+// useRegistryCodec points the codec at the schema registry, creating an
+// Avro codec when none is configured
+func useRegistryCodec(config *KafkaSourceConfig) error {
+	registry := messaging.NewSchemaRegistryConnector(*config.SchemaRegistry)
+	if err := registry.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to schema registry: %w", err)
+	}
+
+	if config.Codec == nil {
+		avroCodec, err := codec.NewAvro(codec.AvroConfig{
+			ConfluentWireFormat: true,
+			Resolver:            registry.Resolver(),
+		})
+		if err != nil {
+			return err
+		}
+		config.Codec = avroCodec
+		return nil
+	}
+
+	registryCodec, ok := config.Codec.(codec.RegistryCodec)
+	if !ok {
+		return fmt.Errorf("%s codec cannot be used with a schema registry", config.Codec.Name())
+	}
+	registryCodec.UseRegistry(0, registry.Resolver())
+	return nil
+}
+
+// kafkaDialer builds the dialer used by the reader and the transport used
+// for admin requests from the SASL and TLS settings
+func kafkaDialer(config KafkaSourceConfig) (*kafka.Dialer, *kafka.Transport, error) {
+	dialer := &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+	transport := &kafka.Transport{}
+
+	if config.SASL != nil {
+		mechanism, err := saslMechanism(*config.SASL)
+		if err != nil {
+			return nil, nil, err
+		}
+		dialer.SASLMechanism = mechanism
+		transport.SASL = mechanism
+	}
+
+	if config.TLS != nil && config.TLS.Enabled {
+		tlsConfig, err := kafkaTLSConfig(*config.TLS)
+		if err != nil {
+			return nil, nil, err
+		}
+		dialer.TLS = tlsConfig
+		transport.TLS = tlsConfig
+	}
+
+	return dialer, transport, nil
+}
+
+func saslMechanism(config KafkaSASLConfig) (sasl.Mechanism, error) {
+	switch config.Mechanism {
+	case "PLAIN":
+		return plain.Mechanism{Username: config.Username, Password: config.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, config.Username, config.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, config.Username, config.Password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism: %s", config.Mechanism)
+	}
+}
+
+func kafkaTLSConfig(config KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// resolveTopics combines the explicit topics with those matching TopicPattern
+func resolveTopics(ctx context.Context, client *kafka.Client, config KafkaSourceConfig) ([]string, error) {
+	topics := append([]string(nil), config.Topics...)
+
+	if config.TopicPattern != "" {
+		pattern, err := regexp.Compile(config.TopicPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic pattern: %w", err)
+		}
+		metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list topics: %w", err)
+		}
+
+		seen := make(map[string]bool, len(topics))
+		for _, topic := range topics {
+			seen[topic] = true
+		}
+		var matched []string
+		for _, topic := range metadata.Topics {
+			if topic.Internal || seen[topic.Name] || !pattern.MatchString(topic.Name) {
+				continue
+			}
+			matched = append(matched, topic.Name)
+		}
+		sort.Strings(matched)
+		topics = append(topics, matched...)
+	}
+
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics to consume")
+	}
+	return topics, nil
+}
+
+// seedGroupOffsets commits the offsets at startTime for every partition the
+// group has not committed yet, so a new group starts from that point in time
+func seedGroupOffsets(ctx context.Context, client *kafka.Client, groupID string, topics []string, startTime time.Time) error {
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return err
+	}
+
+	partitions := make(map[string][]int)
+	for _, topic := range metadata.Topics {
+		if topic.Error != nil {
+			return topic.Error
+		}
+		for _, p := range topic.Partitions {
+			partitions[topic.Name] = append(partitions[topic.Name], p.ID)
+		}
+	}
+
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  partitions,
+	})
+	if err != nil {
+		return err
+	}
+
+	lookups := make(map[string][]kafka.OffsetRequest)
+	for topic, offsets := range committed.Topics {
+		for _, p := range offsets {
+			if p.CommittedOffset < 0 {
+				lookups[topic] = append(lookups[topic], kafka.TimeOffsetOf(p.Partition, startTime))
+			}
+		}
+	}
+	if len(lookups) == 0 {
+		return nil
+	}
+
+	listed, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: lookups})
+	if err != nil {
+		return err
+	}
+
+	commits := make(map[string][]kafka.OffsetCommit)
+	for topic, offsets := range listed.Topics {
+		for _, p := range offsets {
+			if p.Error != nil {
+				return p.Error
+			}
+			offset := p.LastOffset
+			for o := range p.Offsets {
+				if o >= 0 {
+					offset = o
+				}
+			}
+			commits[topic] = append(commits[topic], kafka.OffsetCommit{
+				Partition: p.Partition,
+				Offset:    offset,
+			})
+		}
+	}
+
+	// Generation -1 commits on behalf of a group with no active members
+	_, err = client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       commits,
+	})
+	return err
+}
+
+// Read implements pipeline.Source. With a consumer group, offsets only
+// advance through Commit, so pipelines read the source with Pull, which
+// Pipeline.Run commits batch by batch. Fetch errors are reported by Err
+// and the read goes on.
 func (s *KafkaSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
 	out := make(chan pipeline.Record)
 
@@ -39,39 +422,183 @@ func (s *KafkaSource) Read(ctx context.Context) (<-chan pipeline.Record, error)
 			case <-ctx.Done():
 				return
 			default:
-				msg, err := s.reader.ReadMessage(ctx)
+				msg, err := s.fetch(ctx)
 				if err != nil {
+					if ctx.Err() == nil {
+						s.setErr(fmt.Errorf("failed to fetch message: %w", err))
+					}
 					continue
 				}
 
-				var data map[string]interface{}
-				if err := json.Unmarshal(msg.Value, &data); err != nil {
+				data, err := s.decode(msg)
+				if err != nil {
 					continue
 				}
 
-				record := pipeline.Record{
-					ID:   string(msg.Key),
-					Data: data,
-					Metadata: map[string]string{
-						"topic":     msg.Topic,
-						"partition": string(msg.Partition),
-						"offset":    string(msg.Offset),
-					},
-					Timestamp: msg.Time.Unix(),
-				}
-
 				select {
 				case <-ctx.Done():
 					return
-				case out <- record:
+				case out <- kafkaRecord(msg, data):
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. It returns up to BatchSize
+// messages, ending early once no message arrived within MaxWait (10
+// seconds by default), so each batch is committed while the pipeline runs.
+// Any other fetch error ends the batch and is reported by Err.
+func (s *KafkaSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	idle := s.config.MaxWait
+	if idle <= 0 {
+		idle = 10 * time.Second
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		for n := 0; n < batchSize; {
+			fetchCtx, cancel := context.WithTimeout(ctx, idle)
+			msg, err := s.fetch(fetchCtx)
+			cancel()
+			if err != nil {
+				// Running out of the idle wait just ends the batch
+				if ctx.Err() == nil && !errors.Is(err, context.DeadlineExceeded) {
+					s.setErr(fmt.Errorf("failed to fetch message: %w", err))
 				}
+				return
 			}
+
+			data, err := s.decode(msg)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- kafkaRecord(msg, data):
+			}
+			n++
 		}
 	}()
 
 	return out, nil
 }
 
+// fetch reads the next message. Group readers fetch without committing so
+// offsets only advance through Commit.
+func (s *KafkaSource) fetch(ctx context.Context) (kafka.Message, error) {
+	if s.config.GroupID != "" {
+		return s.reader.FetchMessage(ctx)
+	}
+	return s.reader.ReadMessage(ctx)
+}
+
+// decode decodes a message value, reporting failures through Err
+func (s *KafkaSource) decode(msg kafka.Message) (map[string]interface{}, error) {
+	data, err := s.config.Codec.Decode(msg.Value)
+	if err != nil {
+		s.setErr(fmt.Errorf("failed to decode message %s/%d@%d: %w", msg.Topic, msg.Partition, msg.Offset, err))
+		return nil, err
+	}
+	return data, nil
+}
+
+// kafkaRecord builds a record carrying the typed Kafka metadata of msg
+func kafkaRecord(msg kafka.Message, data map[string]interface{}) pipeline.Record {
+	meta := messaging.KafkaMetadata{
+		Topic:         msg.Topic,
+		Partition:     msg.Partition,
+		Offset:        msg.Offset,
+		Key:           msg.Key,
+		LeaderEpoch:   -1,
+		HighWaterMark: msg.HighWaterMark,
+		Timestamp:     msg.Time,
+	}
+	for _, header := range msg.Headers {
+		meta.Headers = append(meta.Headers, messaging.KafkaHeader{
+			Key:   header.Key,
+			Value: header.Value,
+		})
+	}
+
+	metadata := meta.Encode()
+	messaging.EncodeLegacyKafkaMetadata(meta, metadata)
+
+	return pipeline.Record{
+		ID:        string(msg.Key),
+		Data:      data,
+		Metadata:  metadata,
+		Timestamp: msg.Time.Unix(),
+	}
+}
+
+// Commit implements pipeline.Committer by committing the highest offset
+// seen per topic partition. It is a no-op without a consumer group. The
+// commit is not abandoned when ctx is cancelled after the sink accepted
+// the records, so that they are not delivered again.
+func (s *KafkaSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	if s.config.GroupID == "" {
+		return nil
+	}
+
+	type topicPartition struct {
+		topic     string
+		partition int
+	}
+	latest := make(map[topicPartition]int64)
+	for _, record := range records {
+		meta, err := messaging.DecodeKafkaMetadata(record.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to read kafka metadata of record %s: %w", record.ID, err)
+		}
+		tp := topicPartition{meta.Topic, meta.Partition}
+		if offset, ok := latest[tp]; !ok || meta.Offset > offset {
+			latest[tp] = meta.Offset
+		}
+	}
+	if len(latest) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, 0, len(latest))
+	for tp, offset := range latest {
+		messages = append(messages, kafka.Message{
+			Topic:     tp.topic,
+			Partition: tp.partition,
+			Offset:    offset,
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.config.CommitTimeout)
+	defer cancel()
+	if err := s.reader.CommitMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	return nil
+}
+
+func (s *KafkaSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last fetch or decode error
+func (s *KafkaSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
 // Close implements pipeline.Source
 func (s *KafkaSource) Close() error {
 	return s.reader.Close()
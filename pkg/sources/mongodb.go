@@ -0,0 +1,320 @@
+package sources
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/database"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDBMode selects how a MongoDBSource reads data
+type MongoDBMode string
+
+const (
+	// MongoDBChangeStream tails a collection or database change stream
+	MongoDBChangeStream MongoDBMode = "change_stream"
+	// MongoDBSnapshot reads every document of a collection in _id order
+	MongoDBSnapshot MongoDBMode = "snapshot"
+)
+
+// Metadata keys set on records produced by MongoDBSource
+const (
+	MongoMetaDatabase    = database.MongoMetaDatabase
+	MongoMetaCollection  = database.MongoMetaCollection
+	MongoMetaOperation   = database.MongoMetaOperation
+	MongoMetaResumeToken = database.MongoMetaResumeToken
+	MongoMetaSnapshotID  = database.MongoMetaSnapshotID
+	MongoMetaClusterTime = database.MongoMetaClusterTime
+)
+
+// MongoDBSourceConfig configures a MongoDB source
+type MongoDBSourceConfig struct {
+	Connection database.MongoDBConfig
+	// Collection to read; change streams watch the whole database when empty
+	Collection string
+	Mode       MongoDBMode
+	// Pipeline is an optional aggregation pipeline for change streams
+	Pipeline mongo.Pipeline
+	// Filter is an optional query filter for snapshots
+	Filter bson.M
+	// Checkpoints stores resume tokens or the last snapshot _id; optional
+	Checkpoints   pipeline.CheckpointStore
+	CheckpointKey string
+}
+
+// MongoDBSource implements pipeline.PullSource over MongoDB change streams
+// and collection snapshots. A document or event that cannot be decoded, or a
+// failing cursor, ends the read: the records before it are still delivered
+// and committed, the error is reported by Err, and the next read resumes at
+// the failed document instead of skipping it.
+type MongoDBSource struct {
+	connector *database.MongoDBConnector
+	config    MongoDBSourceConfig
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewMongoDBSource creates a new MongoDB source
+func NewMongoDBSource(config MongoDBSourceConfig) (*MongoDBSource, error) {
+	if config.Mode == "" {
+		config.Mode = MongoDBChangeStream
+	}
+	if config.Mode == MongoDBSnapshot && config.Collection == "" {
+		return nil, fmt.Errorf("snapshot mode requires a collection")
+	}
+	if config.Checkpoints != nil && config.CheckpointKey == "" {
+		config.CheckpointKey = fmt.Sprintf("mongodb/%s/%s/%s", config.Connection.Database, config.Collection, config.Mode)
+	}
+
+	connector := database.NewMongoDBConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to mongodb: %w", err)
+	}
+
+	return &MongoDBSource{
+		connector: connector,
+		config:    config,
+	}, nil
+}
+
+// Read implements pipeline.Source. Snapshots close the channel once every
+// document has been read; change streams run until ctx is cancelled.
+func (s *MongoDBSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	return s.read(ctx, 0)
+}
+
+// Pull implements pipeline.PullSource, returning at most config.BatchSize
+// records starting from the last committed checkpoint
+func (s *MongoDBSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	return s.read(ctx, config.BatchSize)
+}
+
+func (s *MongoDBSource) read(ctx context.Context, limit int) (<-chan pipeline.Record, error) {
+	checkpoint, err := s.loadCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.Mode == MongoDBSnapshot {
+		return s.readSnapshot(ctx, checkpoint, limit)
+	}
+	return s.readChangeStream(ctx, checkpoint, limit)
+}
+
+func (s *MongoDBSource) readSnapshot(ctx context.Context, checkpoint []byte, limit int) (<-chan pipeline.Record, error) {
+	filter := bson.M{}
+	for k, v := range s.config.Filter {
+		filter[k] = v
+	}
+	if checkpoint != nil {
+		var last bson.M
+		if err := bson.UnmarshalExtJSON(checkpoint, true, &last); err != nil {
+			return nil, fmt.Errorf("invalid snapshot checkpoint: %w", err)
+		}
+		filter["_id"] = bson.M{"$gt": last["_id"]}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := s.connector.Collection(s.config.Collection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collection: %w", err)
+	}
+
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+		defer cursor.Close(context.Background())
+
+		for cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				s.setErr(fmt.Errorf("failed to decode document: %w", err))
+				return
+			}
+
+			position, err := bson.MarshalExtJSON(bson.M{"_id": doc["_id"]}, true, false)
+			if err != nil {
+				s.setErr(fmt.Errorf("failed to encode snapshot position of %v: %w", doc["_id"], err))
+				return
+			}
+
+			record := pipeline.Record{
+				ID:   database.FormatDocumentID(doc["_id"]),
+				Data: database.NormalizeBSON(doc).(map[string]interface{}),
+				Metadata: map[string]string{
+					MongoMetaDatabase:   s.config.Connection.Database,
+					MongoMetaCollection: s.config.Collection,
+					MongoMetaOperation:  "snapshot",
+					MongoMetaSnapshotID: string(position),
+				},
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+		if err := cursor.Err(); err != nil && ctx.Err() == nil {
+			s.setErr(fmt.Errorf("failed to read collection: %w", err))
+		}
+	}()
+
+	return out, nil
+}
+
+// changeEvent is the subset of a change stream event used to build records
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	FullDocument  bson.M `bson:"fullDocument"`
+	DocumentKey   bson.M `bson:"documentKey"`
+	Namespace     struct {
+		Database   string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+}
+
+func (s *MongoDBSource) readChangeStream(ctx context.Context, checkpoint []byte, limit int) (<-chan pipeline.Record, error) {
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if checkpoint != nil {
+		opts.SetResumeAfter(bson.Raw(checkpoint))
+	}
+
+	stream, err := s.connector.Watch(ctx, s.config.Collection, s.config.Pipeline, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change stream: %w", err)
+	}
+
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+		defer stream.Close(context.Background())
+
+		emitted := 0
+		for limit <= 0 || emitted < limit {
+			// A bounded pull blocks for the first event only and then takes
+			// whatever is already available
+			var ok bool
+			if limit > 0 && emitted > 0 {
+				ok = stream.TryNext(ctx)
+			} else {
+				ok = stream.Next(ctx)
+			}
+			if !ok {
+				if err := stream.Err(); err != nil && ctx.Err() == nil {
+					s.setErr(fmt.Errorf("failed to read change stream: %w", err))
+				}
+				return
+			}
+
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				s.setErr(fmt.Errorf("failed to decode change event: %w", err))
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- s.changeRecord(event, stream.ResumeToken()):
+				emitted++
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *MongoDBSource) changeRecord(event changeEvent, token bson.Raw) pipeline.Record {
+	data := event.FullDocument
+	if data == nil {
+		data = event.DocumentKey
+	}
+
+	normalized, _ := database.NormalizeBSON(data).(map[string]interface{})
+	if normalized == nil {
+		normalized = map[string]interface{}{}
+	}
+
+	return pipeline.Record{
+		ID:   database.FormatDocumentID(event.DocumentKey["_id"]),
+		Data: normalized,
+		Metadata: map[string]string{
+			MongoMetaDatabase:    event.Namespace.Database,
+			MongoMetaCollection:  event.Namespace.Collection,
+			MongoMetaOperation:   event.OperationType,
+			MongoMetaResumeToken: base64.StdEncoding.EncodeToString(token),
+			MongoMetaClusterTime: strconv.FormatUint(uint64(event.ClusterTime.T), 10) + "." +
+				strconv.FormatUint(uint64(event.ClusterTime.I), 10),
+		},
+		Timestamp: int64(event.ClusterTime.T),
+	}
+}
+
+func (s *MongoDBSource) loadCheckpoint(ctx context.Context) ([]byte, error) {
+	if s.config.Checkpoints == nil {
+		return nil, nil
+	}
+	checkpoint, err := s.config.Checkpoints.Load(ctx, s.config.CheckpointKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// Commit implements pipeline.Committer by saving the position of the last
+// record read from this source
+func (s *MongoDBSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		meta := records[i].Metadata
+		if token, ok := meta[MongoMetaResumeToken]; ok {
+			raw, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				return fmt.Errorf("invalid resume token: %w", err)
+			}
+			return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, raw)
+		}
+		if position, ok := meta[MongoMetaSnapshotID]; ok {
+			return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, []byte(position))
+		}
+	}
+	return nil
+}
+
+func (s *MongoDBSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error that ended a read early
+func (s *MongoDBSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close implements pipeline.Source
+func (s *MongoDBSource) Close() error {
+	return s.connector.Disconnect()
+}
@@ -0,0 +1,486 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Record metadata keys set by ObjectSource
+const (
+	// ObjectMetaStore is the store's location, e.g. "s3://bucket"
+	ObjectMetaStore = "object.store"
+	ObjectMetaKey   = "object.key"
+	ObjectMetaETag  = "object.etag"
+	// ObjectMetaLine is the line of the record in its object, or its
+	// position for formats that are not line-oriented
+	ObjectMetaLine = "object.line"
+)
+
+// ObjectSourceConfig configures an ObjectSource
+type ObjectSourceConfig struct {
+	Prefix string
+	// Pattern selects keys with connectors.MatchGlob, e.g.
+	// "logs/*/*.jsonl.gz" or "logs/**/*.gz" at any depth; every key under
+	// Prefix is read by default
+	Pattern string
+	// Codec decodes objects; by default it is chosen from each key's
+	// extension with codec.ByExtension, so .jsonl, .csv and .parquet
+	// objects, compressed or not, are read without configuration. Parquet
+	// objects are spooled to a temporary file first, as their footer is at
+	// the end.
+	Codec codec.Codec
+	// Compression is codec.CompressionAuto (default), which detects gzip
+	// and zstd, or a fixed algorithm
+	Compression string
+	// SkipInvalid skips lines that fail to decode in line-oriented formats.
+	// Otherwise a decode error stops reading the object, which is retried
+	// from its last committed line on the next listing.
+	SkipInvalid bool
+	// Checkpoints stores the progress of every object; optional
+	Checkpoints pipeline.CheckpointStore
+	// CheckpointKey defaults to the store location and prefix
+	CheckpointKey string
+}
+
+// objectState is the checkpointed progress of one object. An object whose
+// ETag changed is read again from the start.
+type objectState struct {
+	ETag string `json:"etag"`
+	Line int64  `json:"line,omitempty"`
+	Done bool   `json:"done,omitempty"`
+}
+
+// ObjectSource implements pipeline.PullSource over the objects under a
+// prefix of any connectors.ObjectStore. Read makes a single pass over the
+// objects. Pull resumes where the last pull stopped and lists the prefix
+// again once every known object has been read, so new objects are picked
+// up. Progress is committed per object and line, so a restart neither
+// skips nor repeats committed records.
+type ObjectSource struct {
+	store    connectors.ObjectStore
+	location string
+	config   ObjectSourceConfig
+
+	// readMu serializes readers; queue and current belong to the reader
+	readMu  sync.Mutex
+	queue   []connectors.ObjectInfo
+	current *objectReader
+
+	mu        sync.Mutex
+	committed map[string]objectState
+	// finished holds the last line of objects read to the end whose
+	// records have not all been committed yet
+	finished map[string]objectState
+	err      error
+}
+
+// NewObjectSource creates a source reading from store. location names the
+// store in record IDs and metadata, e.g. "s3://bucket"; record IDs are
+// location + "/" + key + "#" + line.
+func NewObjectSource(store connectors.ObjectStore, location string, config ObjectSourceConfig) (*ObjectSource, error) {
+	if config.Pattern != "" {
+		if _, err := path.Match(config.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern: %w", err)
+		}
+	}
+	if config.Compression == "" {
+		config.Compression = codec.CompressionAuto
+	}
+	if config.Checkpoints != nil && config.CheckpointKey == "" {
+		config.CheckpointKey = location + "/" + config.Prefix
+	}
+
+	return &ObjectSource{
+		store:    store,
+		location: location,
+		config:   config,
+		finished: make(map[string]objectState),
+	}, nil
+}
+
+// Read implements pipeline.Source
+func (s *ObjectSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	if err := s.loadCheckpoints(ctx); err != nil {
+		return nil, err
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+		s.readMu.Lock()
+		defer s.readMu.Unlock()
+
+		listed := false
+		for {
+			record, ok := s.next(ctx, &listed)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. It returns up to BatchSize records,
+// listing the prefix at most once per call.
+func (s *ObjectSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	if err := s.loadCheckpoints(ctx); err != nil {
+		return nil, err
+	}
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+		s.readMu.Lock()
+		defer s.readMu.Unlock()
+
+		listed := len(s.queue) > 0 || s.current != nil
+		for n := 0; n < batchSize; n++ {
+			record, ok := s.next(ctx, &listed)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// next returns the next record, opening queued objects as needed and
+// listing the prefix when the queue is empty unless it was already listed.
+// Failures are kept for Err and skip the object.
+func (s *ObjectSource) next(ctx context.Context, listed *bool) (pipeline.Record, bool) {
+	for ctx.Err() == nil {
+		if s.current == nil {
+			if len(s.queue) == 0 {
+				if *listed {
+					return pipeline.Record{}, false
+				}
+				*listed = true
+				if err := s.list(ctx); err != nil {
+					s.setErr(fmt.Errorf("failed to list %s/%s: %w", s.location, s.config.Prefix, err))
+					return pipeline.Record{}, false
+				}
+				continue
+			}
+
+			obj := s.queue[0]
+			s.queue = s.queue[1:]
+			reader, err := s.open(ctx, obj)
+			if err != nil {
+				s.setErr(fmt.Errorf("failed to open %s/%s: %w", s.location, obj.Key, err))
+				continue
+			}
+			s.current = reader
+		}
+
+		data, err := s.current.next(s.config.SkipInvalid)
+		if err == io.EOF {
+			s.finish(s.current)
+			s.current.close()
+			s.current = nil
+			continue
+		}
+		if err != nil {
+			s.setErr(fmt.Errorf("failed to read %s/%s: %w", s.location, s.current.key, err))
+			s.current.close()
+			s.current = nil
+			continue
+		}
+		return s.record(s.current, data), true
+	}
+	return pipeline.Record{}, false
+}
+
+// list queues the objects under the prefix that still have records to read
+func (s *ObjectSource) list(ctx context.Context) error {
+	prefix := s.config.Prefix
+	if prefix == "" {
+		// Skip the directories the pattern cannot match
+		prefix = connectors.GlobPrefix(s.config.Pattern)
+	}
+	var queue []connectors.ObjectInfo
+	err := s.store.List(ctx, connectors.ListOptions{Prefix: prefix}, func(objects []connectors.ObjectInfo) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, obj := range objects {
+			if s.config.Pattern != "" {
+				if ok, _ := connectors.MatchGlob(s.config.Pattern, obj.Key); !ok {
+					continue
+				}
+			}
+			if state := s.committed[obj.Key]; state.Done && state.ETag == obj.ETag {
+				continue
+			}
+			if state, ok := s.finished[obj.Key]; ok && state.ETag == obj.ETag {
+				continue
+			}
+			queue = append(queue, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.queue = queue
+	return nil
+}
+
+// objectReader decodes one object
+type objectReader struct {
+	key      string
+	etag     string
+	modified int64
+	body     io.ReadCloser
+	stream   io.ReadCloser
+	dec      codec.Decoder
+	// line is the line of the last decoded record; records up to skip
+	// were committed before
+	line int64
+	skip int64
+}
+
+func (s *ObjectSource) open(ctx context.Context, obj connectors.ObjectInfo) (*objectReader, error) {
+	key := obj.Key
+	reader := &objectReader{
+		key:  key,
+		etag: obj.ETag,
+	}
+	if !obj.ModTime.IsZero() {
+		reader.modified = obj.ModTime.Unix()
+	}
+
+	s.mu.Lock()
+	if state := s.committed[key]; state.ETag == reader.etag {
+		reader.skip = state.Line
+	}
+	s.mu.Unlock()
+
+	c := s.config.Codec
+	if c == nil {
+		var err error
+		if c, err = codec.ByExtension(key); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := s.store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := codec.Decompress(body, s.config.Compression)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	dec, err := c.NewDecoder(stream)
+	if err != nil {
+		stream.Close()
+		body.Close()
+		return nil, err
+	}
+
+	reader.body = body
+	reader.stream = stream
+	reader.dec = dec
+	return reader, nil
+}
+
+// next decodes the next record after the committed ones
+func (r *objectReader) next(skipInvalid bool) (map[string]interface{}, error) {
+	lines, lineOriented := r.dec.(codec.LineDecoder)
+	for {
+		data, err := r.dec.Decode()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			// Only line-oriented decoders can resume after a bad record
+			if skipInvalid && lineOriented && int64(lines.Line()) > r.line {
+				r.line = int64(lines.Line())
+				continue
+			}
+			return nil, err
+		}
+
+		if lineOriented {
+			r.line = int64(lines.Line())
+		} else {
+			r.line++
+		}
+		if r.line <= r.skip {
+			continue
+		}
+		return data, nil
+	}
+}
+
+func (r *objectReader) close() {
+	codec.CloseDecoder(r.dec)
+	r.stream.Close()
+	r.body.Close()
+}
+
+func (s *ObjectSource) record(r *objectReader, data map[string]interface{}) pipeline.Record {
+	line := strconv.FormatInt(r.line, 10)
+	return pipeline.Record{
+		ID:   fmt.Sprintf("%s/%s#%s", s.location, r.key, line),
+		Data: data,
+		Metadata: map[string]string{
+			ObjectMetaStore: s.location,
+			ObjectMetaKey:   r.key,
+			ObjectMetaETag:  r.etag,
+			ObjectMetaLine:  line,
+		},
+		Timestamp: r.modified,
+	}
+}
+
+// finish records that an object was read to the end. Objects that yielded
+// no new records are done right away; the others once their last line is
+// committed.
+func (s *ObjectSource) finish(r *objectReader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.line <= r.skip {
+		s.committed[r.key] = objectState{ETag: r.etag, Line: r.line, Done: true}
+		return
+	}
+	s.finished[r.key] = objectState{ETag: r.etag, Line: r.line}
+}
+
+func (s *ObjectSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error that made the source skip an object
+func (s *ObjectSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *ObjectSource) loadCheckpoints(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.committed != nil {
+		return nil
+	}
+	s.committed = make(map[string]objectState)
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+
+	checkpoint, err := s.config.Checkpoints.Load(ctx, s.config.CheckpointKey)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil
+	}
+	if err := json.Unmarshal(checkpoint, &s.committed); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Commit implements pipeline.Committer by recording the last committed line
+// of every object and marking objects done once all their lines are
+func (s *ObjectSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.committed == nil {
+		s.committed = make(map[string]objectState)
+	}
+	for _, record := range records {
+		key, ok := record.Metadata[ObjectMetaKey]
+		if !ok {
+			continue
+		}
+		line, err := strconv.ParseInt(record.Metadata[ObjectMetaLine], 10, 64)
+		if err != nil {
+			continue
+		}
+		etag := record.Metadata[ObjectMetaETag]
+		if state := s.committed[key]; state.ETag == etag && state.Line >= line {
+			continue
+		}
+		s.committed[key] = objectState{ETag: etag, Line: line}
+	}
+	for key, last := range s.finished {
+		if state := s.committed[key]; state.ETag == last.ETag && state.Line >= last.Line {
+			state.Done = true
+			s.committed[key] = state
+			delete(s.finished, key)
+		}
+	}
+
+	if s.config.Checkpoints == nil {
+		return nil
+	}
+	checkpoint, err := json.Marshal(s.committed)
+	if err != nil {
+		return err
+	}
+	return s.config.Checkpoints.Save(ctx, s.config.CheckpointKey, checkpoint)
+}
+
+// Reject implements pipeline.Rejecter by rewinding to the last committed
+// line of every object, so the next pull reads the records again
+func (s *ObjectSource) Reject(ctx context.Context, records []pipeline.Record, cause error) error {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+
+	if s.current != nil {
+		s.current.close()
+		s.current = nil
+	}
+	s.queue = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		delete(s.finished, record.Metadata[ObjectMetaKey])
+	}
+	return nil
+}
+
+// Close implements pipeline.Source. It does not close the store.
+func (s *ObjectSource) Close() error {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	if s.current != nil {
+		s.current.close()
+		s.current = nil
+	}
+	return nil
+}
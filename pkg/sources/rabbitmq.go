@@ -0,0 +1,281 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+	"github.com/streadway/amqp"
+)
+
+// Record metadata keys set by RabbitMQSource
+const (
+	RabbitMQMetaDeliveryTag = "rabbitmq.delivery_tag"
+	RabbitMQMetaExchange    = "rabbitmq.exchange"
+	RabbitMQMetaRoutingKey  = "rabbitmq.routing_key"
+	RabbitMQMetaMessageID   = "rabbitmq.message_id"
+	RabbitMQMetaRedelivered = "rabbitmq.redelivered"
+	// RabbitMQMetaHeaderPrefix prefixes message headers
+	RabbitMQMetaHeaderPrefix = "rabbitmq.header."
+)
+
+// RabbitMQSourceConfig configures a RabbitMQ queue source
+type RabbitMQSourceConfig struct {
+	Connection messaging.RabbitMQConfig
+	// Prefetch bounds the unacknowledged deliveries held by the source, and
+	// so the size of a Pull batch; defaults to 100
+	Prefetch    int
+	ConsumerTag string
+	// Codec decodes message bodies; defaults to JSON. Messages that fail to
+	// decode are rejected without requeueing.
+	Codec codec.Codec
+	// DiscardOnFailure rejects messages without requeueing when the sink
+	// fails, so they go to the queue's dead letter exchange if it has one
+	DiscardOnFailure bool
+	// IdleTimeout ends a Pull batch once no message has arrived for this
+	// long; defaults to one second
+	IdleTimeout time.Duration
+}
+
+// RabbitMQSource implements pipeline.PullSource for a RabbitMQ queue.
+// Deliveries are acknowledged through Commit after the sink has written
+// them and negatively acknowledged through Reject when it fails, so the
+// source must be pulled: the broker stops delivering once Prefetch
+// deliveries are unacknowledged, and Pipeline.Run only commits batches
+// read with Pull.
+type RabbitMQSource struct {
+	connector  *messaging.RabbitMQConnector
+	config     RabbitMQSourceConfig
+	consumeMu  sync.Mutex
+	deliveries <-chan amqp.Delivery
+}
+
+// NewRabbitMQSource creates a new RabbitMQ source
+func NewRabbitMQSource(config RabbitMQSourceConfig) (*RabbitMQSource, error) {
+	if config.Connection.Queue == "" {
+		return nil, fmt.Errorf("queue is required")
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = 100
+	}
+	if config.Codec == nil {
+		config.Codec = codec.NewJSON()
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = time.Second
+	}
+
+	connector := messaging.NewRabbitMQConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to rabbitmq: %w", err)
+	}
+
+	return &RabbitMQSource{
+		connector: connector,
+		config:    config,
+	}, nil
+}
+
+// consume starts the consumer on first use. Read and Pull share it so that
+// deliveries are acknowledged on the channel they arrived on.
+func (s *RabbitMQSource) consume() (<-chan amqp.Delivery, error) {
+	s.consumeMu.Lock()
+	defer s.consumeMu.Unlock()
+
+	if s.deliveries == nil {
+		deliveries, err := s.connector.Consume(s.config.ConsumerTag, s.config.Prefetch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start consumer: %w", err)
+		}
+		s.deliveries = deliveries
+	}
+	return s.deliveries, nil
+}
+
+// Read implements pipeline.Source. It stalls after Prefetch deliveries
+// unless the caller commits or rejects the records as they arrive.
+func (s *RabbitMQSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	deliveries, err := s.consume()
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case d, ok := <-deliveries:
+				if !ok {
+					return
+				}
+				record, ok := s.record(d)
+				if !ok {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- record:
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. It returns up to BatchSize
+// deliveries, at most Prefetch, ending early once the queue has been idle
+// for IdleTimeout.
+func (s *RabbitMQSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	deliveries, err := s.consume()
+	if err != nil {
+		return nil, err
+	}
+	batchSize := config.BatchSize
+	if batchSize <= 0 || batchSize > s.config.Prefetch {
+		// No more is delivered until the batch is acknowledged
+		batchSize = s.config.Prefetch
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		idle := time.NewTimer(s.config.IdleTimeout)
+		defer idle.Stop()
+
+		for n := 0; n < batchSize; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-idle.C:
+				return
+			case d, ok := <-deliveries:
+				if !ok {
+					return
+				}
+				record, ok := s.record(d)
+				if !ok {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- record:
+				}
+				n++
+				idle.Reset(s.config.IdleTimeout)
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// record converts a delivery, rejecting it if the body cannot be decoded
+func (s *RabbitMQSource) record(d amqp.Delivery) (pipeline.Record, bool) {
+	data, err := s.config.Codec.Decode(d.Body)
+	if err != nil {
+		d.Nack(false, false)
+		return pipeline.Record{}, false
+	}
+
+	metadata := map[string]string{
+		RabbitMQMetaDeliveryTag: strconv.FormatUint(d.DeliveryTag, 10),
+		RabbitMQMetaExchange:    d.Exchange,
+		RabbitMQMetaRoutingKey:  d.RoutingKey,
+		RabbitMQMetaRedelivered: strconv.FormatBool(d.Redelivered),
+	}
+	if d.MessageId != "" {
+		metadata[RabbitMQMetaMessageID] = d.MessageId
+	}
+	for k, v := range d.Headers {
+		metadata[RabbitMQMetaHeaderPrefix+k] = fmt.Sprint(v)
+	}
+
+	timestamp := d.Timestamp.Unix()
+	if d.Timestamp.IsZero() {
+		timestamp = time.Now().Unix()
+	}
+
+	id := d.MessageId
+	if id == "" {
+		id = metadata[RabbitMQMetaDeliveryTag]
+	}
+	return pipeline.Record{
+		ID:        id,
+		Data:      data,
+		Metadata:  metadata,
+		Timestamp: timestamp,
+	}, true
+}
+
+// Commit implements pipeline.Committer by acknowledging the deliveries
+func (s *RabbitMQSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	tags, err := deliveryTags(records)
+	if err != nil {
+		return err
+	}
+	ch := s.connector.Channel()
+	for _, tag := range tags {
+		if err := ch.Ack(tag, false); err != nil {
+			return fmt.Errorf("failed to ack delivery %d: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// Reject implements pipeline.Rejecter by negatively acknowledging the
+// deliveries, requeueing them unless DiscardOnFailure is set
+func (s *RabbitMQSource) Reject(ctx context.Context, records []pipeline.Record, cause error) error {
+	tags, err := deliveryTags(records)
+	if err != nil {
+		return err
+	}
+	ch := s.connector.Channel()
+	for _, tag := range tags {
+		if err := ch.Nack(tag, false, !s.config.DiscardOnFailure); err != nil {
+			return fmt.Errorf("failed to nack delivery %d: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// deliveryTags returns the distinct delivery tags of records in order
+func deliveryTags(records []pipeline.Record) ([]uint64, error) {
+	seen := make(map[uint64]bool, len(records))
+	tags := make([]uint64, 0, len(records))
+	for _, record := range records {
+		v, ok := record.Metadata[RabbitMQMetaDeliveryTag]
+		if !ok {
+			continue
+		}
+		tag, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delivery tag %q: %w", v, err)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	return tags, nil
+}
+
+// Close implements pipeline.Source. Unacknowledged deliveries are requeued
+// by the broker.
+func (s *RabbitMQSource) Close() error {
+	return s.connector.Disconnect()
+}
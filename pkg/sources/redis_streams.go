@@ -0,0 +1,408 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/messaging"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Record metadata keys set by RedisStreamsSource
+const (
+	RedisMetaStream = "redis.stream"
+	RedisMetaID     = "redis.id"
+)
+
+// RedisStreamsSourceConfig configures a Redis Streams consumer group source
+type RedisStreamsSourceConfig struct {
+	Connection messaging.RedisConfig
+	Streams    []string
+	Group      string
+	// Consumer names this member of the group; defaults to hostname-pid.
+	// Keep it stable across restarts so pending entries are picked up again.
+	Consumer string
+	// StartID is where a newly created group starts: "$" (default) for new
+	// entries only or "0" for the whole stream
+	StartID string
+	// Count is the maximum number of entries per read; defaults to 100
+	Count int64
+	// Block is how long a read waits for new entries; defaults to one second
+	Block time.Duration
+	// ClaimMinIdle is how long an entry must have been pending with another
+	// consumer before it is claimed with XAUTOCLAIM; defaults to one minute.
+	// A negative value disables claiming.
+	ClaimMinIdle time.Duration
+	// Codec decodes PayloadField when set; otherwise the entry's fields
+	// become the record data as strings
+	Codec        codec.Codec
+	PayloadField string
+}
+
+// RedisStreamsSource implements pipeline.PullSource over Redis Streams
+// consumer groups. Entries are acknowledged with XACK through Commit. Until
+// then they stay pending: this consumer reads them again after a restart or
+// a Reject, and other consumers claim them once they have been idle for
+// ClaimMinIdle. Read and claim errors are reported by Err.
+type RedisStreamsSource struct {
+	connector *messaging.RedisConnector
+	config    RedisStreamsSourceConfig
+
+	mu sync.Mutex
+	// backlog is set while this consumer's own pending entries are re-read;
+	// pending holds the last pending ID read from each stream
+	backlog bool
+	pending map[string]string
+	// cursors are the XAUTOCLAIM positions of the current claim pass
+	cursors map[string]string
+	claimAt time.Time
+	err     error
+}
+
+// NewRedisStreamsSource creates a new Redis Streams source, creating the
+// consumer group on every stream if it does not exist
+func NewRedisStreamsSource(config RedisStreamsSourceConfig) (*RedisStreamsSource, error) {
+	if len(config.Streams) == 0 {
+		return nil, fmt.Errorf("at least one stream is required")
+	}
+	if config.Group == "" {
+		return nil, fmt.Errorf("consumer group is required")
+	}
+	if config.Consumer == "" {
+		hostname, _ := os.Hostname()
+		config.Consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if config.StartID == "" {
+		config.StartID = "$"
+	}
+	if config.Count <= 0 {
+		config.Count = 100
+	}
+	if config.Block <= 0 {
+		config.Block = time.Second
+	}
+	if config.ClaimMinIdle == 0 {
+		config.ClaimMinIdle = time.Minute
+	}
+	if config.Codec != nil && config.PayloadField == "" {
+		config.PayloadField = "payload"
+	}
+
+	connector := messaging.NewRedisConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	ctx := context.Background()
+	for _, stream := range config.Streams {
+		if err := connector.EnsureGroup(ctx, stream, config.Group, config.StartID); err != nil {
+			connector.Disconnect()
+			return nil, fmt.Errorf("failed to create consumer group on %s: %w", stream, err)
+		}
+	}
+
+	cursors := make(map[string]string, len(config.Streams))
+	for _, stream := range config.Streams {
+		cursors[stream] = "0-0"
+	}
+
+	s := &RedisStreamsSource{
+		connector: connector,
+		config:    config,
+		cursors:   cursors,
+	}
+	s.rewind()
+	return s, nil
+}
+
+// rewind makes the next reads start over with this consumer's pending
+// entries. Callers hold mu or own s exclusively.
+func (s *RedisStreamsSource) rewind() {
+	s.backlog = true
+	s.pending = make(map[string]string, len(s.config.Streams))
+	for _, stream := range s.config.Streams {
+		s.pending[stream] = "0"
+	}
+}
+
+// Read implements pipeline.Source. After a failed read it waits for Block
+// and tries again.
+func (s *RedisStreamsSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		for ctx.Err() == nil {
+			streams, err := s.fetch(ctx, s.config.Count)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				s.setErr(err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(s.config.Block):
+				}
+				continue
+			}
+			if !s.emit(ctx, streams, out) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. It returns up to BatchSize entries,
+// ending early once a read has waited Block without new entries, or at the
+// first failed read.
+func (s *RedisStreamsSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	batchSize := int64(config.BatchSize)
+	if batchSize <= 0 {
+		batchSize = s.config.Count
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		for n := int64(0); n < batchSize; {
+			count := batchSize - n
+			if count > s.config.Count {
+				count = s.config.Count
+			}
+			streams, err := s.fetch(ctx, count)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.setErr(err)
+				}
+				return
+			}
+			if len(streams) == 0 {
+				return
+			}
+			if !s.emit(ctx, streams, out) {
+				return
+			}
+			for _, stream := range streams {
+				n += int64(len(stream.Messages))
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// fetch returns the next entries for this consumer: its own pending entries
+// while catching up, then entries claimed from idle consumers, then new
+// entries. It returns nothing when no entry arrived within Block.
+func (s *RedisStreamsSource) fetch(ctx context.Context, count int64) ([]redis.XStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.backlog {
+		ids := make([]string, len(s.config.Streams))
+		for i, stream := range s.config.Streams {
+			ids[i] = s.pending[stream]
+		}
+		streams, err := s.readGroup(ctx, ids, count, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(streams) > 0 {
+			for _, stream := range streams {
+				s.pending[stream.Stream] = stream.Messages[len(stream.Messages)-1].ID
+			}
+			return streams, nil
+		}
+		s.backlog = false
+	}
+
+	if s.config.ClaimMinIdle > 0 && !time.Now().Before(s.claimAt) {
+		streams, err := s.claim(ctx, count)
+		if err != nil {
+			return nil, err
+		}
+		if len(streams) > 0 {
+			return streams, nil
+		}
+	}
+
+	ids := make([]string, len(s.config.Streams))
+	for i := range ids {
+		ids[i] = ">"
+	}
+	return s.readGroup(ctx, ids, count, s.config.Block)
+}
+
+// readGroup runs XREADGROUP from the given ID of each stream. A negative
+// block does not wait at all.
+func (s *RedisStreamsSource) readGroup(ctx context.Context, ids []string, count int64, block time.Duration) ([]redis.XStream, error) {
+	args := make([]string, 0, 2*len(s.config.Streams))
+	args = append(args, s.config.Streams...)
+	args = append(args, ids...)
+
+	streams, err := s.connector.Client().XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.config.Group,
+		Consumer: s.config.Consumer,
+		Streams:  args,
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from streams: %w", err)
+	}
+
+	// Reading pending entries returns every stream, including empty ones
+	nonEmpty := streams[:0]
+	for _, stream := range streams {
+		if len(stream.Messages) > 0 {
+			nonEmpty = append(nonEmpty, stream)
+		}
+	}
+	return nonEmpty, nil
+}
+
+// claim advances the XAUTOCLAIM pass over every stream. Once all streams
+// have been scanned, the next pass waits for ClaimMinIdle.
+func (s *RedisStreamsSource) claim(ctx context.Context, count int64) ([]redis.XStream, error) {
+	var streams []redis.XStream
+	done := true
+	for _, stream := range s.config.Streams {
+		messages, cursor, err := s.connector.AutoClaim(ctx, stream, s.config.Group, s.config.Consumer,
+			s.config.ClaimMinIdle, s.cursors[stream], count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim pending entries on %s: %w", stream, err)
+		}
+		s.cursors[stream] = cursor
+		if cursor != "0-0" {
+			done = false
+		}
+		if len(messages) > 0 {
+			streams = append(streams, redis.XStream{Stream: stream, Messages: messages})
+		}
+	}
+	if done {
+		s.claimAt = time.Now().Add(s.config.ClaimMinIdle)
+	}
+	return streams, nil
+}
+
+func (s *RedisStreamsSource) emit(ctx context.Context, streams []redis.XStream, out chan<- pipeline.Record) bool {
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- s.record(stream.Stream, msg):
+			}
+		}
+	}
+	return true
+}
+
+// record converts a stream entry. Entries whose payload cannot be decoded
+// keep their raw fields so that they are not lost.
+func (s *RedisStreamsSource) record(stream string, msg redis.XMessage) pipeline.Record {
+	data := make(map[string]interface{}, len(msg.Values))
+	for k, v := range msg.Values {
+		data[k] = v
+	}
+	if s.config.Codec != nil {
+		if payload, ok := msg.Values[s.config.PayloadField].(string); ok {
+			if decoded, err := s.config.Codec.Decode([]byte(payload)); err == nil {
+				data = decoded
+			}
+		}
+	}
+
+	return pipeline.Record{
+		ID:   msg.ID,
+		Data: data,
+		Metadata: map[string]string{
+			RedisMetaStream: stream,
+			RedisMetaID:     msg.ID,
+		},
+		Timestamp: streamIDTime(msg.ID),
+	}
+}
+
+// streamIDTime returns the Unix time encoded in the millisecond part of an
+// entry ID
+func streamIDTime(id string) int64 {
+	var ms, seq int64
+	if _, err := fmt.Sscanf(id, "%d-%d", &ms, &seq); err != nil {
+		return time.Now().Unix()
+	}
+	return ms / 1000
+}
+
+// Commit implements pipeline.Committer by acknowledging the entries
+func (s *RedisStreamsSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	ids := make(map[string][]string)
+	var streams []string
+	for _, record := range records {
+		stream, ok := record.Metadata[RedisMetaStream]
+		if !ok {
+			continue
+		}
+		if _, seen := ids[stream]; !seen {
+			streams = append(streams, stream)
+		}
+		ids[stream] = append(ids[stream], record.Metadata[RedisMetaID])
+	}
+	if len(streams) == 0 {
+		return nil
+	}
+
+	_, err := s.connector.Client().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, stream := range streams {
+			pipe.XAck(ctx, stream, s.config.Group, ids[stream]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ack entries: %w", err)
+	}
+	return nil
+}
+
+// Reject implements pipeline.Rejecter. The entries stay pending, so the
+// next read delivers this consumer's pending entries again.
+func (s *RedisStreamsSource) Reject(ctx context.Context, records []pipeline.Record, cause error) error {
+	s.mu.Lock()
+	s.rewind()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RedisStreamsSource) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the last error met while reading the streams
+func (s *RedisStreamsSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close implements pipeline.Source. Unacknowledged entries stay pending in
+// the group.
+func (s *RedisStreamsSource) Close() error {
+	return s.connector.Disconnect()
+}
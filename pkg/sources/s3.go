@@ -0,0 +1,40 @@
+package sources
+
+import (
+	"fmt"
+
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+)
+
+// S3SourceConfig configures an S3 object source
+type S3SourceConfig struct {
+	Connection cloud.S3Config
+	ObjectSourceConfig
+}
+
+// S3Source is an ObjectSource over an S3 bucket. Record IDs are
+// "s3://bucket/key#line".
+type S3Source struct {
+	*ObjectSource
+	connector *cloud.S3Connector
+}
+
+// NewS3Source creates a new S3 source
+func NewS3Source(config S3SourceConfig) (*S3Source, error) {
+	connector := cloud.NewS3Connector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to s3: %w", err)
+	}
+
+	source, err := NewObjectSource(connector, "s3://"+config.Connection.Bucket, config.ObjectSourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Source{ObjectSource: source, connector: connector}, nil
+}
+
+// Close implements pipeline.Source
+func (s *S3Source) Close() error {
+	s.ObjectSource.Close()
+	return s.connector.Disconnect()
+}
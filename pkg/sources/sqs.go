@@ -0,0 +1,343 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/ivikasavnish/datapipe/pkg/codec"
+	"github.com/ivikasavnish/datapipe/pkg/connectors/cloud"
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// Record metadata keys set by SQSSource
+const (
+	SQSMetaMessageID     = "sqs.message_id"
+	SQSMetaReceiptHandle = "sqs.receipt_handle"
+	SQSMetaReceiveCount  = "sqs.receive_count"
+	// FIFO queues only
+	SQSMetaGroupID         = "sqs.message_group_id"
+	SQSMetaDeduplicationID = "sqs.deduplication_id"
+	SQSMetaSequenceNumber  = "sqs.sequence_number"
+	// SQSMetaAttributePrefix prefixes string and number message attributes
+	SQSMetaAttributePrefix = "sqs.attribute."
+)
+
+// sqsMaxWait is the longest long poll SQS allows
+const sqsMaxWait = 20 * time.Second
+
+// sqsSystemMetadata maps the message system attributes copied to record
+// metadata to their keys
+var sqsSystemMetadata = map[string]string{
+	sqs.MessageSystemAttributeNameApproximateReceiveCount: SQSMetaReceiveCount,
+	sqs.MessageSystemAttributeNameMessageGroupId:          SQSMetaGroupID,
+	sqs.MessageSystemAttributeNameMessageDeduplicationId:  SQSMetaDeduplicationID,
+	sqs.MessageSystemAttributeNameSequenceNumber:          SQSMetaSequenceNumber,
+}
+
+// SQSSourceConfig configures an SQS queue source
+type SQSSourceConfig struct {
+	Connection cloud.SQSConfig
+	// MaxMessages is the number of messages per receive, at most 10
+	MaxMessages int64
+	// WaitTime is the long poll duration, at most 20 seconds (the default)
+	WaitTime time.Duration
+	// VisibilityTimeout is requested on receive and used to extend messages
+	// that are still being processed; defaults to the queue's setting
+	VisibilityTimeout time.Duration
+	// MaxExtension stops extending the visibility of messages received
+	// longer ago than this, so that stuck batches are redelivered; defaults
+	// to one hour
+	MaxExtension time.Duration
+	// Codec decodes message bodies; defaults to JSON. Messages that fail to
+	// decode are left on the queue for its redrive policy.
+	Codec codec.Codec
+}
+
+// SQSSource implements pipeline.PullSource for an SQS queue. Messages are
+// deleted through Commit once the sink has written them. Until then their
+// visibility timeout is extended in the background. Rejected messages are
+// released and become visible again when their timeout expires, leaving
+// repeated failures to the queue's redrive policy.
+//
+// Records from FIFO queues are emitted in the order SQS delivers them and
+// carry their group in SQSMetaGroupID. Use transformers.Partitioned with
+// transformers.ByMetadata(SQSMetaGroupID) to transform them in parallel
+// without reordering a group.
+type SQSSource struct {
+	connector *cloud.SQSConnector
+	config    SQSSourceConfig
+
+	mu sync.Mutex
+	// inFlight maps the receipt handles of uncommitted messages to the
+	// time they were received
+	inFlight  map[string]time.Time
+	extending bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSQSSource creates a new SQS source
+func NewSQSSource(config SQSSourceConfig) (*SQSSource, error) {
+	if config.MaxMessages <= 0 || config.MaxMessages > 10 {
+		config.MaxMessages = 10
+	}
+	if config.WaitTime <= 0 || config.WaitTime > sqsMaxWait {
+		config.WaitTime = sqsMaxWait
+	}
+	if config.MaxExtension <= 0 {
+		config.MaxExtension = time.Hour
+	}
+	if config.Codec == nil {
+		config.Codec = codec.NewJSON()
+	}
+
+	connector := cloud.NewSQSConnector(config.Connection)
+	if err := connector.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to sqs: %w", err)
+	}
+
+	if config.VisibilityTimeout <= 0 {
+		visibility, err := connector.VisibilityTimeout(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue visibility timeout: %w", err)
+		}
+		config.VisibilityTimeout = visibility
+	}
+	if config.VisibilityTimeout < time.Second {
+		return nil, fmt.Errorf("visibility timeout must be at least one second")
+	}
+
+	return &SQSSource{
+		connector: connector,
+		config:    config,
+		inFlight:  make(map[string]time.Time),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Read implements pipeline.Source
+func (s *SQSSource) Read(ctx context.Context) (<-chan pipeline.Record, error) {
+	s.startExtending()
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		for ctx.Err() == nil {
+			messages, err := s.receive(ctx, s.config.MaxMessages, s.config.WaitTime)
+			if err != nil {
+				return
+			}
+			if !s.emit(ctx, messages, out) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Pull implements pipeline.PullSource. The first receive long-polls for
+// WaitTime; the batch then fills from messages that are already available
+// and ends as soon as a receive comes back empty.
+func (s *SQSSource) Pull(ctx context.Context, config pipeline.PullConfig) (<-chan pipeline.Record, error) {
+	s.startExtending()
+	batchSize := int64(config.BatchSize)
+	if batchSize <= 0 {
+		batchSize = s.config.MaxMessages
+	}
+	out := make(chan pipeline.Record)
+
+	go func() {
+		defer close(out)
+
+		wait := s.config.WaitTime
+		for n := int64(0); n < batchSize; {
+			count := batchSize - n
+			if count > s.config.MaxMessages {
+				count = s.config.MaxMessages
+			}
+			messages, err := s.receive(ctx, count, wait)
+			if err != nil || len(messages) == 0 {
+				return
+			}
+			if !s.emit(ctx, messages, out) {
+				return
+			}
+			n += int64(len(messages))
+			wait = 0
+		}
+	}()
+
+	return out, nil
+}
+
+// receive fetches messages and tracks them as in flight
+func (s *SQSSource) receive(ctx context.Context, count int64, wait time.Duration) ([]*sqs.Message, error) {
+	messages, err := s.connector.Receive(ctx, count, wait, s.config.VisibilityTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive messages: %w", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for _, msg := range messages {
+		s.inFlight[aws.StringValue(msg.ReceiptHandle)] = now
+	}
+	s.mu.Unlock()
+	return messages, nil
+}
+
+func (s *SQSSource) emit(ctx context.Context, messages []*sqs.Message, out chan<- pipeline.Record) bool {
+	for _, msg := range messages {
+		record, ok := s.record(msg)
+		if !ok {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case out <- record:
+		}
+	}
+	return true
+}
+
+// record converts a message. Messages that cannot be decoded are released
+// so that they are redelivered and eventually dead-lettered.
+func (s *SQSSource) record(msg *sqs.Message) (pipeline.Record, bool) {
+	handle := aws.StringValue(msg.ReceiptHandle)
+	data, err := s.config.Codec.Decode([]byte(aws.StringValue(msg.Body)))
+	if err != nil {
+		s.release([]string{handle})
+		return pipeline.Record{}, false
+	}
+
+	metadata := map[string]string{
+		SQSMetaMessageID:     aws.StringValue(msg.MessageId),
+		SQSMetaReceiptHandle: handle,
+	}
+	for name, key := range sqsSystemMetadata {
+		if v, ok := msg.Attributes[name]; ok {
+			metadata[key] = aws.StringValue(v)
+		}
+	}
+	for k, v := range msg.MessageAttributes {
+		if v.StringValue != nil {
+			metadata[SQSMetaAttributePrefix+k] = aws.StringValue(v.StringValue)
+		}
+	}
+
+	timestamp := time.Now().Unix()
+	if sent, ok := msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]; ok {
+		if ms, err := strconv.ParseInt(aws.StringValue(sent), 10, 64); err == nil {
+			timestamp = ms / 1000
+		}
+	}
+
+	return pipeline.Record{
+		ID:        aws.StringValue(msg.MessageId),
+		Data:      data,
+		Metadata:  metadata,
+		Timestamp: timestamp,
+	}, true
+}
+
+// startExtending starts the background loop that keeps in-flight messages
+// invisible, extending them at half the visibility timeout
+func (s *SQSSource) startExtending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.extending {
+		return
+	}
+	s.extending = true
+
+	go func() {
+		ticker := time.NewTicker(s.config.VisibilityTimeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.extend()
+			}
+		}
+	}()
+}
+
+// extend renews the visibility of in-flight messages. Messages past
+// MaxExtension are released instead.
+func (s *SQSSource) extend() {
+	now := time.Now()
+	var handles []string
+	s.mu.Lock()
+	for handle, received := range s.inFlight {
+		if now.Sub(received) > s.config.MaxExtension {
+			delete(s.inFlight, handle)
+			continue
+		}
+		handles = append(handles, handle)
+	}
+	s.mu.Unlock()
+
+	if len(handles) > 0 {
+		// A failed extension only means the message may be redelivered
+		s.connector.ChangeVisibilityBatch(context.Background(), handles, s.config.VisibilityTimeout)
+	}
+}
+
+// release stops extending the given messages
+func (s *SQSSource) release(handles []string) {
+	s.mu.Lock()
+	for _, handle := range handles {
+		delete(s.inFlight, handle)
+	}
+	s.mu.Unlock()
+}
+
+// Commit implements pipeline.Committer by deleting the messages
+func (s *SQSSource) Commit(ctx context.Context, records []pipeline.Record) error {
+	handles := receiptHandles(records)
+	if err := s.connector.DeleteMessageBatch(ctx, handles); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	s.release(handles)
+	return nil
+}
+
+// Reject implements pipeline.Rejecter. The messages are left on the queue
+// and become visible again once their visibility timeout expires.
+func (s *SQSSource) Reject(ctx context.Context, records []pipeline.Record, cause error) error {
+	s.release(receiptHandles(records))
+	return nil
+}
+
+// receiptHandles returns the distinct receipt handles of records
+func receiptHandles(records []pipeline.Record) []string {
+	seen := make(map[string]bool, len(records))
+	handles := make([]string, 0, len(records))
+	for _, record := range records {
+		handle, ok := record.Metadata[SQSMetaReceiptHandle]
+		if !ok || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+// Close implements pipeline.Source. Uncommitted messages become visible
+// again when their visibility timeout expires.
+func (s *SQSSource) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return s.connector.Disconnect()
+}
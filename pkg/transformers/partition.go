@@ -0,0 +1,143 @@
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync"
+
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+)
+
+// KeyFunc returns the partition key of a record
+type KeyFunc func(record pipeline.Record) string
+
+// ByMetadata partitions records on a metadata value, e.g. the SQS message
+// group ID
+func ByMetadata(key string) KeyFunc {
+	return func(record pipeline.Record) string {
+		return record.Metadata[key]
+	}
+}
+
+// ByField partitions records on the string form of a Record.Data field
+func ByField(field string) KeyFunc {
+	return func(record pipeline.Record) string {
+		v, ok := record.Data[field]
+		if !ok || v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// PartitionConfig configures a Partitioned transformer
+type PartitionConfig struct {
+	// Workers is the number of parallel workers; defaults to the number of
+	// CPUs
+	Workers int
+	// Key assigns records to workers. Records with the same key always go
+	// to the same worker.
+	Key KeyFunc
+}
+
+// Partitioned runs a transformer on several workers in parallel. Records
+// are routed to a worker by a hash of their key, so records that share a
+// key keep their relative order as long as the inner transformer keeps the
+// order of its input. Records with different keys may be reordered.
+type Partitioned struct {
+	inner  pipeline.Transformer
+	config PartitionConfig
+}
+
+// NewPartitioned creates a new Partitioned transformer. The inner
+// transformer's Transform is called once per worker, so it must be safe to
+// run concurrently with itself, and a pipeline.FailingTransformer must
+// report the failures of all the workers' Transforms together.
+func NewPartitioned(inner pipeline.Transformer, config PartitionConfig) (*Partitioned, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("transformer is required")
+	}
+	if config.Key == nil {
+		return nil, fmt.Errorf("partition key is required")
+	}
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	return &Partitioned{inner: inner, config: config}, nil
+}
+
+// Transform implements pipeline.Transformer
+func (p *Partitioned) Transform(ctx context.Context, in <-chan pipeline.Record) (<-chan pipeline.Record, error) {
+	inputs := make([]chan pipeline.Record, p.config.Workers)
+	outputs := make([]<-chan pipeline.Record, p.config.Workers)
+	for i := range inputs {
+		inputs[i] = make(chan pipeline.Record)
+		transformed, err := p.inner.Transform(ctx, inputs[i])
+		if err != nil {
+			for _, input := range inputs[:i+1] {
+				close(input)
+			}
+			return nil, err
+		}
+		outputs[i] = transformed
+	}
+
+	// Route records to their workers
+	go func() {
+		defer func() {
+			for _, input := range inputs {
+				close(input)
+			}
+		}()
+
+		for record := range in {
+			worker := p.worker(record)
+			select {
+			case <-ctx.Done():
+				return
+			case inputs[worker] <- record:
+			}
+		}
+	}()
+
+	// Merge the workers' output
+	out := make(chan pipeline.Record)
+	var wg sync.WaitGroup
+	wg.Add(len(outputs))
+	for _, output := range outputs {
+		go func(output <-chan pipeline.Record) {
+			defer wg.Done()
+			for record := range output {
+				select {
+				case <-ctx.Done():
+					// Keep draining so the worker can finish
+					continue
+				case out <- record:
+				}
+			}
+		}(output)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// Failed implements pipeline.FailingTransformer for inner transformers that
+// do, such as Validate
+func (p *Partitioned) Failed() error {
+	if failing, ok := p.inner.(pipeline.FailingTransformer); ok {
+		return failing.Failed()
+	}
+	return nil
+}
+
+func (p *Partitioned) worker(record pipeline.Record) int {
+	h := fnv.New32a()
+	h.Write([]byte(p.config.Key(record)))
+	return int(h.Sum32() % uint32(p.config.Workers))
+}
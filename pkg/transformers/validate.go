@@ -0,0 +1,169 @@
+package transformers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ivikasavnish/datapipe/pkg/pipeline"
+	"github.com/ivikasavnish/datapipe/pkg/schema"
+)
+
+// ValidateMetaErrors holds the JSON-encoded field errors of a record sent
+// to the error path
+const ValidateMetaErrors = "validation.errors"
+
+// ValidateConfig configures a Validate transformer
+type ValidateConfig struct {
+	Schema *schema.Schema
+	// Coerce converts values to their declared types where possible
+	Coerce bool
+	// ErrorSink receives invalid records, annotated with ValidateMetaErrors.
+	// Invalid records are dropped when no error sink is set. When the error
+	// sink fails, Failed reports it and the pipeline rejects the batch
+	// instead of committing records that were not written anywhere.
+	ErrorSink pipeline.Sink
+	// OnInvalid is called for every invalid record
+	OnInvalid func(record pipeline.Record, err *schema.ValidationError)
+}
+
+// Validate implements a transformer that checks records against a schema
+type Validate struct {
+	config ValidateConfig
+
+	mu      sync.Mutex
+	sinkErr error
+	// failed is the first error sink failure since the last call to Failed
+	failed   error
+	invalid  int64
+	accepted int64
+}
+
+// NewValidate creates a new Validate transformer
+func NewValidate(config ValidateConfig) (*Validate, error) {
+	if config.Schema == nil {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if err := config.Schema.Check(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Validate{config: config}, nil
+}
+
+// Transform implements pipeline.Transformer
+func (v *Validate) Transform(ctx context.Context, in <-chan pipeline.Record) (<-chan pipeline.Record, error) {
+	out := make(chan pipeline.Record)
+
+	var invalid chan pipeline.Record
+	var errorPath sync.WaitGroup
+	if v.config.ErrorSink != nil {
+		invalid = make(chan pipeline.Record)
+		errorPath.Add(1)
+		go func() {
+			defer errorPath.Done()
+			if err := v.config.ErrorSink.Write(ctx, invalid); err != nil {
+				v.mu.Lock()
+				v.sinkErr = err
+				if v.failed == nil {
+					v.failed = err
+				}
+				v.mu.Unlock()
+				// Keep draining so validation is not blocked
+				for range invalid {
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		defer errorPath.Wait()
+		if invalid != nil {
+			defer close(invalid)
+		}
+
+		for record := range in {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				valid, verr := v.validate(record)
+				if verr == nil {
+					out <- valid
+					continue
+				}
+
+				if v.config.OnInvalid != nil {
+					v.config.OnInvalid(record, verr)
+				}
+				if invalid != nil {
+					invalid <- annotateInvalid(record, verr)
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// validate returns the record with coerced data, or its field errors
+func (v *Validate) validate(record pipeline.Record) (pipeline.Record, *schema.ValidationError) {
+	data, err := v.config.Schema.Validate(record.Data, schema.Options{Coerce: v.config.Coerce})
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err != nil {
+		v.invalid++
+		var verr *schema.ValidationError
+		if !errors.As(err, &verr) {
+			verr = &schema.ValidationError{Errors: []schema.FieldError{{Message: err.Error()}}}
+		}
+		return record, verr
+	}
+	v.accepted++
+	record.Data = data
+	return record, nil
+}
+
+// annotateInvalid copies the record with its field errors in the metadata
+func annotateInvalid(record pipeline.Record, verr *schema.ValidationError) pipeline.Record {
+	metadata := make(map[string]string, len(record.Metadata)+1)
+	for k, val := range record.Metadata {
+		metadata[k] = val
+	}
+	// Marshalling []FieldError cannot fail
+	errs, _ := json.Marshal(verr.Errors)
+	metadata[ValidateMetaErrors] = string(errs)
+	record.Metadata = metadata
+	return record
+}
+
+// Counts returns the number of accepted and invalid records so far
+func (v *Validate) Counts() (accepted, invalid int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.accepted, v.invalid
+}
+
+// Failed implements pipeline.FailingTransformer. It returns the first error
+// sink failure since the last call, once the output of the Transforms has
+// been drained, and clears it, so concurrent Transforms share one report.
+func (v *Validate) Failed() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	failed := v.failed
+	v.failed = nil
+	if failed != nil {
+		return fmt.Errorf("failed to write invalid records: %w", failed)
+	}
+	return nil
+}
+
+// Err returns the last error from the error sink
+func (v *Validate) Err() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.sinkErr
+}